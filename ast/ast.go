@@ -11,9 +11,16 @@ import (
 // Each of our AST Nodes (ie each expression, statement) all must implement the Node interface, aka it must provide a TokenLiteral() that returns the literal value of the token it's associated with. TokenLiteral will only be used for debugging and testing
 // All nodes will be connected to each other
 // Some nodes implement the Statement interface, some the expression interface
+// Pos and End give the byte offsets of the node's source text: Pos is the offset of its first
+// character, End the one just after its last. Statements end with their last expression, the
+// optional ';' isn't part of them, and neither are the parentheses of a grouped expression.
+// Kind tells the concrete type of the node without a type switch
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() int
+	End() int
+	Kind() NodeKind
 }
 
 type Statement interface {
@@ -30,11 +37,12 @@ type Program struct {
 	Statements []Statement
 }
 
-// LetStatement implements Statement interface
+// LetStatement implements Statement interface. It also represents `const x = ...;`, with Const set
 type LetStatement struct {
-	Token token.Token // the token.LET token
+	Token token.Token // the token.LET or token.CONST token
 	Name  *Identifier
 	Value Expression
+	Const bool // the binding can't be reassigned
 }
 
 type ReturnStatement struct { // imple
@@ -42,6 +50,56 @@ type ReturnStatement struct { // imple
 	ReturnValue Expression
 }
 
+// ThrowStatement represents `throw expr;`
+type ThrowStatement struct {
+	Token token.Token // the 'throw' token
+	Value Expression
+}
+
+// DeferStatement represents `defer expr;`. The expression is evaluated when the function call the
+// statement ran in ends
+type DeferStatement struct {
+	Token token.Token // the 'defer' token
+	Value Expression
+}
+
+// WhileStatement represents `while (cond) { ... }`
+type WhileStatement struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+// ForStatement represents `for (init; cond; post) { ... }`. Each of Init, Condition and Post may be
+// nil, a missing Condition loops forever
+type ForStatement struct {
+	Token     token.Token // the 'for' token
+	Init      Statement
+	Condition Expression
+	Post      Statement
+	Body      *BlockStatement
+}
+
+// ForInStatement represents `for (x in iterable) { ... }` and `for (k, v in iterable) { ... }`. Key
+// is nil in the first form
+type ForInStatement struct {
+	Token    token.Token // the 'for' token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+// BreakStatement represents `break;`, leaving the innermost loop
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+}
+
+// ContinueStatement represents `continue;`, going on with the next iteration of the innermost loop
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+}
+
 type ExpressionStatement struct {
 	Token      token.Token // the first token of the expression
 	Expression Expression
@@ -58,6 +116,12 @@ type IntegerLiteral struct {
 	Value int64
 }
 
+// FloatLiteral is a number with a fractional part, eg 3.14
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
 type PrefixExpression struct {
 	Token    token.Token // a prefix token (! or -)
 	Operator string
@@ -76,6 +140,11 @@ type Boolean struct {
 	Value bool
 }
 
+// NullLiteral is the `null` keyword
+type NullLiteral struct {
+	Token token.Token
+}
+
 type IfExpression struct {
 	Token       token.Token // the 'if' token
 	Condition   Expression
@@ -86,6 +155,7 @@ type IfExpression struct {
 type BlockStatement struct {
 	Token      token.Token // the '{' token
 	Statements []Statement
+	Rbrace     token.Token // the closing '}', unset for the body of a |x| lambda
 }
 
 type FunctionLiteral struct {
@@ -94,10 +164,26 @@ type FunctionLiteral struct {
 	Body       *BlockStatement
 }
 
+// MacroLiteral represents `macro(x, y) { ... }`. It's only ever evaluated by the macro expansion
+// phase, quote and unquote inside its body are ordinary CallExpressions
+type MacroLiteral struct {
+	Token      token.Token // the 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
 type CallExpression struct {
-	Token     token.Token // The '(' token
-	Function  Expression  // Identifier or FunctionLiteral .. What if a prefix expression is given???
-	Arguments []Expression
+	Token          token.Token // The '(' token
+	Function       Expression  // Identifier or FunctionLiteral .. What if a prefix expression is given???
+	Arguments      []Expression
+	NamedArguments []*NamedArgument // `name: value` arguments, in source order, always after the positional ones
+	Rparen         token.Token      // the closing ')'
+}
+
+// NamedArgument is a single `name: value` argument of a call, eg port: 8080 in makeServer(port: 8080)
+type NamedArgument struct {
+	Name  *Identifier
+	Value Expression
 }
 
 type StringLiteral struct {
@@ -105,20 +191,125 @@ type StringLiteral struct {
 	Value string
 }
 
+// InterpolatedString represents "Hello ${name}!", a string literal with expressions in it. Strings
+// are the unescaped text around the expressions, there's always one more of them than of Exprs
+type InterpolatedString struct {
+	Token   token.Token // the STRING token
+	Strings []string
+	Exprs   []Expression
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
+	Rbracket token.Token // the closing ']'
 }
 
 type IndexExpression struct {
-	Token token.Token // the '[' token
-	Left  Expression
-	Index Expression
+	Token    token.Token // the '[' token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token // the closing ']'
+}
+
+// Value is what the tree needs to know of an evaluated object: object.Object satisfies it. It's
+// declared here because the object package imports ast, not the other way around
+type Value interface {
+	Inspect() string
+}
+
+// ObjectExpression is an already evaluated value standing in for an expression. Macro expansion
+// puts them in the tree where unquote(...) returned something with no literal form of its own,
+// eg a function, and the evaluator evaluates them to the object they wrap
+type ObjectExpression struct {
+	Token token.Token // the token of the expression it replaced
+	Value Value
+}
+
+// AssignExpression represents `x = value`, rebinding an existing name
+type AssignExpression struct {
+	Token token.Token // the '=' token
+	Name  *Identifier
+	Value Expression
+}
+
+// IndexAssignExpression represents `arr[i] = value` and `hash[k] = value`, changing an element of
+// the array or hash Target.Left evaluates to
+type IndexAssignExpression struct {
+	Token  token.Token // the '=' token
+	Target *IndexExpression
+	Value  Expression
 }
 
 type HashLiteral struct {
-	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Token  token.Token // the '{' token
+	Pairs  []*HashPair // in source order
+	Rbrace token.Token // the closing '}'
+}
+
+// HashPair is a single `key: value` entry of a HashLiteral
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// TryExpression represents `try { ... } catch (e) { ... }`. Like if, it's an expression producing the value of whichever block ran
+type TryExpression struct {
+	Token      token.Token // the 'try' token
+	Block      *BlockStatement
+	CatchParam *Identifier // the error gets bound to this name inside CatchBlock
+	CatchBlock *BlockStatement
+}
+
+// MatchExpression represents `match (x) { 1 => "one", _ => "other" }`
+type MatchExpression struct {
+	Token   token.Token // the 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+	Rbrace  token.Token // the closing '}'
+}
+
+// MatchArm is a single `pattern => body` arm of a MatchExpression. The wildcard `_` is parsed as a plain Identifier
+type MatchArm struct {
+	Token   token.Token // the first token of the pattern
+	Pattern Expression
+	Body    Expression
+}
+
+// SelectExpression represents `select { case v = recv(c): ... case send(d, x): ... default: ... }`.
+// It waits until one of its cases can go ahead and evaluates to the body of that case. select, case
+// and default aren't keywords, the parser tells them apart by what follows them
+type SelectExpression struct {
+	Token  token.Token // the 'select' identifier
+	Cases  []*SelectCase
+	Rbrace token.Token // the closing '}'
+}
+
+// SelectCase is a single case of a SelectExpression: a receive from Channel, binding the value to
+// Name if there is one, a send of Value to Channel, or the default case, which has neither
+type SelectCase struct {
+	Token   token.Token // the 'case' or 'default' identifier
+	Name    *Identifier // nil unless it's `case name = recv(...)`
+	Op      token.Token // the recv, receive or send identifier, unset for the default case
+	Channel Expression  // nil for the default case
+	Value   Expression  // what a send case sends, nil for the others
+	Body    *BlockStatement
+}
+
+// SliceExpression represents arr[1:3], arr[:3] and arr[2:]. Low and High are nil when omitted
+type SliceExpression struct {
+	Token    token.Token // the '[' token
+	Left     Expression
+	Low      Expression
+	High     Expression
+	Rbracket token.Token // the closing ']'
+}
+
+// RangeExpression represents `start..end`, eg 1..10 or a..b
+type RangeExpression struct {
+	Token token.Token // the '..' token
+	From  Expression
+	To    Expression
 }
 
 func (p *Program) TokenLiteral() string {
@@ -134,47 +325,205 @@ func (ls *LetStatement) statementNode()        {}
 func (rs *ReturnStatement) statementNode()     {}
 func (es *ExpressionStatement) statementNode() {}
 func (bs *BlockStatement) statementNode()      {}
+func (ts *ThrowStatement) statementNode()      {}
+func (ds *DeferStatement) statementNode()      {}
+func (ws *WhileStatement) statementNode()      {}
+func (fs *ForStatement) statementNode()        {}
+func (fs *ForInStatement) statementNode()      {}
+func (bs *BreakStatement) statementNode()      {}
+func (cs *ContinueStatement) statementNode()   {}
 
 // To satisfy the ast.Expression interface...
-func (i *Identifier) expressionNode()        {}
-func (il *IntegerLiteral) expressionNode()   {}
-func (pe *PrefixExpression) expressionNode() {}
-func (ie *InfixExpression) expressionNode()  {}
-func (b *Boolean) expressionNode()           {}
-func (ie *IfExpression) expressionNode()     {}
-func (fl *FunctionLiteral) expressionNode()  {}
-func (ce *CallExpression) expressionNode()   {}
-func (sl *StringLiteral) expressionNode()    {}
-func (al *ArrayLiteral) expressionNode()     {}
-func (ie *IndexExpression) expressionNode()  {}
-func (hl *HashLiteral) expressionNode() {}
-
-func (ls *LetStatement) TokenLiteral() string        { return ls.Token.Literal }
-func (i *Identifier) TokenLiteral() string           { return i.Token.Literal }
-func (rs *ReturnStatement) TokenLiteral() string     { return rs.Token.Literal }
-func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
-func (il *IntegerLiteral) TokenLiteral() string      { return il.Token.Literal }
-func (pe *PrefixExpression) TokenLiteral() string    { return pe.Token.Literal }
-func (ie *InfixExpression) TokenLiteral() string     { return ie.Token.Literal }
-func (b *Boolean) TokenLiteral() string              { return b.Token.Literal }
-func (ie *IfExpression) TokenLiteral() string        { return ie.Token.Literal }
-func (bs *BlockStatement) TokenLiteral() string      { return bs.Token.Literal }
-func (fl *FunctionLiteral) TokenLiteral() string     { return fl.Token.Literal }
-func (ce *CallExpression) TokenLiteral() string      { return ce.Token.Literal }
-func (sl *StringLiteral) TokenLiteral() string       { return sl.Token.Literal }
-func (al *ArrayLiteral) TokenLiteral() string        { return al.Token.Literal }
-func (ie *IndexExpression) TokenLiteral() string     { return ie.Token.Literal }
-func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
-
-// Programs String method creates a buffer and writes the return value of each statement's String() method to it
+func (i *Identifier) expressionNode()             {}
+func (il *IntegerLiteral) expressionNode()        {}
+func (fl *FloatLiteral) expressionNode()          {}
+func (pe *PrefixExpression) expressionNode()      {}
+func (ie *InfixExpression) expressionNode()       {}
+func (b *Boolean) expressionNode()                {}
+func (n *NullLiteral) expressionNode()            {}
+func (ie *IfExpression) expressionNode()          {}
+func (fl *FunctionLiteral) expressionNode()       {}
+func (ce *CallExpression) expressionNode()        {}
+func (sl *StringLiteral) expressionNode()         {}
+func (is *InterpolatedString) expressionNode()    {}
+func (al *ArrayLiteral) expressionNode()          {}
+func (ie *IndexExpression) expressionNode()       {}
+func (ae *AssignExpression) expressionNode()      {}
+func (oe *ObjectExpression) expressionNode()      {}
+func (ia *IndexAssignExpression) expressionNode() {}
+func (hl *HashLiteral) expressionNode()           {}
+func (re *RangeExpression) expressionNode()       {}
+func (se *SliceExpression) expressionNode()       {}
+func (me *MatchExpression) expressionNode()       {}
+func (se *SelectExpression) expressionNode()      {}
+func (te *TryExpression) expressionNode()         {}
+func (ml *MacroLiteral) expressionNode()          {}
+
+func (ls *LetStatement) TokenLiteral() string          { return ls.Token.Literal }
+func (i *Identifier) TokenLiteral() string             { return i.Token.Literal }
+func (rs *ReturnStatement) TokenLiteral() string       { return rs.Token.Literal }
+func (es *ExpressionStatement) TokenLiteral() string   { return es.Token.Literal }
+func (il *IntegerLiteral) TokenLiteral() string        { return il.Token.Literal }
+func (fl *FloatLiteral) TokenLiteral() string          { return fl.Token.Literal }
+func (pe *PrefixExpression) TokenLiteral() string      { return pe.Token.Literal }
+func (ie *InfixExpression) TokenLiteral() string       { return ie.Token.Literal }
+func (b *Boolean) TokenLiteral() string                { return b.Token.Literal }
+func (n *NullLiteral) TokenLiteral() string            { return n.Token.Literal }
+func (ie *IfExpression) TokenLiteral() string          { return ie.Token.Literal }
+func (bs *BlockStatement) TokenLiteral() string        { return bs.Token.Literal }
+func (fl *FunctionLiteral) TokenLiteral() string       { return fl.Token.Literal }
+func (ce *CallExpression) TokenLiteral() string        { return ce.Token.Literal }
+func (na *NamedArgument) TokenLiteral() string         { return na.Name.TokenLiteral() }
+func (sl *StringLiteral) TokenLiteral() string         { return sl.Token.Literal }
+func (is *InterpolatedString) TokenLiteral() string    { return is.Token.Literal }
+func (al *ArrayLiteral) TokenLiteral() string          { return al.Token.Literal }
+func (ie *IndexExpression) TokenLiteral() string       { return ie.Token.Literal }
+func (ae *AssignExpression) TokenLiteral() string      { return ae.Token.Literal }
+func (oe *ObjectExpression) TokenLiteral() string      { return oe.Token.Literal }
+func (ia *IndexAssignExpression) TokenLiteral() string { return ia.Token.Literal }
+func (hl *HashLiteral) TokenLiteral() string           { return hl.Token.Literal }
+func (re *RangeExpression) TokenLiteral() string       { return re.Token.Literal }
+func (se *SliceExpression) TokenLiteral() string       { return se.Token.Literal }
+func (me *MatchExpression) TokenLiteral() string       { return me.Token.Literal }
+func (ma *MatchArm) TokenLiteral() string              { return ma.Token.Literal }
+func (se *SelectExpression) TokenLiteral() string      { return se.Token.Literal }
+func (sc *SelectCase) TokenLiteral() string            { return sc.Token.Literal }
+func (ts *ThrowStatement) TokenLiteral() string        { return ts.Token.Literal }
+func (ds *DeferStatement) TokenLiteral() string        { return ds.Token.Literal }
+func (te *TryExpression) TokenLiteral() string         { return te.Token.Literal }
+func (ml *MacroLiteral) TokenLiteral() string          { return ml.Token.Literal }
+func (ws *WhileStatement) TokenLiteral() string        { return ws.Token.Literal }
+func (fs *ForStatement) TokenLiteral() string          { return fs.Token.Literal }
+func (fs *ForInStatement) TokenLiteral() string        { return fs.Token.Literal }
+func (bs *BreakStatement) TokenLiteral() string        { return bs.Token.Literal }
+func (cs *ContinueStatement) TokenLiteral() string     { return cs.Token.Literal }
+
+// Source positions. Leaves span their token, everything else runs from its first token or child
+// to its closing delimiter or last child
+func (p *Program) Pos() int {
+	if len(p.Statements) == 0 {
+		return 0
+	}
+	return p.Statements[0].Pos()
+}
+func (p *Program) End() int {
+	if len(p.Statements) == 0 {
+		return 0
+	}
+	return p.Statements[len(p.Statements)-1].End()
+}
+
+func (ls *LetStatement) Pos() int        { return ls.Token.Offset }
+func (ls *LetStatement) End() int        { return ls.Value.End() }
+func (rs *ReturnStatement) Pos() int     { return rs.Token.Offset }
+func (rs *ReturnStatement) End() int     { return rs.ReturnValue.End() }
+func (ts *ThrowStatement) Pos() int      { return ts.Token.Offset }
+func (ts *ThrowStatement) End() int      { return ts.Value.End() }
+func (ds *DeferStatement) Pos() int      { return ds.Token.Offset }
+func (ds *DeferStatement) End() int      { return ds.Value.End() }
+func (ws *WhileStatement) Pos() int      { return ws.Token.Offset }
+func (ws *WhileStatement) End() int      { return ws.Body.End() }
+func (fs *ForStatement) Pos() int        { return fs.Token.Offset }
+func (fs *ForStatement) End() int        { return fs.Body.End() }
+func (fs *ForInStatement) Pos() int      { return fs.Token.Offset }
+func (fs *ForInStatement) End() int      { return fs.Body.End() }
+func (bs *BreakStatement) Pos() int      { return bs.Token.Offset }
+func (bs *BreakStatement) End() int      { return bs.Token.Offset + len(bs.Token.Literal) }
+func (cs *ContinueStatement) Pos() int   { return cs.Token.Offset }
+func (cs *ContinueStatement) End() int   { return cs.Token.Offset + len(cs.Token.Literal) }
+func (es *ExpressionStatement) Pos() int { return es.Expression.Pos() }
+func (es *ExpressionStatement) End() int { return es.Expression.End() }
+func (bs *BlockStatement) Pos() int      { return bs.Token.Offset }
+func (bs *BlockStatement) End() int {
+	if bs.Rbrace.Type != "" {
+		return bs.Rbrace.Offset + 1
+	}
+	if len(bs.Statements) > 0 {
+		return bs.Statements[len(bs.Statements)-1].End()
+	}
+	return bs.Token.Offset + len(bs.Token.Literal)
+}
+
+func (i *Identifier) Pos() int          { return i.Token.Offset }
+func (i *Identifier) End() int          { return i.Token.Offset + len(i.Token.Literal) }
+func (il *IntegerLiteral) Pos() int     { return il.Token.Offset }
+func (il *IntegerLiteral) End() int     { return il.Token.Offset + len(il.Token.Literal) }
+func (fl *FloatLiteral) Pos() int       { return fl.Token.Offset }
+func (fl *FloatLiteral) End() int       { return fl.Token.Offset + len(fl.Token.Literal) }
+func (sl *StringLiteral) Pos() int      { return sl.Token.Offset }
+func (sl *StringLiteral) End() int      { return sl.Token.Offset + len(sl.Token.Literal) + 2 } // the quotes
+func (is *InterpolatedString) Pos() int { return is.Token.Offset }
+func (is *InterpolatedString) End() int { return is.Token.Offset + len(is.Token.Literal) + 2 }
+func (b *Boolean) Pos() int             { return b.Token.Offset }
+func (b *Boolean) End() int             { return b.Token.Offset + len(b.Token.Literal) }
+func (n *NullLiteral) Pos() int         { return n.Token.Offset }
+func (n *NullLiteral) End() int         { return n.Token.Offset + len(n.Token.Literal) }
+func (pe *PrefixExpression) Pos() int   { return pe.Token.Offset }
+func (pe *PrefixExpression) End() int   { return pe.Right.End() }
+func (ie *InfixExpression) Pos() int    { return ie.Left.Pos() }
+func (ie *InfixExpression) End() int    { return ie.Right.End() }
+func (re *RangeExpression) Pos() int    { return re.From.Pos() }
+func (re *RangeExpression) End() int    { return re.To.End() }
+func (ie *IfExpression) Pos() int       { return ie.Token.Offset }
+func (ie *IfExpression) End() int {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	return ie.Consequence.End()
+}
+func (fl *FunctionLiteral) Pos() int       { return fl.Token.Offset }
+func (fl *FunctionLiteral) End() int       { return fl.Body.End() }
+func (ml *MacroLiteral) Pos() int          { return ml.Token.Offset }
+func (ml *MacroLiteral) End() int          { return ml.Body.End() }
+func (ce *CallExpression) Pos() int        { return ce.Function.Pos() }
+func (ce *CallExpression) End() int        { return ce.Rparen.Offset + 1 }
+func (na *NamedArgument) Pos() int         { return na.Name.Pos() }
+func (na *NamedArgument) End() int         { return na.Value.End() }
+func (al *ArrayLiteral) Pos() int          { return al.Token.Offset }
+func (al *ArrayLiteral) End() int          { return al.Rbracket.Offset + 1 }
+func (hl *HashLiteral) Pos() int           { return hl.Token.Offset }
+func (hl *HashLiteral) End() int           { return hl.Rbrace.Offset + 1 }
+func (ie *IndexExpression) Pos() int       { return ie.Left.Pos() }
+func (ie *IndexExpression) End() int       { return ie.Rbracket.Offset + 1 }
+func (oe *ObjectExpression) Pos() int      { return oe.Token.Offset }
+func (oe *ObjectExpression) End() int      { return oe.Token.Offset + len(oe.Token.Literal) }
+func (ae *AssignExpression) Pos() int      { return ae.Name.Pos() }
+func (ae *AssignExpression) End() int      { return ae.Value.End() }
+func (ia *IndexAssignExpression) Pos() int { return ia.Target.Pos() }
+func (ia *IndexAssignExpression) End() int { return ia.Value.End() }
+func (se *SliceExpression) Pos() int       { return se.Left.Pos() }
+func (se *SliceExpression) End() int       { return se.Rbracket.Offset + 1 }
+func (me *MatchExpression) Pos() int       { return me.Token.Offset }
+func (me *MatchExpression) End() int       { return me.Rbrace.Offset + 1 }
+func (ma *MatchArm) Pos() int              { return ma.Pattern.Pos() }
+func (ma *MatchArm) End() int              { return ma.Body.End() }
+func (se *SelectExpression) Pos() int      { return se.Token.Offset }
+func (se *SelectExpression) End() int      { return se.Rbrace.Offset + 1 }
+func (sc *SelectCase) Pos() int            { return sc.Token.Offset }
+func (sc *SelectCase) End() int            { return sc.Body.End() }
+func (te *TryExpression) Pos() int         { return te.Token.Offset }
+func (te *TryExpression) End() int         { return te.CatchBlock.End() }
+
+// Programs String method creates a buffer and writes the return value of each statement's String() method to it.
+// The String methods print source the parser reads back into an Equal tree
 func (p *Program) String() string {
 	var out bytes.Buffer
-	for _, s := range p.Statements {
-		out.WriteString(s.String())
-	}
+	writeStatements(&out, p.Statements)
 	return out.String()
 }
 
+// writeStatements separates the statements that don't end in a semicolon from the next one, so
+// `x; (y)` isn't printed as the call `x(y)`
+func writeStatements(out *bytes.Buffer, statements []Statement) {
+	for i, s := range statements {
+		str := s.String()
+		out.WriteString(str)
+		if i < len(statements)-1 && !strings.HasSuffix(str, ";") {
+			out.WriteString(";")
+		}
+	}
+}
+
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
@@ -210,6 +559,8 @@ func (i *Identifier) String() string {
 
 func (il *IntegerLiteral) String() string { return il.Token.Literal }
 
+func (fl *FloatLiteral) String() string { return fl.Token.Literal }
+
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -236,25 +587,32 @@ func (b *Boolean) String() string {
 	return b.Token.Literal
 }
 
+func (n *NullLiteral) String() string {
+	return n.Token.Literal
+}
+
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
-	out.WriteString("if")
+	out.WriteString("if (")
 	out.WriteString(ie.Condition.String())
-	out.WriteString(" ")
+	out.WriteString(") ")
 	out.WriteString(ie.Consequence.String())
 
 	if ie.Alternative != nil {
-		out.WriteString("else")
+		out.WriteString(" else ")
 		out.WriteString(ie.Alternative.String())
 	}
 	return out.String()
 }
 
 func (bs *BlockStatement) String() string {
-	var out bytes.Buffer
-	for _, s := range bs.Statements {
-		out.WriteString(s.String())
+	if len(bs.Statements) == 0 {
+		return "{ }"
 	}
+	var out bytes.Buffer
+	out.WriteString("{ ")
+	writeStatements(&out, bs.Statements)
+	out.WriteString(" }")
 	return out.String()
 }
 
@@ -275,6 +633,23 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 	args := []string{}
@@ -282,6 +657,9 @@ func (ce *CallExpression) String() string {
 	for _, a := range ce.Arguments {
 		args = append(args, a.String())
 	}
+	for _, na := range ce.NamedArguments {
+		args = append(args, na.String())
+	}
 	out.WriteString(ce.Function.String())
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
@@ -290,7 +668,26 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
-func (sl *StringLiteral) String() string { return sl.Token.Literal }
+func (na *NamedArgument) String() string {
+	return na.Name.String() + ": " + na.Value.String()
+}
+
+func (sl *StringLiteral) String() string { return `"` + stringEscaper.Replace(sl.Value) + `"` }
+
+// stringEscaper writes a string back as a literal, undoing lexer.Unescape
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+	out.WriteString(`"`)
+	for i, e := range is.Exprs {
+		out.WriteString(stringEscaper.Replace(is.Strings[i]))
+		out.WriteString("${" + e.String() + "}")
+	}
+	out.WriteString(stringEscaper.Replace(is.Strings[len(is.Exprs)]))
+	out.WriteString(`"`)
+	return out.String()
+}
 
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
@@ -314,13 +711,229 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+func (oe *ObjectExpression) String() string { return oe.Value.Inspect() }
+
+func (ae *AssignExpression) String() string {
+	return "(" + ae.Name.String() + " = " + ae.Value.String() + ")"
+}
+
+func (ia *IndexAssignExpression) String() string {
+	return "(" + ia.Target.Left.String() + "[" + ia.Target.Index.String() + "] = " + ia.Value.String() + ")"
+}
+
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String() + ":" + value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// Get returns the value of the last pair whose key is Equal to key, the one that wins when the
+// literal is evaluated
+func (hl *HashLiteral) Get(key Expression) (Expression, bool) {
+	for i := len(hl.Pairs) - 1; i >= 0; i-- {
+		if Equal(hl.Pairs[i].Key, key) {
+			return hl.Pairs[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+func (re *RangeExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(re.From.String())
+	out.WriteString("..")
+	out.WriteString(re.To.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	out.WriteString("match (")
+	out.WriteString(me.Subject.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+	return out.String()
+}
+
+func (ma *MatchArm) String() string {
+	return ma.Pattern.String() + " => " + ma.Body.String()
+}
+
+func (se *SelectExpression) String() string {
+	if len(se.Cases) == 0 {
+		return "select { }"
+	}
+	cases := []string{}
+	for _, c := range se.Cases {
+		cases = append(cases, c.String())
+	}
+	return "select { " + strings.Join(cases, " ") + " }"
+}
+
+// String ends the statements of the body in a semicolon, so the next case can't be read as a
+// call or an index of the last one
+func (sc *SelectCase) String() string {
+	var out bytes.Buffer
+	if sc.Channel == nil {
+		out.WriteString("default:")
+	} else {
+		out.WriteString("case ")
+		if sc.Name != nil {
+			out.WriteString(sc.Name.String() + " = ")
+		}
+		out.WriteString(sc.Op.Literal + "(" + sc.Channel.String())
+		if sc.Value != nil {
+			out.WriteString(", " + sc.Value.String())
+		}
+		out.WriteString("):")
+	}
+	for _, s := range sc.Body.Statements {
+		str := s.String()
+		out.WriteString(" " + str)
+		if !strings.HasSuffix(str, ";") {
+			out.WriteString(";")
+		}
+	}
 	return out.String()
-}
\ No newline at end of file
+}
+
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+func (ds *DeferStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(ds.TokenLiteral() + " ")
+	if ds.Value != nil {
+		out.WriteString(ds.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+func (ws *WhileStatement) String() string {
+	return "while (" + ws.Condition.String() + ") " + ws.Body.String()
+}
+
+func (bs *BreakStatement) String() string    { return "break;" }
+func (cs *ContinueStatement) String() string { return "continue;" }
+
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	if fs.Init != nil {
+		out.WriteString(strings.TrimSuffix(fs.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.String())
+	}
+	out.WriteString("; ")
+	if fs.Post != nil {
+		out.WriteString(strings.TrimSuffix(fs.Post.String(), ";"))
+	}
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+
+func (fs *ForInStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String() + ", ")
+	}
+	out.WriteString(fs.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+	out.WriteString(" catch (")
+	out.WriteString(te.CatchParam.String())
+	out.WriteString(") ")
+	out.WriteString(te.CatchBlock.String())
+	return out.String()
+}
+
+// Comment is a single `// ...` comment. Comments aren't part of the tree built by the parser, they
+// are attached to statements on the side, see parser.CommentMap
+type Comment struct {
+	Token token.Token // the token.COMMENT token
+	Text  string      // the comment, including the leading "//"
+}
+
+func (c *Comment) TokenLiteral() string { return c.Token.Literal }
+func (c *Comment) String() string       { return c.Text }
+func (c *Comment) Pos() int             { return c.Token.Offset }
+func (c *Comment) End() int             { return c.Token.Offset + len(c.Text) }
+
+// CommentGroup is a run of comments with no other tokens in between
+type CommentGroup struct {
+	List []*Comment // at least one
+}
+
+func (g *CommentGroup) TokenLiteral() string { return g.List[0].TokenLiteral() }
+func (g *CommentGroup) Pos() int             { return g.List[0].Pos() }
+func (g *CommentGroup) End() int             { return g.List[len(g.List)-1].End() }
+
+// String gives the comments as they were written, one per line
+func (g *CommentGroup) String() string {
+	lines := []string{}
+	for _, c := range g.List {
+		lines = append(lines, c.Text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Text gives the text of the comments without their "//" markers and the space that usually
+// follows them, one line per comment
+func (g *CommentGroup) Text() string {
+	lines := []string{}
+	for _, c := range g.List {
+		line := strings.TrimPrefix(c.Text, "//")
+		lines = append(lines, strings.TrimPrefix(line, " "))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -25,3 +25,50 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestLoopStrings(t *testing.T) {
+	body := func(stmts ...Statement) *BlockStatement { return &BlockStatement{Statements: stmts} }
+	less := &InfixExpression{Left: ident("i"), Operator: "<", Right: integer(10)}
+
+	tests := []struct {
+		stmt     Statement
+		expected string
+	}{
+		{&WhileStatement{Condition: less, Body: body(&ExpressionStatement{Expression: ident("i")})},
+			"while ((i < 10)) { i }"},
+		{&ForStatement{
+			Init:      &LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: ident("i"), Value: integer(0)},
+			Condition: less,
+			Post:      &ExpressionStatement{Expression: ident("next")},
+			Body:      body(),
+		}, "for (let i = 0; (i < 10); next) { }"},
+		{&ForStatement{Body: body()}, "for (; ; ) { }"},
+		{&ForInStatement{Value: ident("x"), Iterable: ident("xs"), Body: body()}, "for (x in xs) { }"},
+		{&ForInStatement{Key: ident("k"), Value: ident("v"), Iterable: ident("h"), Body: body()}, "for (k, v in h) { }"},
+	}
+
+	for _, tt := range tests {
+		if tt.stmt.String() != tt.expected {
+			t.Errorf("String() wrong. expected=%q, got=%q", tt.expected, tt.stmt.String())
+		}
+	}
+}
+
+func TestAssignStrings(t *testing.T) {
+	target := &IndexExpression{Left: ident("arr"), Index: integer(0)}
+	tests := []struct {
+		exp      Expression
+		expected string
+	}{
+		{&AssignExpression{Name: ident("x"), Value: integer(5)}, "(x = 5)"},
+		{&AssignExpression{Name: ident("x"), Value: &AssignExpression{Name: ident("y"), Value: integer(5)}}, "(x = (y = 5))"},
+		{&IndexAssignExpression{Target: target, Value: &InfixExpression{Left: ident("x"), Operator: "+", Right: integer(1)}},
+			"(arr[0] = (x + 1))"},
+	}
+
+	for _, tt := range tests {
+		if tt.exp.String() != tt.expected {
+			t.Errorf("String() wrong. expected=%q, got=%q", tt.expected, tt.exp.String())
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ToDot renders the tree under node as a Graphviz digraph, one vertex per node labeled with its
+// type and, for operators, identifiers and literals, the operator or value. Edges go from parents
+// to their children, left to right in source order
+func ToDot(node Node) string {
+	var out bytes.Buffer
+	out.WriteString("digraph ast {\n")
+	out.WriteString("\tnode [shape=box];\n")
+
+	next := 0
+	parents := []int{} // the ids of the nodes being walked
+	Inspect(node, func(n Node) bool {
+		if n == nil {
+			parents = parents[:len(parents)-1]
+			return false
+		}
+		id := next
+		next++
+		out.WriteString(fmt.Sprintf("\tn%d [label=%q];\n", id, dotLabel(n)))
+		if len(parents) > 0 {
+			out.WriteString(fmt.Sprintf("\tn%d -> n%d;\n", parents[len(parents)-1], id))
+		}
+		parents = append(parents, id)
+		return true
+	})
+
+	out.WriteString("}\n")
+	return out.String()
+}
+
+func dotLabel(n Node) string {
+	name := n.Kind().String()
+	switch n := n.(type) {
+	case *LetStatement:
+		if n.Const {
+			return name + "\nconst"
+		}
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean, *ObjectExpression:
+		return name + "\n" + n.String()
+	case *PrefixExpression:
+		return name + "\n" + n.Operator
+	case *InfixExpression:
+		return name + "\n" + n.Operator
+	}
+	return name
+}
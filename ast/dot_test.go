@@ -0,0 +1,35 @@
+package ast
+
+import "testing"
+
+func TestToDot(t *testing.T) {
+	// const y = -x + 1;
+	program := &Program{Statements: []Statement{
+		&LetStatement{Const: true, Name: ident("y"), Value: &InfixExpression{
+			Left:     &PrefixExpression{Operator: "-", Right: ident("x")},
+			Operator: "+",
+			Right:    integer(1),
+		}},
+	}}
+
+	expected := `digraph ast {
+	node [shape=box];
+	n0 [label="Program"];
+	n1 [label="LetStatement\nconst"];
+	n0 -> n1;
+	n2 [label="Identifier\ny"];
+	n1 -> n2;
+	n3 [label="InfixExpression\n+"];
+	n1 -> n3;
+	n4 [label="PrefixExpression\n-"];
+	n3 -> n4;
+	n5 [label="Identifier\nx"];
+	n4 -> n5;
+	n6 [label="IntegerLiteral\n1"];
+	n3 -> n6;
+}
+`
+	if got := ToDot(program); got != expected {
+		t.Errorf("ToDot wrong. expected=\n%s\ngot=\n%s", expected, got)
+	}
+}
@@ -0,0 +1,227 @@
+package ast
+
+import "reflect"
+
+// Equal reports whether a and b are the same tree: the same node types with the same values,
+// operators and children. Tokens aren't compared, so neither are positions or literal spellings
+// (`05` and `5` are equal IntegerLiterals), nor the delimiters recorded for End
+func Equal(a, b Node) bool {
+	if isNil(a) || isNil(b) {
+		return isNil(a) && isNil(b)
+	}
+
+	switch a := a.(type) {
+	case *Program:
+		b, ok := b.(*Program)
+		return ok && statementsEqual(a.Statements, b.Statements)
+
+	// Statements
+	case *LetStatement:
+		b, ok := b.(*LetStatement)
+		return ok && a.Const == b.Const && Equal(a.Name, b.Name) && Equal(a.Value, b.Value)
+	case *ReturnStatement:
+		b, ok := b.(*ReturnStatement)
+		return ok && Equal(a.ReturnValue, b.ReturnValue)
+	case *ThrowStatement:
+		b, ok := b.(*ThrowStatement)
+		return ok && Equal(a.Value, b.Value)
+	case *DeferStatement:
+		b, ok := b.(*DeferStatement)
+		return ok && Equal(a.Value, b.Value)
+	case *ExpressionStatement:
+		b, ok := b.(*ExpressionStatement)
+		return ok && Equal(a.Expression, b.Expression)
+	case *BlockStatement:
+		b, ok := b.(*BlockStatement)
+		return ok && statementsEqual(a.Statements, b.Statements)
+	case *WhileStatement:
+		b, ok := b.(*WhileStatement)
+		return ok && Equal(a.Condition, b.Condition) && Equal(a.Body, b.Body)
+	case *BreakStatement:
+		_, ok := b.(*BreakStatement)
+		return ok
+	case *ContinueStatement:
+		_, ok := b.(*ContinueStatement)
+		return ok
+	case *ForStatement:
+		b, ok := b.(*ForStatement)
+		return ok && Equal(a.Init, b.Init) && Equal(a.Condition, b.Condition) && Equal(a.Post, b.Post) &&
+			Equal(a.Body, b.Body)
+	case *ForInStatement:
+		b, ok := b.(*ForInStatement)
+		return ok && Equal(a.Key, b.Key) && Equal(a.Value, b.Value) && Equal(a.Iterable, b.Iterable) &&
+			Equal(a.Body, b.Body)
+
+	// Expressions
+	case *Identifier:
+		b, ok := b.(*Identifier)
+		return ok && a.Value == b.Value
+	case *IntegerLiteral:
+		b, ok := b.(*IntegerLiteral)
+		return ok && a.Value == b.Value
+	case *FloatLiteral:
+		b, ok := b.(*FloatLiteral)
+		return ok && a.Value == b.Value
+	case *StringLiteral:
+		b, ok := b.(*StringLiteral)
+		return ok && a.Value == b.Value
+	case *InterpolatedString:
+		b, ok := b.(*InterpolatedString)
+		if !ok || len(a.Exprs) != len(b.Exprs) {
+			return false
+		}
+		for i := range a.Exprs {
+			if a.Strings[i] != b.Strings[i] || !Equal(a.Exprs[i], b.Exprs[i]) {
+				return false
+			}
+		}
+		return a.Strings[len(a.Exprs)] == b.Strings[len(b.Exprs)]
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *NullLiteral:
+		_, ok := b.(*NullLiteral)
+		return ok
+	case *ObjectExpression:
+		b, ok := b.(*ObjectExpression)
+		return ok && a.Value == b.Value // the same object
+	case *PrefixExpression:
+		b, ok := b.(*PrefixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Right, b.Right)
+	case *InfixExpression:
+		b, ok := b.(*InfixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Left, b.Left) && Equal(a.Right, b.Right)
+	case *RangeExpression:
+		b, ok := b.(*RangeExpression)
+		return ok && Equal(a.From, b.From) && Equal(a.To, b.To)
+	case *IfExpression:
+		b, ok := b.(*IfExpression)
+		return ok && Equal(a.Condition, b.Condition) && Equal(a.Consequence, b.Consequence) &&
+			Equal(a.Alternative, b.Alternative)
+	case *FunctionLiteral:
+		b, ok := b.(*FunctionLiteral)
+		return ok && identifiersEqual(a.Parameters, b.Parameters) && Equal(a.Body, b.Body)
+	case *MacroLiteral:
+		b, ok := b.(*MacroLiteral)
+		return ok && identifiersEqual(a.Parameters, b.Parameters) && Equal(a.Body, b.Body)
+	case *CallExpression:
+		b, ok := b.(*CallExpression)
+		if !ok || !Equal(a.Function, b.Function) || !expressionsEqual(a.Arguments, b.Arguments) ||
+			len(a.NamedArguments) != len(b.NamedArguments) {
+			return false
+		}
+		for i := range a.NamedArguments {
+			if !Equal(a.NamedArguments[i], b.NamedArguments[i]) {
+				return false
+			}
+		}
+		return true
+	case *NamedArgument:
+		b, ok := b.(*NamedArgument)
+		return ok && Equal(a.Name, b.Name) && Equal(a.Value, b.Value)
+	case *ArrayLiteral:
+		b, ok := b.(*ArrayLiteral)
+		return ok && expressionsEqual(a.Elements, b.Elements)
+	case *HashLiteral:
+		b, ok := b.(*HashLiteral)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		for i := range a.Pairs {
+			if !Equal(a.Pairs[i].Key, b.Pairs[i].Key) || !Equal(a.Pairs[i].Value, b.Pairs[i].Value) {
+				return false
+			}
+		}
+		return true
+	case *IndexExpression:
+		b, ok := b.(*IndexExpression)
+		return ok && Equal(a.Left, b.Left) && Equal(a.Index, b.Index)
+	case *AssignExpression:
+		b, ok := b.(*AssignExpression)
+		return ok && Equal(a.Name, b.Name) && Equal(a.Value, b.Value)
+	case *IndexAssignExpression:
+		b, ok := b.(*IndexAssignExpression)
+		return ok && Equal(a.Target, b.Target) && Equal(a.Value, b.Value)
+	case *SliceExpression:
+		b, ok := b.(*SliceExpression)
+		return ok && Equal(a.Left, b.Left) && Equal(a.Low, b.Low) && Equal(a.High, b.High)
+	case *MatchExpression:
+		b, ok := b.(*MatchExpression)
+		if !ok || !Equal(a.Subject, b.Subject) || len(a.Arms) != len(b.Arms) {
+			return false
+		}
+		for i := range a.Arms {
+			if !Equal(a.Arms[i], b.Arms[i]) {
+				return false
+			}
+		}
+		return true
+	case *MatchArm:
+		b, ok := b.(*MatchArm)
+		return ok && Equal(a.Pattern, b.Pattern) && Equal(a.Body, b.Body)
+	case *TryExpression:
+		b, ok := b.(*TryExpression)
+		return ok && Equal(a.Block, b.Block) && Equal(a.CatchParam, b.CatchParam) && Equal(a.CatchBlock, b.CatchBlock)
+	case *SelectExpression:
+		b, ok := b.(*SelectExpression)
+		if !ok || len(a.Cases) != len(b.Cases) {
+			return false
+		}
+		for i := range a.Cases {
+			if !Equal(a.Cases[i], b.Cases[i]) {
+				return false
+			}
+		}
+		return true
+	case *SelectCase:
+		// recv and receive are spellings of the same thing, and a send is told apart by its Value
+		b, ok := b.(*SelectCase)
+		return ok && Equal(a.Name, b.Name) && Equal(a.Channel, b.Channel) && Equal(a.Value, b.Value) && Equal(a.Body, b.Body)
+	}
+	return false
+}
+
+// isNil also catches nil pointers stored in a Node, eg a missing IfExpression.Alternative
+func isNil(n Node) bool {
+	if n == nil {
+		return true
+	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+func statementsEqual(a, b []Statement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func expressionsEqual(a, b []Expression) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func identifiersEqual(a, b []*Identifier) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package ast
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	str := func(value string) *StringLiteral {
+		return &StringLiteral{Token: token.Token{Type: token.STRING, Literal: value}, Value: value}
+	}
+	hash := func(pairs ...Expression) *HashLiteral {
+		h := &HashLiteral{}
+		for i := 0; i < len(pairs); i += 2 {
+			h.Pairs = append(h.Pairs, &HashPair{Key: pairs[i], Value: pairs[i+1]})
+		}
+		return h
+	}
+	ifExp := func(alternative *BlockStatement) *IfExpression {
+		return &IfExpression{Condition: ident("x"), Consequence: &BlockStatement{}, Alternative: alternative}
+	}
+	selectCase := func(op string, value Expression) *SelectCase {
+		return &SelectCase{Op: token.Token{Type: token.IDENT, Literal: op}, Channel: ident("c"), Value: value, Body: &BlockStatement{}}
+	}
+	moved := ident("x")
+	moved.Token.Offset, moved.Token.Line = 42, 3
+	octal := integer(5)
+	octal.Token.Literal = "05"
+
+	tests := []struct {
+		a, b     Node
+		expected bool
+	}{
+		{ident("x"), ident("x"), true},
+		{ident("x"), moved, true},
+		{integer(5), octal, true},
+		{ident("x"), ident("y"), false},
+		{ident("x"), str("x"), false}, // both print as x
+		{&InfixExpression{Left: ident("a"), Operator: "+", Right: integer(1)}, &InfixExpression{Left: ident("a"), Operator: "+", Right: integer(1)}, true},
+		{&InfixExpression{Left: ident("a"), Operator: "+", Right: integer(1)}, &InfixExpression{Left: ident("a"), Operator: "-", Right: integer(1)}, false},
+		{&LetStatement{Name: ident("x"), Value: integer(1)}, &LetStatement{Name: ident("x"), Value: integer(1), Const: true}, false},
+		{ifExp(nil), ifExp(nil), true},
+		{ifExp(nil), ifExp(&BlockStatement{}), false},
+		{hash(str("a"), integer(1), str("b"), integer(2)), hash(str("a"), integer(1), str("b"), integer(2)), true},
+		{hash(str("a"), integer(1), str("b"), integer(2)), hash(str("b"), integer(2), str("a"), integer(1)), false},
+		{hash(str("a"), integer(1), str("b"), integer(2)), hash(str("a"), integer(2), str("b"), integer(1)), false},
+		{hash(str("a"), integer(1), str("a"), integer(1)), hash(str("a"), integer(1), str("b"), integer(1)), false},
+		{&SliceExpression{Left: ident("a"), Low: integer(1)}, &SliceExpression{Left: ident("a"), High: integer(1)}, false},
+		{&CallExpression{Function: ident("f"), NamedArguments: []*NamedArgument{{Name: ident("a"), Value: integer(1)}}},
+			&CallExpression{Function: ident("f"), Arguments: []Expression{integer(1)}}, false},
+		{selectCase("recv", nil), selectCase("receive", nil), true},
+		{selectCase("recv", nil), selectCase("send", integer(1)), false},
+		{&SelectExpression{Cases: []*SelectCase{selectCase("recv", nil)}}, &SelectExpression{}, false},
+		{walkTestProgram(), walkTestProgram(), true},
+		{nil, nil, true},
+		{ident("x"), nil, false},
+	}
+
+	for i, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("tests[%d]: Equal(%v, %v) wrong. want=%t, got=%t", i, tt.a, tt.b, tt.expected, got)
+		}
+		if got := Equal(tt.b, tt.a); got != tt.expected {
+			t.Errorf("tests[%d]: Equal is not symmetric", i)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package ast
+
+// NodeKind identifies the concrete type of a Node, so tools can switch on an enum or index a table
+// instead of type-asserting over every node struct
+type NodeKind int
+
+const (
+	BadKind NodeKind = iota // the zero value, no node has it
+	ProgramKind
+
+	// Statements
+	LetStatementKind
+	ReturnStatementKind
+	ThrowStatementKind
+	DeferStatementKind
+	ExpressionStatementKind
+	BlockStatementKind
+	WhileStatementKind
+	ForStatementKind
+	ForInStatementKind
+	BreakStatementKind
+	ContinueStatementKind
+
+	// Expressions
+	IdentifierKind
+	IntegerLiteralKind
+	FloatLiteralKind
+	StringLiteralKind
+	InterpolatedStringKind
+	BooleanKind
+	NullLiteralKind
+	PrefixExprKind
+	InfixExprKind
+	RangeExprKind
+	IfExprKind
+	FunctionLiteralKind
+	MacroLiteralKind
+	CallExprKind
+	ArrayLiteralKind
+	HashLiteralKind
+	IndexExprKind
+	ObjectExprKind
+	AssignExprKind
+	IndexAssignExprKind
+	SliceExprKind
+	MatchExprKind
+	TryExprKind
+	SelectExprKind
+
+	// Parts of other nodes
+	NamedArgumentKind
+	MatchArmKind
+	SelectCaseKind
+	CommentKind
+	CommentGroupKind
+
+	NumKinds // the number of kinds, for sizing tables indexed by NodeKind
+)
+
+var kindNames = [...]string{
+	BadKind:                 "Bad",
+	ProgramKind:             "Program",
+	LetStatementKind:        "LetStatement",
+	ReturnStatementKind:     "ReturnStatement",
+	ThrowStatementKind:      "ThrowStatement",
+	DeferStatementKind:      "DeferStatement",
+	ExpressionStatementKind: "ExpressionStatement",
+	BlockStatementKind:      "BlockStatement",
+	WhileStatementKind:      "WhileStatement",
+	ForStatementKind:        "ForStatement",
+	ForInStatementKind:      "ForInStatement",
+	BreakStatementKind:      "BreakStatement",
+	ContinueStatementKind:   "ContinueStatement",
+	IdentifierKind:          "Identifier",
+	IntegerLiteralKind:      "IntegerLiteral",
+	FloatLiteralKind:        "FloatLiteral",
+	StringLiteralKind:       "StringLiteral",
+	InterpolatedStringKind:  "InterpolatedString",
+	BooleanKind:             "Boolean",
+	NullLiteralKind:         "NullLiteral",
+	PrefixExprKind:          "PrefixExpression",
+	InfixExprKind:           "InfixExpression",
+	RangeExprKind:           "RangeExpression",
+	IfExprKind:              "IfExpression",
+	FunctionLiteralKind:     "FunctionLiteral",
+	MacroLiteralKind:        "MacroLiteral",
+	CallExprKind:            "CallExpression",
+	ArrayLiteralKind:        "ArrayLiteral",
+	HashLiteralKind:         "HashLiteral",
+	IndexExprKind:           "IndexExpression",
+	ObjectExprKind:          "ObjectExpression",
+	AssignExprKind:          "AssignExpression",
+	IndexAssignExprKind:     "IndexAssignExpression",
+	SliceExprKind:           "SliceExpression",
+	MatchExprKind:           "MatchExpression",
+	TryExprKind:             "TryExpression",
+	SelectExprKind:          "SelectExpression",
+	NamedArgumentKind:       "NamedArgument",
+	MatchArmKind:            "MatchArm",
+	SelectCaseKind:          "SelectCase",
+	CommentKind:             "Comment",
+	CommentGroupKind:        "CommentGroup",
+}
+
+// String gives the name of the node type of the kind, eg "InfixExpression" for InfixExprKind
+func (k NodeKind) String() string {
+	if k < 0 || k >= NumKinds {
+		return "Bad"
+	}
+	return kindNames[k]
+}
+
+func (p *Program) Kind() NodeKind { return ProgramKind }
+
+func (ls *LetStatement) Kind() NodeKind        { return LetStatementKind }
+func (rs *ReturnStatement) Kind() NodeKind     { return ReturnStatementKind }
+func (ts *ThrowStatement) Kind() NodeKind      { return ThrowStatementKind }
+func (ds *DeferStatement) Kind() NodeKind      { return DeferStatementKind }
+func (es *ExpressionStatement) Kind() NodeKind { return ExpressionStatementKind }
+func (bs *BlockStatement) Kind() NodeKind      { return BlockStatementKind }
+func (ws *WhileStatement) Kind() NodeKind      { return WhileStatementKind }
+func (fs *ForStatement) Kind() NodeKind        { return ForStatementKind }
+func (fs *ForInStatement) Kind() NodeKind      { return ForInStatementKind }
+func (bs *BreakStatement) Kind() NodeKind      { return BreakStatementKind }
+func (cs *ContinueStatement) Kind() NodeKind   { return ContinueStatementKind }
+
+func (i *Identifier) Kind() NodeKind             { return IdentifierKind }
+func (il *IntegerLiteral) Kind() NodeKind        { return IntegerLiteralKind }
+func (fl *FloatLiteral) Kind() NodeKind          { return FloatLiteralKind }
+func (sl *StringLiteral) Kind() NodeKind         { return StringLiteralKind }
+func (is *InterpolatedString) Kind() NodeKind    { return InterpolatedStringKind }
+func (b *Boolean) Kind() NodeKind                { return BooleanKind }
+func (n *NullLiteral) Kind() NodeKind            { return NullLiteralKind }
+func (pe *PrefixExpression) Kind() NodeKind      { return PrefixExprKind }
+func (ie *InfixExpression) Kind() NodeKind       { return InfixExprKind }
+func (re *RangeExpression) Kind() NodeKind       { return RangeExprKind }
+func (ie *IfExpression) Kind() NodeKind          { return IfExprKind }
+func (fl *FunctionLiteral) Kind() NodeKind       { return FunctionLiteralKind }
+func (ml *MacroLiteral) Kind() NodeKind          { return MacroLiteralKind }
+func (ce *CallExpression) Kind() NodeKind        { return CallExprKind }
+func (al *ArrayLiteral) Kind() NodeKind          { return ArrayLiteralKind }
+func (hl *HashLiteral) Kind() NodeKind           { return HashLiteralKind }
+func (ie *IndexExpression) Kind() NodeKind       { return IndexExprKind }
+func (oe *ObjectExpression) Kind() NodeKind      { return ObjectExprKind }
+func (ae *AssignExpression) Kind() NodeKind      { return AssignExprKind }
+func (ia *IndexAssignExpression) Kind() NodeKind { return IndexAssignExprKind }
+func (se *SliceExpression) Kind() NodeKind       { return SliceExprKind }
+func (me *MatchExpression) Kind() NodeKind       { return MatchExprKind }
+func (te *TryExpression) Kind() NodeKind         { return TryExprKind }
+func (se *SelectExpression) Kind() NodeKind      { return SelectExprKind }
+
+func (na *NamedArgument) Kind() NodeKind { return NamedArgumentKind }
+func (ma *MatchArm) Kind() NodeKind      { return MatchArmKind }
+func (sc *SelectCase) Kind() NodeKind    { return SelectCaseKind }
+func (c *Comment) Kind() NodeKind        { return CommentKind }
+func (g *CommentGroup) Kind() NodeKind   { return CommentGroupKind }
@@ -0,0 +1,29 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestKind(t *testing.T) {
+	Inspect(walkTestProgram(), func(n Node) bool {
+		if n == nil {
+			return false
+		}
+		name := strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")
+		if n.Kind().String() != name {
+			t.Errorf("%s has the wrong kind. got=%s", name, n.Kind())
+		}
+		return true
+	})
+
+	for k := BadKind; k < NumKinds; k++ {
+		if kindNames[k] == "" {
+			t.Errorf("kind %d has no name", k)
+		}
+	}
+	if NumKinds.String() != "Bad" {
+		t.Errorf("NumKinds.String() wrong. got=%q", NumKinds.String())
+	}
+}
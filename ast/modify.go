@@ -0,0 +1,175 @@
+package ast
+
+type ModifierFunc func(Node) Node
+
+// Modify walks node bottom-up: every child is replaced by the result of modifying it, then the
+// modifier is called on node itself and its result returned. The tree is changed in place. A child
+// has to be replaced by a node that fits in its place (an expression by an expression, a block by
+// a block), anything else leaves the child as it was
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+
+	case *Program:
+		for i := range node.Statements {
+			node.Statements[i] = modifyStatement(node.Statements[i], modifier)
+		}
+
+	// Statements
+	case *LetStatement:
+		node.Value = modifyExpression(node.Value, modifier)
+	case *ReturnStatement:
+		node.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+	case *ThrowStatement:
+		node.Value = modifyExpression(node.Value, modifier)
+	case *DeferStatement:
+		node.Value = modifyExpression(node.Value, modifier)
+	case *ExpressionStatement:
+		node.Expression = modifyExpression(node.Expression, modifier)
+	case *BlockStatement:
+		for i := range node.Statements {
+			node.Statements[i] = modifyStatement(node.Statements[i], modifier)
+		}
+	case *WhileStatement:
+		node.Condition = modifyExpression(node.Condition, modifier)
+		node.Body = modifyBlock(node.Body, modifier)
+	case *ForStatement:
+		if node.Init != nil {
+			node.Init = modifyStatement(node.Init, modifier)
+		}
+		if node.Condition != nil {
+			node.Condition = modifyExpression(node.Condition, modifier)
+		}
+		if node.Post != nil {
+			node.Post = modifyStatement(node.Post, modifier)
+		}
+		node.Body = modifyBlock(node.Body, modifier)
+	case *ForInStatement:
+		if node.Key != nil {
+			node.Key = modifyIdentifier(node.Key, modifier)
+		}
+		node.Value = modifyIdentifier(node.Value, modifier)
+		node.Iterable = modifyExpression(node.Iterable, modifier)
+		node.Body = modifyBlock(node.Body, modifier)
+
+	// Expressions
+	case *PrefixExpression:
+		node.Right = modifyExpression(node.Right, modifier)
+	case *InfixExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Right = modifyExpression(node.Right, modifier)
+	case *RangeExpression:
+		node.From = modifyExpression(node.From, modifier)
+		node.To = modifyExpression(node.To, modifier)
+	case *IfExpression:
+		node.Condition = modifyExpression(node.Condition, modifier)
+		node.Consequence = modifyBlock(node.Consequence, modifier)
+		if node.Alternative != nil {
+			node.Alternative = modifyBlock(node.Alternative, modifier)
+		}
+	case *FunctionLiteral:
+		for i := range node.Parameters {
+			node.Parameters[i] = modifyIdentifier(node.Parameters[i], modifier)
+		}
+		node.Body = modifyBlock(node.Body, modifier)
+	case *MacroLiteral:
+		for i := range node.Parameters {
+			node.Parameters[i] = modifyIdentifier(node.Parameters[i], modifier)
+		}
+		node.Body = modifyBlock(node.Body, modifier)
+	case *CallExpression:
+		node.Function = modifyExpression(node.Function, modifier)
+		for i := range node.Arguments {
+			node.Arguments[i] = modifyExpression(node.Arguments[i], modifier)
+		}
+		for _, arg := range node.NamedArguments {
+			arg.Value = modifyExpression(arg.Value, modifier)
+		}
+	case *ArrayLiteral:
+		for i := range node.Elements {
+			node.Elements[i] = modifyExpression(node.Elements[i], modifier)
+		}
+	case *HashLiteral:
+		for _, pair := range node.Pairs {
+			pair.Key = modifyExpression(pair.Key, modifier)
+			pair.Value = modifyExpression(pair.Value, modifier)
+		}
+	case *IndexExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Index = modifyExpression(node.Index, modifier)
+	case *AssignExpression:
+		node.Name = modifyIdentifier(node.Name, modifier)
+		node.Value = modifyExpression(node.Value, modifier)
+	case *IndexAssignExpression:
+		if target, ok := Modify(node.Target, modifier).(*IndexExpression); ok {
+			node.Target = target
+		}
+		node.Value = modifyExpression(node.Value, modifier)
+	case *InterpolatedString:
+		for i, e := range node.Exprs {
+			node.Exprs[i] = modifyExpression(e, modifier)
+		}
+	case *SliceExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		if node.Low != nil {
+			node.Low = modifyExpression(node.Low, modifier)
+		}
+		if node.High != nil {
+			node.High = modifyExpression(node.High, modifier)
+		}
+	case *MatchExpression:
+		node.Subject = modifyExpression(node.Subject, modifier)
+		for _, arm := range node.Arms {
+			arm.Pattern = modifyExpression(arm.Pattern, modifier)
+			arm.Body = modifyExpression(arm.Body, modifier)
+		}
+	case *TryExpression:
+		node.Block = modifyBlock(node.Block, modifier)
+		node.CatchParam = modifyIdentifier(node.CatchParam, modifier)
+		node.CatchBlock = modifyBlock(node.CatchBlock, modifier)
+	case *SelectExpression:
+		for _, c := range node.Cases {
+			if c.Name != nil {
+				c.Name = modifyIdentifier(c.Name, modifier)
+			}
+			if c.Channel != nil {
+				c.Channel = modifyExpression(c.Channel, modifier)
+			}
+			if c.Value != nil {
+				c.Value = modifyExpression(c.Value, modifier)
+			}
+			c.Body = modifyBlock(c.Body, modifier)
+		}
+	}
+
+	return modifier(node)
+}
+
+// the helpers below keep the original child when the modifier returns something of the wrong kind
+
+func modifyStatement(stmt Statement, modifier ModifierFunc) Statement {
+	if modified, ok := Modify(stmt, modifier).(Statement); ok {
+		return modified
+	}
+	return stmt
+}
+
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if modified, ok := Modify(exp, modifier).(Expression); ok {
+		return modified
+	}
+	return exp
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if modified, ok := Modify(block, modifier).(*BlockStatement); ok {
+		return modified
+	}
+	return block
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if modified, ok := Modify(ident, modifier).(*Identifier); ok {
+		return modified
+	}
+	return ident
+}
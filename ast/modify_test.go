@@ -0,0 +1,112 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+		if integer.Value != 1 {
+			return node
+		}
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{&InfixExpression{Left: one(), Operator: "+", Right: two()}, &InfixExpression{Left: two(), Operator: "+", Right: two()}},
+		{&InfixExpression{Left: two(), Operator: "+", Right: one()}, &InfixExpression{Left: two(), Operator: "+", Right: two()}},
+		{&PrefixExpression{Operator: "-", Right: one()}, &PrefixExpression{Operator: "-", Right: two()}},
+		{&IndexExpression{Left: one(), Index: one()}, &IndexExpression{Left: two(), Index: two()}},
+		{
+			&IfExpression{
+				Condition:   one(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&IfExpression{
+				Condition:   two(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{&ReturnStatement{ReturnValue: one()}, &ReturnStatement{ReturnValue: two()}},
+		{&LetStatement{Value: one()}, &LetStatement{Value: two()}},
+		{
+			&FunctionLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+			&FunctionLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+		},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, &ArrayLiteral{Elements: []Expression{two(), two()}}},
+		{
+			&CallExpression{Function: one(), Arguments: []Expression{one()}, NamedArguments: []*NamedArgument{{Name: ident("a"), Value: one()}}},
+			&CallExpression{Function: two(), Arguments: []Expression{two()}, NamedArguments: []*NamedArgument{{Name: ident("a"), Value: two()}}},
+		},
+		{&SliceExpression{Left: one(), High: one()}, &SliceExpression{Left: two(), High: two()}},
+		{&RangeExpression{From: one(), To: one()}, &RangeExpression{From: two(), To: two()}},
+		{
+			&MatchExpression{Subject: one(), Arms: []*MatchArm{{Pattern: one(), Body: one()}}},
+			&MatchExpression{Subject: two(), Arms: []*MatchArm{{Pattern: two(), Body: two()}}},
+		},
+		{
+			&SelectExpression{Cases: []*SelectCase{
+				{Channel: one(), Value: one(), Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+				{Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+			}},
+			&SelectExpression{Cases: []*SelectCase{
+				{Channel: two(), Value: two(), Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+				{Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
+		}
+	}
+
+	hashLiteral := &HashLiteral{Pairs: []*HashPair{{Key: one(), Value: one()}, {Key: one(), Value: one()}}}
+	Modify(hashLiteral, turnOneIntoTwo)
+	for _, pair := range hashLiteral.Pairs {
+		key, _ := pair.Key.(*IntegerLiteral)
+		if key.Value != 2 {
+			t.Errorf("value is not %d, got=%d", 2, key.Value)
+		}
+		val, _ := pair.Value.(*IntegerLiteral)
+		if val.Value != 2 {
+			t.Errorf("value is not %d, got=%d", 2, val.Value)
+		}
+	}
+}
+
+func TestModifyKeepsMisfits(t *testing.T) {
+	// replacing every integer with a statement can't work, the integers stay where they are
+	toStatement := func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok {
+			return &ExpressionStatement{Expression: integer}
+		}
+		return node
+	}
+	infix := &InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)}
+	Modify(infix, toStatement)
+	if _, ok := infix.Left.(*IntegerLiteral); !ok {
+		t.Errorf("infix.Left was replaced by a %T", infix.Left)
+	}
+}
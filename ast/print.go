@@ -0,0 +1,380 @@
+package ast
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// PrintOptions control the layout of Fprint
+type PrintOptions struct {
+	Indent string // one level of indentation, four spaces if empty
+}
+
+// Fprint writes node to w as formatted Monkey source: one statement per line, blocks indented and
+// only the parentheses the precedence of the operators requires. Statements end in a semicolon,
+// except for the last expression of a block, which gives the block its value. opts may be nil
+func Fprint(w io.Writer, node Node, opts *PrintOptions) error {
+	p := &printer{indent: "    "}
+	if opts != nil && opts.Indent != "" {
+		p.indent = opts.Indent
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, s := range node.Statements {
+			p.statement(s, false)
+			p.newline()
+		}
+	case Statement:
+		p.statement(node, false)
+		p.newline()
+	case Expression:
+		p.expression(node)
+		p.newline()
+	}
+
+	_, err := w.Write(p.out.Bytes())
+	return err
+}
+
+// operator precedences, as the parser has them. Operators it doesn't know are always put in
+// parentheses, both as operands and around theirs
+const (
+	precUnknown = iota
+	precOr
+	precAnd
+	precRange
+	precEquals
+	precLessGreater
+	precSum
+	precProduct
+	precPrefix
+	precAtom
+)
+
+func operatorPrecedence(op string) int {
+	switch op {
+	case "||":
+		return precOr
+	case "&&":
+		return precAnd
+	case "==", "!=":
+		return precEquals
+	case "<", ">":
+		return precLessGreater
+	case "+", "-":
+		return precSum
+	case "*", "/", "%":
+		return precProduct
+	}
+	return precUnknown
+}
+
+func precedence(e Expression) int {
+	switch e := e.(type) {
+	case *InfixExpression:
+		return operatorPrecedence(e.Operator)
+	case *RangeExpression:
+		return precRange
+	case *PrefixExpression:
+		return precPrefix
+	case *AssignExpression, *IndexAssignExpression:
+		return precUnknown // only ever unparenthesized as a whole statement or on the right of another
+	}
+	return precAtom
+}
+
+type printer struct {
+	out    bytes.Buffer
+	indent string
+	depth  int
+}
+
+func (p *printer) write(s string) { p.out.WriteString(s) }
+
+func (p *printer) newline() {
+	p.write("\n")
+	p.write(strings.Repeat(p.indent, p.depth))
+}
+
+// statement prints s without a trailing newline. last is set for the final statement of a block
+func (p *printer) statement(s Statement, last bool) {
+	switch s := s.(type) {
+	case *LetStatement:
+		p.write(s.TokenLiteral() + " " + s.Name.Value + " = ")
+		p.expression(s.Value)
+		p.write(";")
+	case *ReturnStatement:
+		p.write("return ")
+		p.expression(s.ReturnValue)
+		p.write(";")
+	case *ThrowStatement:
+		p.write("throw ")
+		p.expression(s.Value)
+		p.write(";")
+	case *DeferStatement:
+		p.write("defer ")
+		p.expression(s.Value)
+		p.write(";")
+	case *ExpressionStatement:
+		p.expression(s.Expression)
+		if !last {
+			p.write(";")
+		}
+	case *BlockStatement:
+		p.block(s)
+	case *WhileStatement:
+		p.write("while (")
+		p.expression(s.Condition)
+		p.write(") ")
+		p.block(s.Body)
+	case *ForStatement:
+		p.write("for (")
+		if s.Init != nil {
+			p.clause(s.Init)
+		}
+		p.write("; ")
+		if s.Condition != nil {
+			p.expression(s.Condition)
+		}
+		p.write("; ")
+		if s.Post != nil {
+			p.clause(s.Post)
+		}
+		p.write(") ")
+		p.block(s.Body)
+	case *ForInStatement:
+		p.write("for (")
+		if s.Key != nil {
+			p.write(s.Key.Value + ", ")
+		}
+		p.write(s.Value.Value + " in ")
+		p.expression(s.Iterable)
+		p.write(") ")
+		p.block(s.Body)
+	default:
+		p.write(s.String())
+	}
+}
+
+// clause prints the init or post statement of a for loop, without its semicolon
+func (p *printer) clause(s Statement) {
+	p.statement(s, true)
+	if p.out.Len() > 0 && p.out.Bytes()[p.out.Len()-1] == ';' {
+		p.out.Truncate(p.out.Len() - 1)
+	}
+}
+
+func (p *printer) block(b *BlockStatement) {
+	if len(b.Statements) == 0 {
+		p.write("{}")
+		return
+	}
+	p.write("{")
+	p.depth++
+	for i, s := range b.Statements {
+		p.newline()
+		p.statement(s, i == len(b.Statements)-1)
+	}
+	p.depth--
+	p.newline()
+	p.write("}")
+}
+
+// selectCase prints the head of the case on a line of its own and the statements of its body
+// below it, one level deeper, like a block without the braces
+func (p *printer) selectCase(c *SelectCase) {
+	if c.Channel == nil {
+		p.write("default:")
+	} else {
+		p.write("case ")
+		if c.Name != nil {
+			p.write(c.Name.Value + " = ")
+		}
+		p.write(c.Op.Literal + "(")
+		p.expression(c.Channel)
+		if c.Value != nil {
+			p.write(", ")
+			p.expression(c.Value)
+		}
+		p.write("):")
+	}
+	p.depth++
+	for i, s := range c.Body.Statements {
+		p.newline()
+		p.statement(s, i == len(c.Body.Statements)-1)
+	}
+	p.depth--
+}
+
+// operand prints e, in parentheses if it binds less tightly than prec
+func (p *printer) operand(e Expression, prec int) {
+	if precedence(e) < prec {
+		p.write("(")
+		p.expression(e)
+		p.write(")")
+		return
+	}
+	p.expression(e)
+}
+
+func (p *printer) list(elements []Expression) {
+	for i, e := range elements {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expression(e)
+	}
+}
+
+func (p *printer) parameters(params []*Identifier) {
+	p.write("(")
+	for i, param := range params {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.write(param.Value)
+	}
+	p.write(") ")
+}
+
+func (p *printer) expression(e Expression) {
+	switch e := e.(type) {
+	case *PrefixExpression:
+		p.write(e.Operator)
+		if right, ok := e.Right.(*PrefixExpression); ok && right.Operator == e.Operator && e.Operator == "-" {
+			p.write("(")
+			p.expression(right)
+			p.write(")")
+			return
+		}
+		p.operand(e.Right, precPrefix)
+	case *InfixExpression:
+		// operators are left associative: a right operand of the same precedence needs parentheses
+		left := operatorPrecedence(e.Operator)
+		right := left + 1
+		if left == precUnknown {
+			left, right = precAtom, precAtom
+		}
+		p.operand(e.Left, left)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Right, right)
+	case *RangeExpression:
+		p.operand(e.From, precRange+1)
+		p.write("..")
+		p.operand(e.To, precRange+1)
+	case *IfExpression:
+		p.write("if (")
+		p.expression(e.Condition)
+		p.write(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	case *FunctionLiteral:
+		p.write("fn")
+		p.parameters(e.Parameters)
+		p.block(e.Body)
+	case *MacroLiteral:
+		p.write("macro")
+		p.parameters(e.Parameters)
+		p.block(e.Body)
+	case *CallExpression:
+		p.operand(e.Function, precAtom)
+		p.write("(")
+		p.list(e.Arguments)
+		for i, arg := range e.NamedArguments {
+			if i > 0 || len(e.Arguments) > 0 {
+				p.write(", ")
+			}
+			p.write(arg.Name.Value + ": ")
+			p.expression(arg.Value)
+		}
+		p.write(")")
+	case *ArrayLiteral:
+		p.write("[")
+		p.list(e.Elements)
+		p.write("]")
+	case *HashLiteral:
+		p.write("{")
+		for i, pair := range e.Pairs {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(pair.Key)
+			p.write(": ")
+			p.expression(pair.Value)
+		}
+		p.write("}")
+	case *IndexExpression:
+		p.operand(e.Left, precAtom)
+		p.write("[")
+		p.expression(e.Index)
+		p.write("]")
+	case *AssignExpression:
+		p.write(e.Name.Value + " = ")
+		p.expression(e.Value)
+	case *IndexAssignExpression:
+		p.expression(e.Target)
+		p.write(" = ")
+		p.expression(e.Value)
+	case *SliceExpression:
+		p.operand(e.Left, precAtom)
+		p.write("[")
+		if e.Low != nil {
+			p.expression(e.Low)
+		}
+		p.write(":")
+		if e.High != nil {
+			p.expression(e.High)
+		}
+		p.write("]")
+	case *MatchExpression:
+		p.write("match (")
+		p.expression(e.Subject)
+		p.write(") {")
+		p.depth++
+		for _, arm := range e.Arms {
+			p.newline()
+			p.expression(arm.Pattern)
+			p.write(" => ")
+			p.expression(arm.Body)
+			p.write(",")
+		}
+		p.depth--
+		p.newline()
+		p.write("}")
+	case *InterpolatedString:
+		p.write(`"`)
+		for i, x := range e.Exprs {
+			p.write(stringEscaper.Replace(e.Strings[i]) + "${")
+			p.expression(x)
+			p.write("}")
+		}
+		p.write(stringEscaper.Replace(e.Strings[len(e.Exprs)]) + `"`)
+	case *TryExpression:
+		p.write("try ")
+		p.block(e.Block)
+		p.write(" catch (" + e.CatchParam.Value + ") ")
+		p.block(e.CatchBlock)
+	case *SelectExpression:
+		if len(e.Cases) == 0 {
+			p.write("select {}")
+			break
+		}
+		p.write("select {")
+		p.depth++
+		for _, c := range e.Cases {
+			p.newline()
+			p.selectCase(c)
+		}
+		p.depth--
+		p.newline()
+		p.write("}")
+	default:
+		// identifiers and literals
+		p.write(e.String())
+	}
+}
@@ -0,0 +1,159 @@
+package ast
+
+import "strings"
+
+// Sdump renders node as an s-expression, eg `(let x (+ 1 2))` for `let x = 1 + 2;`. Every
+// compound node is a list headed by its operator or keyword, so the shape of the tree shows
+// without String's parentheses around every infix expression:
+//
+//	(call f a (named b 1))     f(a, b: 1)
+//	(index a i)                a[i]
+//	(slice a () 2)             a[:2]
+//	(fn (x y) (block (+ x y))) fn(x, y) { x + y }
+//	(hash ("a" 1))             {"a": 1}
+//	(match x (=> 1 "one"))     match (x) { 1 => "one" }
+//
+// The statements of a Program are put on lines of their own
+func Sdump(node Node) string {
+	if program, ok := node.(*Program); ok {
+		lines := []string{}
+		for _, s := range program.Statements {
+			lines = append(lines, Sdump(s))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return sexp(node)
+}
+
+func sexp(node Node) string {
+	if isNil(node) {
+		return "()"
+	}
+
+	switch n := node.(type) {
+	// Statements
+	case *LetStatement:
+		return list(n.TokenLiteral(), n.Name.Value, sexp(n.Value))
+	case *ReturnStatement:
+		return list("return", sexp(n.ReturnValue))
+	case *ThrowStatement:
+		return list("throw", sexp(n.Value))
+	case *DeferStatement:
+		return list("defer", sexp(n.Value))
+	case *ExpressionStatement:
+		return sexp(n.Expression)
+	case *BreakStatement:
+		return list("break")
+	case *ContinueStatement:
+		return list("continue")
+	case *WhileStatement:
+		return list("while", sexp(n.Condition), sexp(n.Body))
+	case *ForStatement:
+		return list("for", sexp(n.Init), sexp(n.Condition), sexp(n.Post), sexp(n.Body))
+	case *ForInStatement:
+		return list("for-in", sexp(n.Key), sexp(n.Value), sexp(n.Iterable), sexp(n.Body))
+	case *BlockStatement:
+		items := []string{"block"}
+		for _, s := range n.Statements {
+			items = append(items, sexp(s))
+		}
+		return list(items...)
+
+	// Expressions
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean, *NullLiteral, *ObjectExpression:
+		return n.String()
+	case *InterpolatedString:
+		items := []string{"interpolate"}
+		for i, e := range n.Exprs {
+			items = append(items, (&StringLiteral{Value: n.Strings[i]}).String(), sexp(e))
+		}
+		return list(append(items, (&StringLiteral{Value: n.Strings[len(n.Exprs)]}).String())...)
+	case *PrefixExpression:
+		return list(n.Operator, sexp(n.Right))
+	case *InfixExpression:
+		return list(n.Operator, sexp(n.Left), sexp(n.Right))
+	case *RangeExpression:
+		return list("..", sexp(n.From), sexp(n.To))
+	case *IfExpression:
+		if n.Alternative == nil {
+			return list("if", sexp(n.Condition), sexp(n.Consequence))
+		}
+		return list("if", sexp(n.Condition), sexp(n.Consequence), sexp(n.Alternative))
+	case *FunctionLiteral:
+		return list("fn", parameters(n.Parameters), sexp(n.Body))
+	case *MacroLiteral:
+		return list("macro", parameters(n.Parameters), sexp(n.Body))
+	case *CallExpression:
+		items := []string{"call", sexp(n.Function)}
+		for _, a := range n.Arguments {
+			items = append(items, sexp(a))
+		}
+		for _, a := range n.NamedArguments {
+			items = append(items, sexp(a))
+		}
+		return list(items...)
+	case *NamedArgument:
+		return list("named", n.Name.Value, sexp(n.Value))
+	case *ArrayLiteral:
+		items := []string{"array"}
+		for _, e := range n.Elements {
+			items = append(items, sexp(e))
+		}
+		return list(items...)
+	case *HashLiteral:
+		items := []string{"hash"}
+		for _, pair := range n.Pairs {
+			items = append(items, list(sexp(pair.Key), sexp(pair.Value)))
+		}
+		return list(items...)
+	case *IndexExpression:
+		return list("index", sexp(n.Left), sexp(n.Index))
+	case *AssignExpression:
+		return list("=", n.Name.Value, sexp(n.Value))
+	case *IndexAssignExpression:
+		return list("=", sexp(n.Target), sexp(n.Value))
+	case *SliceExpression:
+		return list("slice", sexp(n.Left), sexp(n.Low), sexp(n.High))
+	case *MatchExpression:
+		items := []string{"match", sexp(n.Subject)}
+		for _, arm := range n.Arms {
+			items = append(items, sexp(arm))
+		}
+		return list(items...)
+	case *MatchArm:
+		return list("=>", sexp(n.Pattern), sexp(n.Body))
+	case *TryExpression:
+		return list("try", sexp(n.Block), n.CatchParam.Value, sexp(n.CatchBlock))
+	case *SelectExpression:
+		items := []string{"select"}
+		for _, c := range n.Cases {
+			items = append(items, sexp(c))
+		}
+		return list(items...)
+	case *SelectCase:
+		if n.Channel == nil {
+			return list("default", sexp(n.Body))
+		}
+		comm := list(n.Op.Literal, sexp(n.Channel))
+		if n.Value != nil {
+			comm = list(n.Op.Literal, sexp(n.Channel), sexp(n.Value))
+		}
+		if n.Name != nil {
+			comm = list("=", n.Name.Value, comm)
+		}
+		return list("case", comm, sexp(n.Body))
+	}
+	return node.String()
+}
+
+func list(items ...string) string {
+	return "(" + strings.Join(items, " ") + ")"
+}
+
+func parameters(params []*Identifier) string {
+	names := []string{}
+	for _, p := range params {
+		names = append(names, p.Value)
+	}
+	return list(names...)
+}
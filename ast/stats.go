@@ -0,0 +1,33 @@
+package ast
+
+// Statistics describes the size and shape of a tree, eg to turn away scripts that are too big or
+// too deeply nested before evaluating them
+type Statistics struct {
+	Nodes       int            // the total number of nodes
+	Kinds       [NumKinds]int  // the number of nodes of each kind
+	MaxDepth    int            // the number of nodes on the longest path from the root, 1 for a leaf
+	Identifiers map[string]int // how often each name occurs, as a binding or a use
+}
+
+// Stats walks the tree under node and counts its nodes
+func Stats(node Node) *Statistics {
+	s := &Statistics{Identifiers: map[string]int{}}
+	depth := 0
+	Inspect(node, func(n Node) bool {
+		if n == nil {
+			depth--
+			return false
+		}
+		depth++
+		if depth > s.MaxDepth {
+			s.MaxDepth = depth
+		}
+		s.Nodes++
+		s.Kinds[n.Kind()]++
+		if ident, ok := n.(*Identifier); ok {
+			s.Identifiers[ident.Value]++
+		}
+		return true
+	})
+	return s
+}
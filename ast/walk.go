@@ -0,0 +1,193 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If the result visitor w
+// is not nil, Walk visits each of the children of node with w, followed by a call of w.Visit(nil)
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, the same way go/ast.Walk does: it starts by calling
+// v.Visit(node), then walks the children of node with the visitor returned, in source order.
+// Children that are nil are skipped, whether they're optional, eg a missing else block, or parts
+// the parser gave up on in a program with errors
+func Walk(v Visitor, node Node) {
+	if isNil(node) {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Walk(v, s)
+		}
+
+	// Statements
+	case *LetStatement:
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+	case *ReturnStatement:
+		Walk(v, n.ReturnValue)
+	case *ThrowStatement:
+		Walk(v, n.Value)
+	case *DeferStatement:
+		Walk(v, n.Value)
+	case *ExpressionStatement:
+		Walk(v, n.Expression)
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Walk(v, s)
+		}
+	case *WhileStatement:
+		Walk(v, n.Condition)
+		Walk(v, n.Body)
+	case *ForStatement:
+		if n.Init != nil {
+			Walk(v, n.Init)
+		}
+		if n.Condition != nil {
+			Walk(v, n.Condition)
+		}
+		if n.Post != nil {
+			Walk(v, n.Post)
+		}
+		Walk(v, n.Body)
+	case *ForInStatement:
+		if n.Key != nil {
+			Walk(v, n.Key)
+		}
+		Walk(v, n.Value)
+		Walk(v, n.Iterable)
+		Walk(v, n.Body)
+
+	case *BreakStatement, *ContinueStatement:
+		// leaves
+
+	// Expressions
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *Boolean, *NullLiteral, *ObjectExpression, *Comment:
+		// leaves
+	case *InterpolatedString:
+		for _, e := range n.Exprs {
+			Walk(v, e)
+		}
+	case *PrefixExpression:
+		Walk(v, n.Right)
+	case *InfixExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *RangeExpression:
+		Walk(v, n.From)
+		Walk(v, n.To)
+	case *IfExpression:
+		Walk(v, n.Condition)
+		Walk(v, n.Consequence)
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			Walk(v, p)
+		}
+		Walk(v, n.Body)
+	case *MacroLiteral:
+		for _, p := range n.Parameters {
+			Walk(v, p)
+		}
+		Walk(v, n.Body)
+	case *CallExpression:
+		Walk(v, n.Function)
+		for _, a := range n.Arguments {
+			Walk(v, a)
+		}
+		for _, a := range n.NamedArguments {
+			Walk(v, a)
+		}
+	case *NamedArgument:
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			Walk(v, e)
+		}
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			Walk(v, pair.Key)
+			Walk(v, pair.Value)
+		}
+	case *IndexExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Index)
+	case *AssignExpression:
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+	case *IndexAssignExpression:
+		Walk(v, n.Target)
+		Walk(v, n.Value)
+	case *SliceExpression:
+		Walk(v, n.Left)
+		if n.Low != nil {
+			Walk(v, n.Low)
+		}
+		if n.High != nil {
+			Walk(v, n.High)
+		}
+	case *MatchExpression:
+		Walk(v, n.Subject)
+		for _, arm := range n.Arms {
+			Walk(v, arm)
+		}
+	case *MatchArm:
+		Walk(v, n.Pattern)
+		Walk(v, n.Body)
+	case *TryExpression:
+		Walk(v, n.Block)
+		Walk(v, n.CatchParam)
+		Walk(v, n.CatchBlock)
+	case *SelectExpression:
+		for _, c := range n.Cases {
+			Walk(v, c)
+		}
+	case *SelectCase:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		if n.Channel != nil {
+			Walk(v, n.Channel)
+		}
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+		Walk(v, n.Body)
+
+	// Comments
+	case *CommentGroup:
+		for _, c := range n.List {
+			Walk(v, c)
+		}
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: it starts by calling f(node); node must not be
+// nil. If f returns true, Inspect invokes f recursively for each of the non-nil children of node,
+// followed by a call of f(nil)
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
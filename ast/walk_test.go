@@ -0,0 +1,129 @@
+package ast
+
+import (
+	"fmt"
+	"monkey/token"
+	"reflect"
+	"testing"
+)
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func integer(value int64) *IntegerLiteral {
+	return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(value)}, Value: value}
+}
+
+// let add = fn(x) { x + 1 }; if (add(2) > 2) { add } else { 0 }
+func walkTestProgram() *Program {
+	return &Program{Statements: []Statement{
+		&LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let"},
+			Name:  ident("add"),
+			Value: &FunctionLiteral{
+				Token:      token.Token{Type: token.FUNCTION, Literal: "fn"},
+				Parameters: []*Identifier{ident("x")},
+				Body: &BlockStatement{Statements: []Statement{
+					&ExpressionStatement{Expression: &InfixExpression{Left: ident("x"), Operator: "+", Right: integer(1)}},
+				}},
+			},
+		},
+		&ExpressionStatement{Expression: &IfExpression{
+			Condition: &InfixExpression{
+				Left:     &CallExpression{Function: ident("add"), Arguments: []Expression{integer(2)}},
+				Operator: ">",
+				Right:    integer(2),
+			},
+			Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: ident("add")}}},
+			Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: integer(0)}}},
+		}},
+	}}
+}
+
+type countingVisitor map[string]int
+
+func (v countingVisitor) Visit(node Node) Visitor {
+	if node != nil {
+		v[fmt.Sprintf("%T", node)]++
+	}
+	return v
+}
+
+func TestWalk(t *testing.T) {
+	v := countingVisitor{}
+	Walk(v, walkTestProgram())
+
+	expected := countingVisitor{
+		"*ast.Program":             1,
+		"*ast.LetStatement":        1,
+		"*ast.FunctionLiteral":     1,
+		"*ast.BlockStatement":      3,
+		"*ast.ExpressionStatement": 4,
+		"*ast.InfixExpression":     2,
+		"*ast.IfExpression":        1,
+		"*ast.CallExpression":      1,
+		"*ast.Identifier":          5,
+		"*ast.IntegerLiteral":      4,
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("wrong node counts. want=%v, got=%v", expected, v)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	// identifiers in source order, without descending into function literals
+	var idents []string
+	Inspect(walkTestProgram(), func(n Node) bool {
+		switch n := n.(type) {
+		case *Identifier:
+			idents = append(idents, n.Value)
+		case *FunctionLiteral:
+			return false
+		}
+		return true
+	})
+	expected := []string{"add", "add", "add"}
+	if !reflect.DeepEqual(idents, expected) {
+		t.Errorf("wrong identifiers. want=%v, got=%v", expected, idents)
+	}
+
+	// every node that's entered is left again with a nil call
+	depth, maxDepth := 0, 0
+	Inspect(walkTestProgram(), func(n Node) bool {
+		if n == nil {
+			depth--
+			return false
+		}
+		depth++
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		return true
+	})
+	if depth != 0 {
+		t.Errorf("unbalanced nil calls. depth=%d", depth)
+	}
+	if maxDepth != 7 { // Program, Let, Function, Block, ExpressionStatement, Infix, Identifier
+		t.Errorf("wrong maximum depth. got=%d", maxDepth)
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := Stats(walkTestProgram())
+
+	if s.Nodes != 23 {
+		t.Errorf("s.Nodes wrong. want=23, got=%d", s.Nodes)
+	}
+	if s.Kinds[IdentifierKind] != 5 || s.Kinds[BlockStatementKind] != 3 || s.Kinds[CallExprKind] != 1 {
+		t.Errorf("s.Kinds wrong. got=%v", s.Kinds)
+	}
+	// Program, LetStatement, FunctionLiteral, BlockStatement, ExpressionStatement, InfixExpression, Identifier
+	if s.MaxDepth != 7 {
+		t.Errorf("s.MaxDepth wrong. want=7, got=%d", s.MaxDepth)
+	}
+	expected := map[string]int{"add": 3, "x": 2}
+	if !reflect.DeepEqual(s.Identifiers, expected) {
+		t.Errorf("s.Identifiers wrong. want=%v, got=%v", expected, s.Identifiers)
+	}
+}
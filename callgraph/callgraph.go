@@ -0,0 +1,336 @@
+// Package callgraph builds a static call graph of a Monkey program. Functions are only known by the
+// name they're bound to with let, so the graph is best-effort: calls through arbitrary expressions
+// (arr[0](), f()()) aren't resolved, and a named function passed around as a value is recorded as an
+// indirect edge from wherever it was referenced, since it may be called from there
+package callgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"monkey/ast"
+)
+
+// Main is the name of the node for the top-level statements of the program
+const Main = "<main>"
+
+type Node struct {
+	Name     string `json:"name"`               // qualified by the enclosing function, eg outer.inner
+	External bool   `json:"external,omitempty"` // called but not defined in the program, eg a builtin
+	Line     int    `json:"line,omitempty"`     // where the function literal starts
+}
+
+type Edge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Indirect bool   `json:"indirect,omitempty"` // the function was referenced as a value, not called directly
+}
+
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+
+	nodes map[string]*Node
+	edges map[Edge]bool
+}
+
+// Build returns the call graph of program. Nodes and edges are in the order they appear in the source
+func Build(program *ast.Program) *Graph {
+	b := &builder{
+		g:      &Graph{Nodes: []*Node{}, Edges: []*Edge{}, nodes: map[string]*Node{}, edges: map[Edge]bool{}},
+		scopes: []map[string]string{{}},
+		fns:    []string{Main},
+	}
+	b.g.addNode(&Node{Name: Main})
+	for _, s := range program.Statements {
+		b.walk(s)
+	}
+	return b.g
+}
+
+// Callees returns the names of the functions called (or referenced) by name
+func (g *Graph) Callees(name string) []string {
+	callees := []string{}
+	for _, e := range g.Edges {
+		if e.From == name {
+			callees = append(callees, e.To)
+		}
+	}
+	return callees
+}
+
+// Recursive reports whether name can reach itself, directly or through other functions
+func (g *Graph) Recursive(name string) bool {
+	seen := map[string]bool{}
+	stack := g.Callees(name)
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n == name {
+			return true
+		}
+		if !seen[n] {
+			seen[n] = true
+			stack = append(stack, g.Callees(n)...)
+		}
+	}
+	return false
+}
+
+// DOT renders the graph in Graphviz format. External functions are drawn as boxes, indirect
+// edges as dashed lines
+func (g *Graph) DOT() string {
+	var out bytes.Buffer
+	out.WriteString("digraph callgraph {\n")
+	for _, n := range g.Nodes {
+		if n.External {
+			out.WriteString(fmt.Sprintf("\t%q [shape=box];\n", n.Name))
+		} else {
+			out.WriteString(fmt.Sprintf("\t%q;\n", n.Name))
+		}
+	}
+	for _, e := range g.Edges {
+		if e.Indirect {
+			out.WriteString(fmt.Sprintf("\t%q -> %q [style=dashed];\n", e.From, e.To))
+		} else {
+			out.WriteString(fmt.Sprintf("\t%q -> %q;\n", e.From, e.To))
+		}
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// JSON renders the graph as {"nodes": [...], "edges": [...]}
+func (g *Graph) JSON() ([]byte, error) {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false) // keep <main> readable
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(g); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// addNode adds n unless it's already there. Calls are resolved when the closure runs, so a function
+// can call one that's defined after it: its external node becomes the definition
+func (g *Graph) addNode(n *Node) {
+	old, ok := g.nodes[n.Name]
+	if !ok {
+		g.nodes[n.Name] = n
+		g.Nodes = append(g.Nodes, n)
+	} else if old.External && !n.External {
+		*old = *n
+	}
+}
+
+func (g *Graph) addEdge(e Edge) {
+	if !g.edges[e] {
+		g.edges[e] = true
+		g.Edges = append(g.Edges, &e)
+	}
+}
+
+type builder struct {
+	g      *Graph
+	scopes []map[string]string // variable name => function node, or "" for anything that isn't a known function
+	fns    []string            // the functions being walked, innermost last
+	anon   int
+}
+
+func (b *builder) current() string { return b.fns[len(b.fns)-1] }
+
+// resolve returns the function node name is bound to, if it's bound to a known function
+func (b *builder) resolve(name string) (string, bool) {
+	fn, _ := b.lookup(name)
+	return fn, fn != ""
+}
+
+// lookup is resolve without the function check: it reports whether name is bound at all
+func (b *builder) lookup(name string) (string, bool) {
+	for i := len(b.scopes) - 1; i >= 0; i-- {
+		if fn, ok := b.scopes[i][name]; ok {
+			return fn, true
+		}
+	}
+	return "", false
+}
+
+func (b *builder) define(name, fn string) { b.scopes[len(b.scopes)-1][name] = fn }
+
+func (b *builder) qualify(name string) string {
+	if b.current() == Main {
+		return name
+	}
+	return b.current() + "." + name
+}
+
+func (b *builder) walk(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		if fl, ok := node.Value.(*ast.FunctionLiteral); ok {
+			name := b.qualify(node.Name.Value)
+			b.define(node.Name.Value, name) // before the body, so recursive calls resolve
+			b.function(name, fl)
+			return
+		}
+		b.walk(node.Value)
+		b.define(node.Name.Value, "")
+	case *ast.ReturnStatement:
+		b.walk(node.ReturnValue)
+	case *ast.ThrowStatement:
+		b.walk(node.Value)
+	case *ast.DeferStatement:
+		b.walk(node.Value)
+	case *ast.ExpressionStatement:
+		b.walk(node.Expression)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			b.walk(s)
+		}
+	case *ast.WhileStatement:
+		b.walk(node.Condition)
+		b.walk(node.Body)
+	case *ast.ForStatement:
+		if node.Init != nil {
+			b.walk(node.Init)
+		}
+		if node.Condition != nil {
+			b.walk(node.Condition)
+		}
+		if node.Post != nil {
+			b.walk(node.Post)
+		}
+		b.walk(node.Body)
+	case *ast.ForInStatement:
+		b.walk(node.Iterable)
+		b.walk(node.Body)
+	case *ast.Identifier:
+		if fn, ok := b.resolve(node.Value); ok {
+			b.g.addEdge(Edge{From: b.current(), To: fn, Indirect: true})
+		}
+	case *ast.PrefixExpression:
+		b.walk(node.Right)
+	case *ast.InfixExpression:
+		b.walk(node.Left)
+		b.walk(node.Right)
+	case *ast.RangeExpression:
+		b.walk(node.From)
+		b.walk(node.To)
+	case *ast.IfExpression:
+		b.walk(node.Condition)
+		b.walk(node.Consequence)
+		if node.Alternative != nil {
+			b.walk(node.Alternative)
+		}
+	case *ast.FunctionLiteral: // an anonymous function value, it may be called from here
+		b.g.addEdge(Edge{From: b.current(), To: b.anonymous(node), Indirect: true})
+	case *ast.CallExpression:
+		b.call(node)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			b.walk(el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			b.walk(pair.Key)
+			b.walk(pair.Value)
+		}
+	case *ast.IndexExpression:
+		b.walk(node.Left)
+		b.walk(node.Index)
+	case *ast.AssignExpression:
+		b.walk(node.Value)
+	case *ast.IndexAssignExpression:
+		b.walk(node.Target)
+		b.walk(node.Value)
+	case *ast.InterpolatedString:
+		for _, e := range node.Exprs {
+			b.walk(e)
+		}
+	case *ast.SliceExpression:
+		b.walk(node.Left)
+		if node.Low != nil {
+			b.walk(node.Low)
+		}
+		if node.High != nil {
+			b.walk(node.High)
+		}
+	case *ast.MatchExpression:
+		b.walk(node.Subject)
+		for _, arm := range node.Arms {
+			b.walk(arm.Pattern)
+			b.walk(arm.Body)
+		}
+	case *ast.TryExpression:
+		b.walk(node.Block)
+		b.scopes = append(b.scopes, map[string]string{node.CatchParam.Value: ""})
+		b.walk(node.CatchBlock)
+		b.scopes = b.scopes[:len(b.scopes)-1]
+	case *ast.SelectExpression:
+		for _, c := range node.Cases {
+			if c.Channel != nil {
+				b.walk(c.Channel)
+			}
+			if c.Value != nil {
+				b.walk(c.Value)
+			}
+			scope := map[string]string{}
+			if c.Name != nil {
+				scope[c.Name.Value] = ""
+			}
+			b.scopes = append(b.scopes, scope)
+			b.walk(c.Body)
+			b.scopes = b.scopes[:len(b.scopes)-1]
+		}
+	}
+}
+
+// anonymous adds a node for a function literal that isn't bound to a name, eg <fn1>
+func (b *builder) anonymous(fl *ast.FunctionLiteral) string {
+	b.anon++
+	name := b.qualify(fmt.Sprintf("<fn%d>", b.anon))
+	b.function(name, fl)
+	return name
+}
+
+// function adds a node for fl and walks its body with the parameters in scope
+func (b *builder) function(name string, fl *ast.FunctionLiteral) {
+	b.g.addNode(&Node{Name: name, Line: fl.Token.Line})
+
+	scope := map[string]string{}
+	for _, p := range fl.Parameters {
+		scope[p.Value] = ""
+	}
+	b.scopes = append(b.scopes, scope)
+	b.fns = append(b.fns, name)
+	b.walk(fl.Body)
+	b.fns = b.fns[:len(b.fns)-1]
+	b.scopes = b.scopes[:len(b.scopes)-1]
+}
+
+func (b *builder) call(node *ast.CallExpression) {
+	switch callee := node.Function.(type) {
+	case *ast.Identifier:
+		fn, bound := b.lookup(callee.Value)
+		if bound && fn == "" {
+			break // a parameter or variable, we can't tell what it holds
+		}
+		if !bound {
+			fn = callee.Value
+			b.g.addNode(&Node{Name: fn, External: true})
+		}
+		b.g.addEdge(Edge{From: b.current(), To: fn})
+	case *ast.FunctionLiteral: // immediately invoked
+		b.g.addEdge(Edge{From: b.current(), To: b.anonymous(callee)})
+	default:
+		b.walk(callee)
+	}
+
+	for _, arg := range node.Arguments {
+		b.walk(arg)
+	}
+	for _, arg := range node.NamedArguments {
+		b.walk(arg.Value)
+	}
+}
@@ -0,0 +1,128 @@
+package callgraph
+
+import (
+	"encoding/json"
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+func build(t *testing.T, input string) *Graph {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser had errors: %v", p.Errors())
+	}
+	return Build(program)
+}
+
+func TestCallees(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let outer = fn(x) {
+	let inner = fn(y) { puts(y); };
+	inner(x);
+};
+let apply = fn(f, x) { f(x) };
+apply(outer, 1);
+fib(10);
+fn(x) { x }(1);
+`
+	g := build(t, input)
+
+	tests := []struct {
+		name     string
+		expected []string
+	}{
+		{Main, []string{"apply", "outer", "fib", "<fn1>"}},
+		{"fib", []string{"fib"}},
+		{"outer", []string{"outer.inner"}},
+		{"outer.inner", []string{"puts"}},
+		{"apply", []string{}}, // f is a parameter, it can't be resolved
+	}
+	for _, tt := range tests {
+		if got := g.Callees(tt.name); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Callees(%q) wrong. want=%v, got=%v", tt.name, tt.expected, got)
+		}
+	}
+
+	for _, n := range g.Nodes {
+		if n.External != (n.Name == "puts") {
+			t.Errorf("node %q has External=%t", n.Name, n.External)
+		}
+	}
+}
+
+func TestIndirectEdges(t *testing.T) {
+	g := build(t, "let double = fn(x) { x * 2 }; map([1, 2], double); let five = 5; five;")
+
+	expected := []*Edge{{From: Main, To: "map"}, {From: Main, To: "double", Indirect: true}}
+	if !reflect.DeepEqual(g.Edges, expected) {
+		t.Errorf("wrong edges. want=%v, got=%v", expected, g.Edges)
+	}
+}
+
+func TestRecursive(t *testing.T) {
+	input := `
+let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } };
+let sum = fn(arr) { reduce(arr, 0, fn(a, b) { a + b }) };
+`
+	g := build(t, input)
+
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"fact", true},
+		{"sum", false},
+		{"even", true}, // odd is only defined after even, but it's there by the time even runs
+		{"odd", true},
+	}
+	for _, tt := range tests {
+		if got := g.Recursive(tt.name); got != tt.expected {
+			t.Errorf("Recursive(%q) wrong. want=%t, got=%t", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestDOT(t *testing.T) {
+	g := build(t, "let f = fn() { len([]) }; let g = f; f();")
+
+	expected := `digraph callgraph {
+	"<main>";
+	"f";
+	"len" [shape=box];
+	"f" -> "len";
+	"<main>" -> "f" [style=dashed];
+	"<main>" -> "f";
+}
+`
+	if got := g.DOT(); got != expected {
+		t.Errorf("DOT wrong. want=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	g := build(t, "let f = fn() {\n len([]) };\nf();")
+
+	data, err := g.JSON()
+	if err != nil {
+		t.Fatalf("JSON failed: %s", err)
+	}
+	var decoded Graph
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+
+	nodes := []*Node{{Name: Main}, {Name: "f", Line: 1}, {Name: "len", External: true}}
+	if !reflect.DeepEqual(decoded.Nodes, nodes) {
+		t.Errorf("wrong nodes. want=%v, got=%v", nodes, decoded.Nodes)
+	}
+	edges := []*Edge{{From: "f", To: "len"}, {From: Main, To: "f"}}
+	if !reflect.DeepEqual(decoded.Edges, edges) {
+		t.Errorf("wrong edges. want=%v, got=%v", edges, decoded.Edges)
+	}
+}
@@ -0,0 +1,113 @@
+// Package crash writes crash bundles: everything needed to reproduce an internal error of the
+// interpreter, collected in a directory that can be attached to a bug report as is
+package crash
+
+import (
+	"fmt"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Report describes a recovered panic
+type Report struct {
+	Source  string       // the input that was being run
+	Program *ast.Program // what was parsed of Source, may be nil or incomplete
+	Engine  string       // what was running the program, eg "evaluator"
+	Panic   interface{}  // the recovered value
+	Stack   []byte       // debug.Stack() at the point of recovery
+}
+
+// Write creates a new directory under dir (os.TempDir() if dir is empty) and writes the bundle
+// for r to it:
+//
+//	report.txt     the panic, stack trace, engine and versions
+//	source.monkey  the input
+//	tokens.txt     the token stream of the input, one token per line
+//	ast.txt        the parsed program
+//
+// It returns the path of the new directory
+func Write(dir string, r *Report) (string, error) {
+	bundle, err := ioutil.TempDir(dir, "monkey-crash-")
+	if err != nil {
+		return "", err
+	}
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"report.txt", r.report()},
+		{"source.monkey", r.Source},
+		{"tokens.txt", tokens(r.Source)},
+		{"ast.txt", r.ast()},
+	}
+	for _, f := range files {
+		if err := ioutil.WriteFile(filepath.Join(bundle, f.name), []byte(f.content), 0644); err != nil {
+			return "", err
+		}
+	}
+	return bundle, nil
+}
+
+func (r *Report) report() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "panic: %v\n\n", r.Panic)
+	fmt.Fprintf(&out, "engine: %s\n", r.Engine)
+	fmt.Fprintf(&out, "monkey: %s\n", version())
+	fmt.Fprintf(&out, "go: %s %s/%s\n\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	out.Write(r.Stack)
+	return out.String()
+}
+
+func (r *Report) ast() string {
+	if r.Program == nil {
+		return "(not parsed)\n"
+	}
+	var out strings.Builder
+	for _, s := range r.Program.Statements {
+		out.WriteString(s.String() + "\n")
+	}
+	return out.String()
+}
+
+// tokens lexes src again: the lexer has no state worth saving, so this gives the same tokens the
+// parser saw
+func tokens(src string) string {
+	var out strings.Builder
+	l := lexer.New(src)
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(&out, "%d:%d\t%s\t%q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			return out.String()
+		}
+	}
+}
+
+// version is the version of the main module, if the binary was built with module information
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// Enabled reports whether the user opted into crash bundles by setting MONKEY_CRASH_BUNDLE. Its
+// value is the directory to write them to, or 1 for os.TempDir()
+func Enabled() (dir string, ok bool) {
+	dir = os.Getenv("MONKEY_CRASH_BUNDLE")
+	if dir == "" {
+		return "", false
+	}
+	if dir == "1" {
+		dir = ""
+	}
+	return dir, true
+}
@@ -0,0 +1,55 @@
+package crash
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	dir, err := Write(t.TempDir(), &Report{Source: "let x = 1;\nx", Engine: "evaluator", Panic: "boom"})
+	if err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	if !strings.HasPrefix(filepath.Base(dir), "monkey-crash-") {
+		t.Errorf("wrong bundle directory name. got=%q", dir)
+	}
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"source.monkey", "let x = 1;\nx"},
+		{"ast.txt", "(not parsed)\n"},
+		{"tokens.txt", "1:1\tLET\t\"let\"\n1:5\tIDENT\t\"x\"\n1:7\t=\t\"=\"\n1:9\tINT\t\"1\"\n1:10\t;\t\";\"\n2:1\tIDENT\t\"x\"\n2:2\tEOF\t\"\"\n"},
+	}
+	for _, tt := range tests {
+		content, err := ioutil.ReadFile(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatalf("bundle is missing %s: %s", tt.file, err)
+		}
+		if string(content) != tt.expected {
+			t.Errorf("%s wrong. expected=%q, got=%q", tt.file, tt.expected, content)
+		}
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		env     string
+		dir     string
+		enabled bool
+	}{
+		{"", "", false},
+		{"1", "", true},
+		{"/var/crash", "/var/crash", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("MONKEY_CRASH_BUNDLE", tt.env)
+		dir, ok := Enabled()
+		if dir != tt.dir || ok != tt.enabled {
+			t.Errorf("Enabled() with %q wrong. want=(%q, %t), got=(%q, %t)", tt.env, tt.dir, tt.enabled, dir, ok)
+		}
+	}
+}
@@ -1,10 +1,23 @@
 package evaluator
 
 import (
-	"monkey/object"
 	"fmt"
+	"io"
+	"math"
+	"monkey/object"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
+// Stdout is where puts writes to. A program embedding the evaluator, or a test, can set it to
+// capture what scripts print
+var Stdout io.Writer = os.Stdout
+
 var builtins = map[string]*object.Builtin{
 
 	"len": &object.Builtin{
@@ -16,7 +29,11 @@ var builtins = map[string]*object.Builtin{
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			case *object.String:
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *object.Bytes:
 				return &object.Integer{Value: int64(len(arg.Value))}
+			case *object.Range:
+				return &object.Integer{Value: arg.Len()}
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
@@ -32,7 +49,7 @@ var builtins = map[string]*object.Builtin{
 			}
 			arr := args[0].(*object.Array)
 			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
+				return arr.Get(0)
 			}
 			return NULL
 		},
@@ -48,7 +65,7 @@ var builtins = map[string]*object.Builtin{
 			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
 			if length > 0 {
-				return arr.Elements[length-1]
+				return arr.Get(length - 1)
 			}
 			return NULL
 		},
@@ -62,11 +79,11 @@ var builtins = map[string]*object.Builtin{
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError("argument to 'rest' must be ARRAY, got %s", args[0].Type())
 			}
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
+			elements := args[0].(*object.Array).Snapshot()
+			length := len(elements)
 			if length > 0 {
 				newElements := make([]object.Object, length-1, length-1)
-				copy(newElements, arr.Elements[1:length])
+				copy(newElements, elements[1:length])
 				return &object.Array{Elements: newElements}
 			}
 			return NULL
@@ -81,20 +98,706 @@ var builtins = map[string]*object.Builtin{
 			if args[0].Type() != object.ARRAY_OBJ {
 				return newError("argument to 'push()' must be ARRAY, got %s", args[0].Type())
 			}
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
+			elements := args[0].(*object.Array).Snapshot()
+			length := len(elements)
 			newElements := make([]object.Object, length+1, length+1)
-			copy(newElements, arr.Elements)
+			copy(newElements, elements)
 			newElements[length] = args[1]
 			return &object.Array{Elements: newElements}
 		},
 	},
+	// blocks until the future is resolved and returns its result. Awaiting a cancelled future is an error
+	"await": &object.Builtin{
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			future, ok := args[0].(*object.Future)
+			if !ok {
+				return newError("argument to 'await()' must be FUTURE, got %s", args[0].Type())
+			}
+			ctx := contextOf(env)
+			select {
+			case <-future.Done():
+			case <-ctx.Done():
+				return newInterrupt("await interrupted: %s", ctx.Err())
+			}
+			return settled(future)
+		},
+	},
+	"isDone": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			future, ok := args[0].(*object.Future)
+			if !ok {
+				return newError("argument to 'isDone()' must be FUTURE, got %s", args[0].Type())
+			}
+			return nativeBoolToBooleanObject(future.IsDone())
+		},
+	},
+	// returns true if the future was still pending. The spawned function is interrupted the next
+	// time it waits, loops or calls a function, and its result is discarded
+	"cancel": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			future, ok := args[0].(*object.Future)
+			if !ok {
+				return newError("argument to 'cancel()' must be FUTURE, got %s", args[0].Type())
+			}
+			return nativeBoolToBooleanObject(future.Cancel())
+		},
+	},
+	// returns a new array with the elements in reverse order
+	"reverse": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to 'reverse()' must be ARRAY, got %s", args[0].Type())
+			}
+			elements := arr.Snapshot()
+			length := len(elements)
+			newElements := make([]object.Object, length)
+			for i, el := range elements {
+				newElements[length-1-i] = el
+			}
+			return &object.Array{Elements: newElements}
+		},
+	},
+	// returns the keys of the hash as an array, in the order they were added, like a for-in loop
+	"keys": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArg("keys", args, 1)
+			if err != nil {
+				return err
+			}
+			pairs := hash.OrderedPairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Key
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	// returns the values of the hash as an array, in the same order as keys returns the keys
+	"values": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArg("values", args, 1)
+			if err != nil {
+				return err
+			}
+			pairs := hash.OrderedPairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Value
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"has": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArg("has", args, 2)
+			if err != nil {
+				return err
+			}
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+			_, ok = hash.Get(key.HashKey())
+			return nativeBoolToBooleanObject(ok)
+		},
+	},
+	// removes the key from the hash, unlike push it changes the hash it's given. Returns whether
+	// the key was there
+	"delete": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArg("delete", args, 2)
+			if err != nil {
+				return err
+			}
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+			if hash.Frozen {
+				return newError("cannot modify frozen HASH")
+			}
+			return nativeBoolToBooleanObject(hash.Delete(key.HashKey()))
+		},
+	},
+	// returns the name of the type of its argument, eg "INTEGER" or "ARRAY"
+	"type": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return &object.String{Value: string(args[0].Type())}
+		},
+	},
+	// converts to an integer: floats are truncated toward zero, strings must hold a decimal
+	// integer, true and false become 1 and 0
+	"int": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				if math.IsNaN(arg.Value) || arg.Value >= math.MaxInt64 || arg.Value < math.MinInt64 {
+					return newError("cannot convert %s to INTEGER", arg.Inspect())
+				}
+				return &object.Integer{Value: int64(arg.Value)}
+			case *object.String:
+				i, err := strconv.ParseInt(arg.Value, 10, 64)
+				if err != nil {
+					return newError("cannot convert %q to INTEGER", arg.Value)
+				}
+				return &object.Integer{Value: i}
+			case *object.Boolean:
+				if arg.Value {
+					return &object.Integer{Value: 1}
+				}
+				return &object.Integer{Value: 0}
+			default:
+				return newError("argument to 'int()' not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	// converts to a float: strings must hold a decimal number, true and false become 1.0 and 0.0
+	"float": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return &object.Float{Value: float64(arg.Value)}
+			case *object.Float:
+				return arg
+			case *object.String:
+				f, err := strconv.ParseFloat(arg.Value, 64)
+				if err != nil {
+					return newError("cannot convert %q to FLOAT", arg.Value)
+				}
+				return &object.Float{Value: f}
+			case *object.Boolean:
+				if arg.Value {
+					return &object.Float{Value: 1}
+				}
+				return &object.Float{Value: 0}
+			default:
+				return newError("argument to 'float()' not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	// converts anything to a string, the way puts prints it
+	"str": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if str, ok := args[0].(*object.String); ok {
+				return str
+			}
+			return &object.String{Value: args[0].Inspect()}
+		},
+	},
+	// converts anything to a boolean, the way if decides whether a condition holds
+	"bool": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return nativeBoolToBooleanObject(isTruthy(args[0]))
+		},
+	},
+	// splits the string around every occurrence of the separator, or into characters if it's ""
+	"split": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArgs("split", 2, args)
+			if err != nil {
+				return err
+			}
+			parts := strings.Split(strs[0], strs[1])
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	// joins the elements of the array with the separator between them. Elements that aren't strings
+	// are joined the way puts would print them
+	"join": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to 'join()' must be ARRAY, got %s", args[0].Type())
+			}
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError("second argument to 'join()' must be STRING, got %s", args[1].Type())
+			}
+			elements := arr.Snapshot()
+			parts := make([]string, len(elements))
+			for i, el := range elements {
+				parts[i] = el.Inspect()
+			}
+			return &object.String{Value: strings.Join(parts, sep.Value)}
+		},
+	},
+	"upper": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArgs("upper", 1, args)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToUpper(strs[0])}
+		},
+	},
+	"lower": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArgs("lower", 1, args)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToLower(strs[0])}
+		},
+	},
+	// strips leading and trailing white space
+	"trim": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArgs("trim", 1, args)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: strings.TrimSpace(strs[0])}
+		},
+	},
+	// replaces every occurrence of old in the string with new
+	"replace": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArgs("replace", 3, args)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])}
+		},
+	},
+	// contains(arr, x) reports whether an element of the array equals x, contains(r, n) whether the
+	// integer n is in the range and contains(s, sub) whether the string has sub in it
+	"contains": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) == 2 {
+				switch container := args[0].(type) {
+				case *object.Array:
+					for _, el := range container.Snapshot() {
+						if equalObjects(el, args[1]) {
+							return TRUE
+						}
+					}
+					return FALSE
+				case *object.Range:
+					n, ok := args[1].(*object.Integer)
+					return nativeBoolToBooleanObject(ok && container.Contains(n.Value))
+				}
+			}
+			strs, err := stringArgs("contains", 2, args)
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.Contains(strs[0], strs[1]))
+		},
+	},
+	// toArray makes an array of what a one variable for-in loop over its argument would go over: the
+	// integers of a range, the characters of a string, the keys of a hash...
+	"toArray": &object.Builtin{
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			it, ok := args[0].(object.Iterable)
+			if !ok {
+				return newError("argument to 'toArray()' must be iterable, got %s", args[0].Type())
+			}
+			elements := []object.Object{}
+			var stopped *object.Error
+			err := iterateValues(env, it, func(value object.Object) bool {
+				if stopped = interrupted(env); stopped != nil {
+					return false
+				}
+				elements = append(elements, value)
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			if stopped != nil {
+				return stopped
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"startsWith": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArgs("startsWith", 2, args)
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.HasPrefix(strs[0], strs[1]))
+		},
+	},
+	"endsWith": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArgs("endsWith", 2, args)
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.HasSuffix(strs[0], strs[1]))
+		},
+	},
+	// returns the index of the first occurrence of the substring, -1 if there is none. Like indexing,
+	// it counts characters rather than bytes
+	"indexOf": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			strs, err := stringArgs("indexOf", 2, args)
+			if err != nil {
+				return err
+			}
+			i := strings.Index(strs[0], strs[1])
+			if i < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: int64(utf8.RuneCountInString(strs[0][:i]))}
+		},
+	},
+	// charAt(s, i) is s[i], the character at the index as a string
+	"charAt": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("first argument to 'charAt()' must be STRING, got %s", args[0].Type())
+			}
+			if args[1].Type() != object.INTEGER_OBJ {
+				return newError("second argument to 'charAt()' must be INTEGER, got %s", args[1].Type())
+			}
+			return evalStringIndexExpression(args[0], args[1])
+		},
+	},
+	// returns the Unicode code point of the character at the index as an integer
+	"codePoint": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to 'codePoint()' must be STRING, got %s", args[0].Type())
+			}
+			idx, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("second argument to 'codePoint()' must be INTEGER, got %s", args[1].Type())
+			}
+			runes := []rune(str.Value)
+			offset, ok := resolveIndex(idx.Value, len(runes))
+			if !ok {
+				return outOfRange(idx.Value, len(runes))
+			}
+			return &object.Integer{Value: int64(runes[offset])}
+		},
+	},
 	"puts": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(Stdout, arg.Inspect())
 			}
 			return NULL
 		},
 	},
 }
+
+func init() {
+	// pmap calls back into applyFunction, which looks identifiers up in the
+	// builtins table, so it's registered here to avoid an initialization cycle
+	builtins["pmap"] = &object.Builtin{EnvFn: pmap}
+	builtins["spawn"] = &object.Builtin{EnvFn: spawn}
+	builtins["map"] = &object.Builtin{EnvFn: mapBuiltin}
+	builtins["filter"] = &object.Builtin{EnvFn: filter}
+	builtins["reduce"] = &object.Builtin{EnvFn: reduce}
+	builtins["sort"] = &object.Builtin{EnvFn: sortBuiltin}
+}
+
+// ApplyFunction calls fn, a Function or a Builtin, with args. It's the way back into the evaluator
+// for Go code that's handed Monkey functions, eg builtins that take callbacks. The call is made as
+// if from the top level of a program
+func ApplyFunction(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args, nil)
+}
+
+// pmap applies fn to every element of the array on a pool of worker goroutines.
+// Every call gets its own environment enclosed by fn's environment (see extendFunctionEnv),
+// but calls may still assign to shared variables and change shared arrays and hashes, which
+// guard themselves with locks. Results are stored by index, which keeps them in the same
+// order as the input. If any call fails the first error (by index) is returned
+func pmap(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	if args[0].Type() != object.ARRAY_OBJ {
+		return newError("argument to 'pmap()' must be ARRAY, got %s", args[0].Type())
+	}
+	fn := args[1]
+	if fn.Type() != object.FUNCTION_OBJ && fn.Type() != object.BUILTIN_OBJ {
+		return newError("second argument to 'pmap()' must be FUNCTION, got %s", fn.Type())
+	}
+
+	elements := args[0].(*object.Array).Snapshot()
+	results := make([]object.Object, len(elements))
+
+	workers := runtime.NumCPU()
+	if workers > len(elements) {
+		workers = len(elements)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = applyFunction(fn, []object.Object{elements[i]}, env)
+			}
+		}()
+	}
+	for i := range elements {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, result := range results {
+		if isError(result) {
+			return result
+		}
+	}
+	return &object.Array{Elements: results}
+}
+
+// spawn calls fn with the remaining arguments on a new goroutine and immediately returns
+// a Future for its result. The call runs under the context of the future, so cancelling the
+// future, or whatever interrupts the caller, interrupts it too
+func spawn(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+	}
+	fn := args[0]
+	if fn.Type() != object.FUNCTION_OBJ && fn.Type() != object.BUILTIN_OBJ {
+		return newError("argument to 'spawn()' must be FUNCTION, got %s", fn.Type())
+	}
+
+	future := object.NewFuture(contextOf(env))
+	fnArgs := args[1:]
+	go func() {
+		caller := object.NewEnvironment()
+		caller.SetContext(future.Context())
+		future.Resolve(applyFunction(fn, fnArgs, caller))
+	}()
+	return future
+}
+
+// stringArgs checks that the builtin called name got n arguments, all of them strings, and returns
+// their values
+func stringArgs(name string, n int, args []object.Object) ([]string, *object.Error) {
+	if len(args) != n {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+	strs := make([]string, n)
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			if n == 1 {
+				return nil, newError("argument to '%s()' must be STRING, got %s", name, arg.Type())
+			}
+			return nil, newError("%s argument to '%s()' must be STRING, got %s", ordinals[i+1], name, arg.Type())
+		}
+		strs[i] = str.Value
+	}
+	return strs, nil
+}
+
+// hashArg checks that the builtin called name got n arguments, the first one a hash, and returns it
+func hashArg(name string, args []object.Object, n int) (*object.Hash, *object.Error) {
+	if len(args) != n {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		if n == 1 {
+			return nil, newError("argument to '%s()' must be HASH, got %s", name, args[0].Type())
+		}
+		return nil, newError("first argument to '%s()' must be HASH, got %s", name, args[0].Type())
+	}
+	return hash, nil
+}
+
+// callback checks that fn, the argument of the builtin called name at position n (counting from 1),
+// can be called. The calls are made as if from env, the environment the builtin was called in, so
+// they count towards MaxDepth like any other nested call
+func callback(name string, n int, fn object.Object, env *object.Environment) (func(args ...object.Object) object.Object, *object.Error) {
+	if fn.Type() != object.FUNCTION_OBJ && fn.Type() != object.BUILTIN_OBJ {
+		return nil, newError("%s argument to '%s()' must be FUNCTION, got %s", ordinals[n], name, fn.Type())
+	}
+	return func(args ...object.Object) object.Object { return applyFunction(fn, args, env) }, nil
+}
+
+var ordinals = [...]string{1: "first", 2: "second", 3: "third"}
+
+// mapBuiltin returns a new array with fn applied to every element of the array, in order. It
+// stops at the first call that fails
+func mapBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to 'map()' must be ARRAY, got %s", args[0].Type())
+	}
+	fn, err := callback("map", 2, args[1], env)
+	if err != nil {
+		return err
+	}
+
+	elements := arr.Snapshot()
+	results := make([]object.Object, len(elements))
+	for i, el := range elements {
+		results[i] = fn(el)
+		if isError(results[i]) {
+			return results[i]
+		}
+	}
+	return &object.Array{Elements: results}
+}
+
+// filter returns a new array with the elements of the array that fn returns something truthy for
+func filter(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to 'filter()' must be ARRAY, got %s", args[0].Type())
+	}
+	fn, err := callback("filter", 2, args[1], env)
+	if err != nil {
+		return err
+	}
+
+	results := []object.Object{}
+	for _, el := range arr.Snapshot() {
+		keep := fn(el)
+		if isError(keep) {
+			return keep
+		}
+		if isTruthy(keep) {
+			results = append(results, el)
+		}
+	}
+	return &object.Array{Elements: results}
+}
+
+// reduce folds the array into a single value from left to right: fn is called with the value so
+// far, starting with initial, and the next element
+func reduce(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=3", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to 'reduce()' must be ARRAY, got %s", args[0].Type())
+	}
+	fn, err := callback("reduce", 3, args[2], env)
+	if err != nil {
+		return err
+	}
+
+	acc := args[1]
+	for _, el := range arr.Snapshot() {
+		acc = fn(acc, el)
+		if isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+// sortBuiltin returns a new array with the elements of the array sorted. With a function, the
+// function decides the order: it's called with two elements and returns something truthy if the
+// first one goes before the second. Without one, the elements must be all numbers or all strings
+// and are sorted in ascending order. The sort is stable
+func sortBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to 'sort()' must be ARRAY, got %s", args[0].Type())
+	}
+	less := defaultLess
+	if len(args) == 2 {
+		fn, err := callback("sort", 2, args[1], env)
+		if err != nil {
+			return err
+		}
+		less = func(a, b object.Object) object.Object {
+			result := fn(a, b)
+			if isError(result) {
+				return result
+			}
+			return nativeBoolToBooleanObject(isTruthy(result))
+		}
+	}
+
+	newElements := arr.Snapshot()
+	var failed object.Object
+	sort.SliceStable(newElements, func(i, j int) bool {
+		if failed != nil {
+			return false
+		}
+		result := less(newElements[i], newElements[j])
+		if isError(result) {
+			failed = result
+			return false
+		}
+		return result == TRUE
+	})
+	if failed != nil {
+		return failed
+	}
+	return &object.Array{Elements: newElements}
+}
+
+// defaultLess orders numbers and strings, the elements sort can sort without a function
+func defaultLess(a, b object.Object) object.Object {
+	switch {
+	case isNumber(a) && isNumber(b):
+		return evalInfixExpression("<", a, b)
+	case a.Type() == object.STRING_OBJ && b.Type() == object.STRING_OBJ:
+		return nativeBoolToBooleanObject(a.(*object.String).Value < b.(*object.String).Value)
+	}
+	return newError("sort() can't compare %s and %s without a function", a.Type(), b.Type())
+}
@@ -0,0 +1,144 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"monkey/token"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TestMode changes what a failed assertion does. Normally it's an error like any other, that ends
+// the program. In test mode it's collected, with its position, and the program carries on, so a
+// test file reports all of its failures at once. See Failures
+var TestMode = false
+
+var failures struct {
+	sync.Mutex
+	list []*object.Error
+}
+
+// Failures returns the assertions that failed in TestMode since the last ResetFailures, in the
+// order they failed
+func Failures() []*object.Error {
+	failures.Lock()
+	defer failures.Unlock()
+	return append([]*object.Error(nil), failures.list...)
+}
+
+// ResetFailures forgets the assertions that failed so far
+func ResetFailures() {
+	failures.Lock()
+	defer failures.Unlock()
+	failures.list = nil
+}
+
+// assertionFailed starts the message of every failed assertion
+const assertionFailed = "assertion failed"
+
+func init() {
+	builtins["assert"] = &object.Builtin{Fn: assert}
+	builtins["assertEqual"] = &object.Builtin{Fn: assertEqual}
+}
+
+// isAssertion reports whether fn is one of the assertion builtins
+func isAssertion(fn object.Object) bool {
+	return fn == builtins["assert"] || fn == builtins["assertEqual"]
+}
+
+// recordFailure collects the error of a failed assertion called at pos in TestMode. It reports
+// whether it did, in which case the call evaluates to null rather than to the error. Calling an
+// assertion the wrong way is an error as usual
+func recordFailure(fn object.Object, result object.Object, pos token.Position) bool {
+	err, ok := result.(*object.Error)
+	if !ok || !TestMode || !isAssertion(fn) || !strings.HasPrefix(err.Message, assertionFailed) {
+		return false
+	}
+	err.Pos = pos
+	failures.Lock()
+	defer failures.Unlock()
+	failures.list = append(failures.list, err)
+	return true
+}
+
+// assert fails unless the condition is truthy. The optional message says what was expected
+func assert(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	if isTruthy(args[0]) {
+		return NULL
+	}
+	if len(args) == 2 {
+		return newError("%s: %s", assertionFailed, args[1].Inspect())
+	}
+	return newError(assertionFailed)
+}
+
+// assertEqual fails unless the values are equal. Unlike ==, arrays and hashes are equal if their
+// elements are
+func assertEqual(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	if !deepEqual(args[0], args[1], map[[2]object.Object]bool{}) {
+		return newError("%s: %s != %s", assertionFailed, inspectQuoted(args[0]), inspectQuoted(args[1]))
+	}
+	return NULL
+}
+
+// deepEqual is equalObjects extended to the elements of arrays and the pairs of hashes. seen holds
+// the pairs of arrays or hashes being compared further up, which are taken to be equal if they
+// come up again, so arrays and hashes that contain themselves don't send it round forever
+func deepEqual(a, b object.Object, seen map[[2]object.Object]bool) bool {
+	switch a := a.(type) {
+	case *object.Array:
+		b, ok := b.(*object.Array)
+		if !ok {
+			return false
+		}
+		if seen[[2]object.Object{a, b}] {
+			return true
+		}
+		seen[[2]object.Object{a, b}] = true
+		as, bs := a.Snapshot(), b.Snapshot()
+		if len(as) != len(bs) {
+			return false
+		}
+		for i := range as {
+			if !deepEqual(as[i], bs[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		b, ok := b.(*object.Hash)
+		if !ok {
+			return false
+		}
+		if seen[[2]object.Object{a, b}] {
+			return true
+		}
+		seen[[2]object.Object{a, b}] = true
+		pairs := a.OrderedPairs()
+		if len(pairs) != b.Len() {
+			return false
+		}
+		for _, pair := range pairs {
+			other, ok := b.Get(pair.Key.(object.Hashable).HashKey())
+			if !ok || !deepEqual(pair.Value, other.Value, seen) {
+				return false
+			}
+		}
+		return true
+	}
+	return equalObjects(a, b)
+}
+
+// inspectQuoted is Inspect with strings in quotes, so "1" and 1 can be told apart in messages
+func inspectQuoted(obj object.Object) string {
+	if str, ok := obj.(*object.String); ok {
+		return strconv.Quote(str.Value)
+	}
+	return obj.Inspect()
+}
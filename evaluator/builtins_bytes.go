@@ -0,0 +1,71 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"os"
+)
+
+// AllowFiles enables readFileBytes. Like AllowExec it's off by default, so a program embedding the
+// evaluator doesn't let scripts read its files unless it means to. The monkey command turns it on
+var AllowFiles = false
+
+func init() {
+	builtins["bytes"] = &object.Builtin{Fn: bytesBuiltin}
+	builtins["toString"] = &object.Builtin{Fn: toString}
+	builtins["readFileBytes"] = &object.Builtin{Fn: readFileBytes}
+}
+
+// bytesBuiltin makes Bytes of the UTF-8 encoding of a string, of an array of integers from 0 to
+// 255, or of other bytes, which it copies
+func bytesBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Bytes{Value: []byte(arg.Value)}
+	case *object.Bytes:
+		return &object.Bytes{Value: append([]byte{}, arg.Value...)}
+	case *object.Array:
+		elements := arg.Snapshot()
+		value := make([]byte, len(elements))
+		for i, el := range elements {
+			b, ok := el.(*object.Integer)
+			if !ok || b.Value < 0 || b.Value > 255 {
+				return newError("byte must be INTEGER from 0 to 255, got %s", el.Inspect())
+			}
+			value[i] = byte(b.Value)
+		}
+		return &object.Bytes{Value: value}
+	default:
+		return newError("argument to 'bytes()' not supported, got %s", args[0].Type())
+	}
+}
+
+// toString turns bytes into a string, taking them as UTF-8 without checking them
+func toString(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	b, ok := args[0].(*object.Bytes)
+	if !ok {
+		return newError("argument to 'toString()' must be BYTES, got %s", args[0].Type())
+	}
+	return &object.String{Value: string(b.Value)}
+}
+
+// readFileBytes returns the contents of the file at the path
+func readFileBytes(args ...object.Object) object.Object {
+	if !AllowFiles {
+		return newError("readFileBytes is disabled")
+	}
+	strs, err := stringArgs("readFileBytes", 1, args)
+	if err != nil {
+		return err
+	}
+	data, readErr := os.ReadFile(strs[0])
+	if readErr != nil {
+		return newError("readFileBytes: %s", readErr)
+	}
+	return &object.Bytes{Value: data}
+}
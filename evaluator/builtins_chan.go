@@ -0,0 +1,125 @@
+package evaluator
+
+import (
+	"monkey/object"
+)
+
+// maxChannelSize is the most values a channel can buffer. The buffer is allocated up front, so
+// this keeps a program from taking all the memory there is, or panicking, with one call
+const maxChannelSize = 1 << 20
+
+func init() {
+	builtins["channel"] = &object.Builtin{Fn: channel}
+	builtins["send"] = &object.Builtin{EnvFn: send}
+	builtins["receive"] = &object.Builtin{EnvFn: receive}
+	builtins["close"] = &object.Builtin{Fn: closeBuiltin}
+	builtins["select"] = &object.Builtin{EnvFn: selectBuiltin}
+}
+
+// channel makes a channel for passing values between spawned functions. The optional size is how
+// many values it buffers, 0 by default, in which case every send waits for a receive
+func channel(args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+	size := int64(0)
+	if len(args) == 1 {
+		n, ok := args[0].(*object.Integer)
+		if !ok {
+			return newError("argument to 'channel()' must be INTEGER, got %s", args[0].Type())
+		}
+		if n.Value < 0 {
+			return newError("argument to 'channel()' must not be negative, got %d", n.Value)
+		}
+		if n.Value > maxChannelSize {
+			return newError("argument to 'channel()' must not be more than %d, got %d", maxChannelSize, n.Value)
+		}
+		size = n.Value
+	}
+	return object.NewChannel(int(size))
+}
+
+// send puts the value on the channel, waiting for room if it's full. Sending on a closed channel
+// is an error
+func send(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	ch, ok := args[0].(*object.Channel)
+	if !ok {
+		return newError("first argument to 'send()' must be CHANNEL, got %s", args[0].Type())
+	}
+	if err := ch.Send(contextOf(env), args[1]); err != nil {
+		if err == object.ErrChannelClosed {
+			return newError("%s", err)
+		}
+		return newInterrupt("send interrupted: %s", err)
+	}
+	return NULL
+}
+
+// receive takes the next value from the channel, waiting for one to be sent. It returns null once
+// the channel is closed and everything sent before has been received
+func receive(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	ch, ok := args[0].(*object.Channel)
+	if !ok {
+		return newError("argument to 'receive()' must be CHANNEL, got %s", args[0].Type())
+	}
+	value, ok, err := ch.Receive(contextOf(env))
+	if err != nil {
+		return newInterrupt("receive interrupted: %s", err)
+	}
+	if !ok {
+		return NULL
+	}
+	return value
+}
+
+// closeBuiltin closes the channel, waking up everyone waiting on it. It returns true if the
+// channel was still open
+func closeBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	ch, ok := args[0].(*object.Channel)
+	if !ok {
+		return newError("argument to 'close()' must be CHANNEL, got %s", args[0].Type())
+	}
+	return nativeBoolToBooleanObject(ch.Close())
+}
+
+// selectBuiltin waits on an array of channels until one of them can be received from, and
+// returns [index, value] for it. Like receive, the value is null for a closed and empty channel
+func selectBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to 'select()' must be ARRAY, got %s", args[0].Type())
+	}
+	elements := arr.Snapshot()
+	if len(elements) == 0 {
+		return newError("argument to 'select()' must not be empty")
+	}
+	channels := make([]*object.Channel, len(elements))
+	for i, el := range elements {
+		ch, ok := el.(*object.Channel)
+		if !ok {
+			return newError("element %d of the argument to 'select()' must be CHANNEL, got %s", i, el.Type())
+		}
+		channels[i] = ch
+	}
+
+	index, value, ok, err := object.Select(contextOf(env), channels)
+	if err != nil {
+		return newInterrupt("select interrupted: %s", err)
+	}
+	if !ok {
+		value = NULL
+	}
+	return &object.Array{Elements: []object.Object{&object.Integer{Value: int64(index)}, value}}
+}
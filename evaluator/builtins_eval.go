@@ -0,0 +1,51 @@
+package evaluator
+
+import (
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+)
+
+func init() {
+	builtins["eval"] = &object.Builtin{EnvFn: evalBuiltin}
+}
+
+// evalBuiltin parses and evaluates the code in the environment it's called in, so the code sees
+// the bindings of the caller and its lets are the caller's. With a hash as second argument, the
+// code is evaluated in a fresh environment with the keys of the hash bound to its values instead,
+// which is still as many calls deep and interrupted like the caller's. The result is the value of the code, or an error if it doesn't parse or fails
+func evalBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	code, ok := args[0].(*object.String)
+	if !ok {
+		return newError("first argument to 'eval()' must be STRING, got %s", args[0].Type())
+	}
+	if len(args) == 2 {
+		bindings, ok := args[1].(*object.Hash)
+		if !ok {
+			return newError("second argument to 'eval()' must be HASH, got %s", args[1].Type())
+		}
+		env = object.NewCallEnvironment(object.NewEnvironment(), env.Depth(), env.Context())
+		for _, pair := range bindings.SortedPairs() {
+			name, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError("names bound by 'eval()' must be STRING, got %s", pair.Key.Type())
+			}
+			env.Set(name.Value, pair.Value)
+		}
+	}
+
+	p := parser.New(lexer.New(code.Value))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		return newError("eval: %s", strings.Join(errs, "; "))
+	}
+	result := Eval(program, env)
+	if result == nil {
+		return NULL
+	}
+	return result
+}
@@ -0,0 +1,107 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/object"
+	"reflect"
+	"strings"
+)
+
+func init() {
+	builtins["waitAll"] = &object.Builtin{EnvFn: waitAll}
+	builtins["race"] = &object.Builtin{EnvFn: race}
+}
+
+// waitAll waits for every future of the array and returns their results in the same order. If
+// any of them failed or was cancelled, it fails with the errors of all those, after waiting for
+// the rest
+func waitAll(env *object.Environment, args ...object.Object) object.Object {
+	futures, err := futureArgs("waitAll", args)
+	if err != nil {
+		return err
+	}
+
+	ctx := contextOf(env)
+	results := make([]object.Object, len(futures))
+	var failures []string
+	for i, future := range futures {
+		select {
+		case <-future.Done():
+		case <-ctx.Done():
+			return newInterrupt("waitAll interrupted: %s", ctx.Err())
+		}
+		results[i] = settled(future)
+		if err, ok := results[i].(*object.Error); ok {
+			failures = append(failures, fmt.Sprintf("%d: %s", i, err.Message))
+		}
+	}
+	if len(failures) > 0 {
+		return newError("waitAll: %d of %d futures failed: %s", len(failures), len(futures), strings.Join(failures, "; "))
+	}
+	return &object.Array{Elements: results}
+}
+
+// race waits for the first of the futures of the array to be done and returns its result, or its
+// error if it failed or was cancelled. The other futures are cancelled, their results aren't
+// wanted anymore
+func race(env *object.Environment, args ...object.Object) object.Object {
+	futures, err := futureArgs("race", args)
+	if err != nil {
+		return err
+	}
+	if len(futures) == 0 {
+		return newError("argument to 'race()' must not be empty")
+	}
+
+	// one case for every future, then comes ctx
+	ctx := contextOf(env)
+	cases := make([]reflect.SelectCase, 0, len(futures)+1)
+	for _, future := range futures {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(future.Done())})
+	}
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+
+	chosen, _, _ := reflect.Select(cases)
+	if chosen == len(futures) {
+		return newInterrupt("race interrupted: %s", ctx.Err())
+	}
+	for i, future := range futures {
+		if i != chosen {
+			future.Cancel()
+		}
+	}
+	return settled(futures[chosen])
+}
+
+// futureArgs checks that the builtin called name got a single array of futures, and returns them
+func futureArgs(name string, args []object.Object) ([]*object.Future, *object.Error) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, newError("argument to '%s()' must be ARRAY, got %s", name, args[0].Type())
+	}
+	elements := arr.Snapshot()
+	futures := make([]*object.Future, len(elements))
+	for i, el := range elements {
+		future, ok := el.(*object.Future)
+		if !ok {
+			return nil, newError("element %d of the argument to '%s()' must be FUTURE, got %s", i, name, el.Type())
+		}
+		futures[i] = future
+	}
+	return futures, nil
+}
+
+// settled is the result of a future that's done, as await returns it
+func settled(future *object.Future) object.Object {
+	result, ok := future.Wait()
+	if !ok {
+		return newError("future was cancelled")
+	}
+	if result == nil {
+		return NULL
+	}
+	return result
+}
@@ -0,0 +1,139 @@
+package evaluator
+
+import (
+	"math"
+	"monkey/object"
+)
+
+func init() {
+	builtins["abs"] = &object.Builtin{Fn: abs}
+	builtins["floor"] = &object.Builtin{Fn: floatFunction("floor", math.Floor)}
+	builtins["ceil"] = &object.Builtin{Fn: floatFunction("ceil", math.Ceil)}
+	builtins["sqrt"] = &object.Builtin{Fn: sqrt}
+	builtins["pow"] = &object.Builtin{Fn: pow}
+	builtins["min"] = &object.Builtin{Fn: extremum("min", "<")}
+	builtins["max"] = &object.Builtin{Fn: extremum("max", ">")}
+}
+
+// numberArg checks that the builtin called name got a single argument, a number
+func numberArg(name string, args []object.Object) *object.Error {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	if !isNumber(args[0]) {
+		return newError("argument to '%s()' must be INTEGER or FLOAT, got %s", name, args[0].Type())
+	}
+	return nil
+}
+
+func abs(args ...object.Object) object.Object {
+	if err := numberArg("abs", args); err != nil {
+		return err
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		if arg.Value < 0 {
+			return evalMinusPrefixOperatorExpression(arg)
+		}
+	case *object.Float:
+		return &object.Float{Value: math.Abs(arg.Value)}
+	}
+	return args[0]
+}
+
+// floatFunction makes a builtin of a rounding function like math.Floor. Integers are already
+// round, so they're returned as they are, floats stay floats
+func floatFunction(name string, f func(float64) float64) func(args ...object.Object) object.Object {
+	return func(args ...object.Object) object.Object {
+		if err := numberArg(name, args); err != nil {
+			return err
+		}
+		if arg, ok := args[0].(*object.Float); ok {
+			return &object.Float{Value: f(arg.Value)}
+		}
+		return args[0]
+	}
+}
+
+// sqrt always returns a float, the square root of a negative number is an error
+func sqrt(args ...object.Object) object.Object {
+	if err := numberArg("sqrt", args); err != nil {
+		return err
+	}
+	x := toFloat(args[0])
+	if x < 0 {
+		return newError("square root of negative number: %s", args[0].Inspect())
+	}
+	return &object.Float{Value: math.Sqrt(x)}
+}
+
+// pow raises base to the exponent. With integers, and an exponent that isn't negative, the result
+// is an integer, and overflowing it an error. Otherwise it's a float
+func pow(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	for i, arg := range args {
+		if !isNumber(arg) {
+			return newError("%s argument to 'pow()' must be INTEGER or FLOAT, got %s", ordinals[i+1], arg.Type())
+		}
+	}
+	base, ok1 := args[0].(*object.Integer)
+	exp, ok2 := args[1].(*object.Integer)
+	if !ok1 || !ok2 || exp.Value < 0 {
+		return &object.Float{Value: math.Pow(toFloat(args[0]), toFloat(args[1]))}
+	}
+
+	// exponentiation by squaring, checking every multiplication that matters
+	result, b := int64(1), base.Value
+	overflowed := false
+	for e := exp.Value; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			result, overflowed = mulInt64(result, b)
+			if overflowed {
+				return newError("integer overflow: pow(%d, %d)", base.Value, exp.Value)
+			}
+		}
+		if e > 1 {
+			b, overflowed = mulInt64(b, b)
+			if overflowed {
+				return newError("integer overflow: pow(%d, %d)", base.Value, exp.Value)
+			}
+		}
+	}
+	return &object.Integer{Value: result}
+}
+
+// mulInt64 multiplies a and b, reporting whether the product overflowed
+func mulInt64(a, b int64) (int64, bool) {
+	product := a * b
+	return product, a != 0 && (product/a != b || a == -1 && b == math.MinInt64)
+}
+
+// extremum makes min or max: the builtin takes one or more numbers, or a single array of them, and
+// returns the one that compares to all others with operator
+func extremum(name, operator string) func(args ...object.Object) object.Object {
+	return func(args ...object.Object) object.Object {
+		if len(args) == 1 {
+			if arr, ok := args[0].(*object.Array); ok {
+				args = arr.Snapshot()
+				if len(args) == 0 {
+					return newError("argument to '%s()' must not be an empty ARRAY", name)
+				}
+			}
+		}
+		if len(args) == 0 {
+			return newError("wrong number of arguments. got=0, want at least 1")
+		}
+		result := args[0]
+		for _, arg := range args {
+			if !isNumber(arg) {
+				return newError("arguments to '%s()' must be INTEGER or FLOAT, got %s", name, arg.Type())
+			}
+			if evalInfixExpression(operator, arg, result) == TRUE {
+				result = arg
+			}
+		}
+		return result
+	}
+}
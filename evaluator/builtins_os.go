@@ -0,0 +1,121 @@
+package evaluator
+
+import (
+	"bytes"
+	"errors"
+	"monkey/object"
+	"os"
+	"os/exec"
+)
+
+// Args are the arguments of the program, for the args builtin. The monkey command sets them to the
+// command line arguments following the script
+var Args []string
+
+// AllowExec enables the exec builtin. It's off by default, so a program embedding the evaluator
+// doesn't hand scripts a shell unless it means to. The monkey command turns it on
+var AllowExec = false
+
+// AllowEnv enables the env and setEnv builtins, which are off by default like exec: the environment
+// of the host process can hold secrets, and changing it affects the whole process
+var AllowEnv = false
+
+func init() {
+	builtins["exec"] = &object.Builtin{EnvFn: execBuiltin}
+	builtins["env"] = &object.Builtin{Fn: env}
+	builtins["setEnv"] = &object.Builtin{Fn: setEnv}
+	builtins["args"] = &object.Builtin{Fn: argsBuiltin}
+}
+
+// env returns the value of the environment variable, null if it isn't set
+func env(args ...object.Object) object.Object {
+	if !AllowEnv {
+		return newError("env is disabled")
+	}
+	strs, err := stringArgs("env", 1, args)
+	if err != nil {
+		return err
+	}
+	value, ok := os.LookupEnv(strs[0])
+	if !ok {
+		return NULL
+	}
+	return &object.String{Value: value}
+}
+
+// setEnv sets the environment variable, for the rest of the program and the commands it execs
+func setEnv(args ...object.Object) object.Object {
+	if !AllowEnv {
+		return newError("setEnv is disabled")
+	}
+	strs, err := stringArgs("setEnv", 2, args)
+	if err != nil {
+		return err
+	}
+	if err := os.Setenv(strs[0], strs[1]); err != nil {
+		return newError("setEnv %s: %s", strs[0], err)
+	}
+	return NULL
+}
+
+// argsBuiltin returns Args as an array of strings
+func argsBuiltin(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	elements := make([]object.Object, len(Args))
+	for i, arg := range Args {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// execBuiltin runs a command with the remaining arguments, without a shell in between, and waits
+// for it. It returns a hash with the "stdout" and "stderr" output of the command and its exit
+// "code". A command that fails is no error, one that can't be started is
+func execBuiltin(env *object.Environment, args ...object.Object) object.Object {
+	if !AllowExec {
+		return newError("exec is disabled")
+	}
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+	}
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return newError("arguments to 'exec()' must be STRING, got %s", arg.Type())
+		}
+		strs[i] = str.Value
+	}
+
+	var stdout, stderr bytes.Buffer
+	ctx := contextOf(env)
+	cmd := exec.CommandContext(ctx, strs[0], strs[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	code := 0
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return newInterrupt("exec interrupted: %s", ctx.Err())
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return newError("exec %s: %s", strs[0], err)
+		}
+		code = exitErr.ExitCode()
+	}
+
+	result := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	for _, field := range []struct {
+		name  string
+		value object.Object
+	}{
+		{"stdout", &object.String{Value: stdout.String()}},
+		{"stderr", &object.String{Value: stderr.String()}},
+		{"code", &object.Integer{Value: int64(code)}},
+	} {
+		key := &object.String{Value: field.name}
+		result.Set(object.HashPair{Key: key, Value: field.value})
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package evaluator
+
+import (
+	"math"
+	"math/rand"
+	"monkey/object"
+	"sync"
+	"time"
+)
+
+// random is the source of rand and randInt, seeded from the clock until a program calls seed.
+// Spawned functions can use it concurrently, hence the mutex
+var random = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+func init() {
+	builtins["rand"] = &object.Builtin{Fn: randBuiltin}
+	builtins["randInt"] = &object.Builtin{Fn: randInt}
+	builtins["seed"] = &object.Builtin{Fn: seed}
+}
+
+// randBuiltin returns a random float in [0, 1)
+func randBuiltin(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	random.Lock()
+	defer random.Unlock()
+	return &object.Float{Value: random.Float64()}
+}
+
+// randInt returns a random integer between min and max, both included
+func randInt(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	min, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("first argument to 'randInt()' must be INTEGER, got %s", args[0].Type())
+	}
+	max, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError("second argument to 'randInt()' must be INTEGER, got %s", args[1].Type())
+	}
+	if min.Value > max.Value {
+		return newError("randInt() min must not be greater than max, got %d and %d", min.Value, max.Value)
+	}
+
+	random.Lock()
+	defer random.Unlock()
+	// max-min can overflow int64, but then it wraps around to the right uint64
+	span := uint64(max.Value - min.Value)
+	if span < math.MaxInt64 {
+		return &object.Integer{Value: min.Value + random.Int63n(int64(span)+1)}
+	}
+	for { // more than half of all int64s fit, so this rarely takes more than a couple of draws
+		if n := random.Uint64(); n <= span {
+			return &object.Integer{Value: min.Value + int64(n)}
+		}
+	}
+}
+
+// seed makes rand and randInt produce the same sequence every time they're seeded with n
+func seed(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	n, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("argument to 'seed()' must be INTEGER, got %s", args[0].Type())
+	}
+	random.Lock()
+	defer random.Unlock()
+	random.Seed(n.Value)
+	return NULL
+}
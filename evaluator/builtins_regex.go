@@ -0,0 +1,102 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"regexp"
+)
+
+func init() {
+	builtins["regex"] = &object.Builtin{Fn: regex}
+	builtins["regexMatch"] = &object.Builtin{Fn: regexMatch}
+	builtins["regexFind"] = &object.Builtin{Fn: regexFind}
+	builtins["regexReplace"] = &object.Builtin{Fn: regexReplace}
+}
+
+// regex compiles the pattern into a Regex, which saves compiling it again on every use
+func regex(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	pattern, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to 'regex()' must be STRING, got %s", args[0].Type())
+	}
+	re, err := regexp.Compile(pattern.Value)
+	if err != nil {
+		return newError("invalid regex: %s", err)
+	}
+	return &object.Regex{Regexp: re}
+}
+
+// regexArgs checks that the builtin called name got n arguments: a Regex, or a string pattern to
+// compile, followed by strings. It returns the regexp and the values of the strings
+func regexArgs(name string, n int, args []object.Object) (*regexp.Regexp, []string, *object.Error) {
+	if len(args) != n {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+	var re *regexp.Regexp
+	switch arg := args[0].(type) {
+	case *object.Regex:
+		re = arg.Regexp
+	case *object.String:
+		var err error
+		if re, err = regexp.Compile(arg.Value); err != nil {
+			return nil, nil, newError("invalid regex: %s", err)
+		}
+	default:
+		return nil, nil, newError("first argument to '%s()' must be REGEX or STRING, got %s", name, arg.Type())
+	}
+	strs := make([]string, n-1)
+	for i, arg := range args[1:] {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, nil, newError("%s argument to '%s()' must be STRING, got %s", ordinals[i+2], name, arg.Type())
+		}
+		strs[i] = str.Value
+	}
+	return re, strs, nil
+}
+
+// regexMatch reports whether the regex matches anywhere in the string
+func regexMatch(args ...object.Object) object.Object {
+	re, strs, err := regexArgs("regexMatch", 2, args)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(re.MatchString(strs[0]))
+}
+
+// regexFind returns the leftmost match in the string as an array: the whole match followed by
+// the submatches of the groups, with null for groups that didn't take part. It's null if there's
+// no match
+func regexFind(args ...object.Object) object.Object {
+	re, strs, err := regexArgs("regexFind", 2, args)
+	if err != nil {
+		return err
+	}
+	indexes := re.FindStringSubmatchIndex(strs[0])
+	if indexes == nil {
+		return NULL
+	}
+	elements := make([]object.Object, len(indexes)/2)
+	for i := range elements {
+		start, end := indexes[2*i], indexes[2*i+1]
+		if start < 0 {
+			elements[i] = NULL
+		} else {
+			elements[i] = &object.String{Value: strs[0][start:end]}
+		}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// regexReplace replaces every match in the string with the replacement, in which $1 or ${name}
+// stand for the submatches of groups. In a string literal the latter is written "\${name}", as
+// ${ starts an interpolation
+func regexReplace(args ...object.Object) object.Object {
+	re, strs, err := regexArgs("regexReplace", 3, args)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: re.ReplaceAllString(strs[0], strs[1])}
+}
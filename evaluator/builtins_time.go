@@ -0,0 +1,104 @@
+package evaluator
+
+import (
+	"context"
+	"monkey/object"
+	"time"
+)
+
+// Context is the context programs are evaluated in. A host can cancel it to interrupt a program
+// that's waiting, eg in sleep, or looping. Spawned functions run under a context of their own
+// derived from it, see contextOf
+var Context = context.Background()
+
+// contextOf is the context code run in env is interrupted by: the one of a spawned function it's
+// part of, or Context
+func contextOf(env *object.Environment) context.Context {
+	if env != nil && env.Context() != nil {
+		return env.Context()
+	}
+	return Context
+}
+
+// interrupted returns an error if the context of env is done, for code that can't wait on it
+// like sleep does, eg loops, to check every so often
+func interrupted(env *object.Environment) *object.Error {
+	if err := contextOf(env).Err(); err != nil {
+		return newInterrupt("interrupted: %s", err)
+	}
+	return nil
+}
+
+// clockStart is what clock measures from. Durations between times taken from it use the
+// monotonic clock, so they aren't thrown off by changes to the wall clock
+var clockStart = time.Now()
+
+func init() {
+	builtins["now"] = &object.Builtin{Fn: now}
+	builtins["clock"] = &object.Builtin{Fn: clock}
+	builtins["formatTime"] = &object.Builtin{Fn: formatTime}
+	builtins["sleep"] = &object.Builtin{EnvFn: sleep}
+}
+
+// now returns the wall clock time in milliseconds since the Unix epoch
+func now(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return &object.Integer{Value: time.Now().UnixMilli()}
+}
+
+// clock returns the milliseconds since the program started, as a float for sub-millisecond
+// precision. Only the difference between two clocks means anything, eg for benchmarks
+func clock(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+	return &object.Float{Value: float64(time.Since(clockStart).Nanoseconds()) / 1e6}
+}
+
+// formatTime formats a time in milliseconds since the Unix epoch, like now returns, in UTC. The
+// optional layout is a Go time layout, RFC 3339 with milliseconds by default
+func formatTime(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	ms, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("first argument to 'formatTime()' must be INTEGER, got %s", args[0].Type())
+	}
+	layout := "2006-01-02T15:04:05.000Z07:00"
+	if len(args) == 2 {
+		str, ok := args[1].(*object.String)
+		if !ok {
+			return newError("second argument to 'formatTime()' must be STRING, got %s", args[1].Type())
+		}
+		layout = str.Value
+	}
+	return &object.String{Value: time.UnixMilli(ms.Value).UTC().Format(layout)}
+}
+
+// sleep pauses for the given number of milliseconds. If the context it runs under is done before
+// that, it stops early with an error
+func sleep(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	ms, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("argument to 'sleep()' must be INTEGER, got %s", args[0].Type())
+	}
+	if ms.Value < 0 {
+		return newError("argument to 'sleep()' must not be negative, got %d", ms.Value)
+	}
+
+	ctx := contextOf(env)
+	timer := time.NewTimer(time.Duration(ms.Value) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return NULL
+	case <-ctx.Done():
+		return newInterrupt("sleep interrupted: %s", ctx.Err())
+	}
+}
@@ -1,9 +1,13 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"math"
 	"monkey/ast"
 	"monkey/object"
+	"monkey/token"
 )
 
 // Instead of using new instances of true and false each time, reference them instead
@@ -13,7 +17,32 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// IndexPolicy decides what indexing an array outside of its bounds evaluates to
+type IndexPolicy int
+
+const (
+	IndexNull  IndexPolicy = iota // null, the default
+	IndexError                    // an "index out of range" error
+)
+
+// OutOfRange is the IndexPolicy of the evaluator
+var OutOfRange = IndexNull
+
+// MaxDepth is how deeply function calls can nest before the evaluator gives up with a "stack
+// overflow" error, rather than letting runaway recursion crash the program it's embedded in. Calls
+// in tail position don't nest. 0 means there's no limit
+var MaxDepth = 10000
+
+// Eval evaluates node in env. An error gets the position of the innermost node it comes from
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = position(node)
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 
 	switch node := node.(type) {
 
@@ -23,21 +52,54 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		// every block is a scope of its own, so a let in the body of an if or a loop doesn't leak
+		return evalBlockStatement(node, object.NewEnclosedEnvironment(env))
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.SelectExpression:
+		return evalSelectExpression(node, env)
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if str, ok := val.(*object.String); ok {
+			return &object.Error{Message: str.Value, Value: val}
+		}
+		return &object.Error{Message: val.Inspect(), Value: val}
+	case *ast.DeferStatement:
+		env.Defer(node.Value)
+		return NULL
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+	case *ast.BreakStatement:
+		return &object.Break{Pos: node.Token.Position}
+	case *ast.ContinueStatement:
+		return &object.Continue{Pos: node.Token.Position}
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
+		if node.Const {
+			object.Freeze(val)
+			env.SetConst(node.Name.Value, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
@@ -46,8 +108,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	// Expressions
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
+	case *ast.NullLiteral:
+		return NULL
+	case *ast.ObjectExpression:
+		return node.Value.(object.Object)
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 		if isError(right) {
@@ -55,6 +123,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -67,17 +138,24 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		function, args := evalCall(node, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		result := applyFunction(function, args, env)
+		if recordFailure(function, result, position(node)) {
+			return NULL
 		}
-		return applyFunction(function, args)
+		if err, ok := result.(*object.Error); ok {
+			if fn, ok := function.(*object.Function); ok {
+				err.Stack = append(err.Stack, object.StackFrame{Function: fn.Name, Pos: position(node)})
+			}
+		}
+		return result
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -94,8 +172,32 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.RangeExpression:
+		return evalRangeExpression(node, env)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.AssignExpression:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if !env.Assign(node.Name.Value, val) {
+			if env.IsConst(node.Name.Value) {
+				return newError("cannot assign to constant: %s", node.Name.Value)
+			}
+			return newError("assignment to undeclared identifier: %s", node.Name.Value)
+		}
+		return val
+	case *ast.IndexAssignExpression:
+		return evalIndexAssignExpression(node, env)
+	case *ast.MatchExpression:
+		// parsed, but not implemented yet
+		return newError("match expressions are not supported")
+	case *ast.MacroLiteral:
+		// parsed, but macro expansion isn't implemented
+		return newError("macro literals are not supported")
 	}
 	return nil
 }
@@ -133,19 +235,38 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right): // at least one of them a float
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
-	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
+// evalLogicalExpression evaluates && and ||. The right operand is only evaluated when the left
+// one doesn't decide the result already, the result is always a boolean
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if isTruthy(left) == (node.Operator == "||") {
+		return nativeBoolToBooleanObject(isTruthy(left))
+	}
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
 func evalBangOperatorExpression(right object.Object) object.Object {
 	switch right {
 	case TRUE:
@@ -160,12 +281,17 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return newError("integer overflow: -%d", right.Value)
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
@@ -174,13 +300,36 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		sum := leftVal + rightVal
+		if (sum > leftVal) != (rightVal > 0) {
+			return overflowError(left, operator, right)
+		}
+		return &object.Integer{Value: sum}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		diff := leftVal - rightVal
+		if (diff < leftVal) != (rightVal > 0) {
+			return overflowError(left, operator, right)
+		}
+		return &object.Integer{Value: diff}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		product := leftVal * rightVal
+		if leftVal != 0 && (product/leftVal != rightVal || leftVal == -1 && rightVal == math.MinInt64) {
+			return overflowError(left, operator, right)
+		}
+		return &object.Integer{Value: product}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / 0", leftVal)
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return overflowError(left, operator, right)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %d %% 0", leftVal)
+		}
+		return &object.Integer{Value: leftVal % rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -194,6 +343,57 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	}
 }
 
+// Integers are 64 bits wide. Rather than silently wrapping around, arithmetic that doesn't fit is
+// an error
+func overflowError(left object.Object, operator string, right object.Object) *object.Error {
+	return newError("integer overflow: %s %s %s", left.Inspect(), operator, right.Inspect())
+}
+
+// evalFloatInfixExpression does float arithmetic and comparisons. An integer operand is promoted
+// to a float first, so 1 + 0.5 is 1.5 and 1 == 1.0
+func evalFloatInfixExpression(operator string, leftVal, rightVal float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %s / 0", (&object.Float{Value: leftVal}).Inspect())
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %s %% 0", (&object.Float{Value: leftVal}).Inspect())
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", object.FLOAT_OBJ, operator, object.FLOAT_OBJ)
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat converts an Integer or Float to a float64
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
@@ -208,29 +408,159 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+// evalProgram evaluates the statements in order up to the first return or error. Expressions
+// deferred outside of any function are evaluated at the end. So are those the program deferred in
+// the call it's evaluated in by eval, but not the ones the call deferred before
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
+	mark := env.NumDeferred()
 
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = loopControlError(Eval(statement, env))
 
-		switch result := result.(type) {
-		case *object.ReturnValue:
-			return result.Value
-		case *object.Error:
+		if returnValue, ok := result.(*object.ReturnValue); ok {
+			result = returnValue.Value
+			break
+		}
+		if isError(result) {
+			break
+		}
+	}
+	return runDeferred(env.TakeDeferredSince(mark), result)
+}
+
+// runDeferred evaluates deferred expressions last one first, once the call that deferred them ended
+// with result. They all run even if some fail. The first error of theirs becomes the result,
+// unless the call failed already
+func runDeferred(deferred []object.Deferred, result object.Object) object.Object {
+	for i := len(deferred) - 1; i >= 0; i-- {
+		val := Eval(deferred[i].Expr, deferred[i].Env)
+		if isError(val) && !isError(result) {
+			result = val
+		}
+	}
+	return result
+}
+
+// evalWhileStatement runs the body for as long as the condition holds. A break in the body ends
+// the loop, a continue the current iteration. The loop itself evaluates to null
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		if err := interrupted(env); err != nil {
+			return err
+		}
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		switch result := Eval(ws.Body, env).(type) {
+		case *object.Break:
+			return NULL
+		case *object.ReturnValue, *object.Error:
 			return result
 		}
 	}
+}
+
+// evalForInStatement runs the body once for every element of an object.Iterable, with the loop
+// variables bound in a scope of their own for each. `for (k, v in x)` binds the key and the value,
+// `for (v in x)` the value alone, except for a hash, where it's the key. Like a while loop, the
+// loop itself evaluates to null
+func evalForInStatement(fs *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	it, ok := iterable.(object.Iterable)
+	if !ok {
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	var result object.Object = NULL
+	body := func(loopEnv *object.Environment) bool {
+		if err := interrupted(loopEnv); err != nil {
+			result = err
+			return false
+		}
+		switch r := Eval(fs.Body, loopEnv).(type) {
+		case *object.Break:
+			return false
+		case *object.ReturnValue, *object.Error:
+			result = r
+			return false
+		}
+		return true
+	}
+	var err *object.Error
+	if fs.Key != nil {
+		err = iterate(env, it, func(key, value object.Object) bool {
+			loopEnv := object.NewEnclosedEnvironment(env)
+			loopEnv.Set(fs.Key.Value, key)
+			loopEnv.Set(fs.Value.Value, value)
+			return body(loopEnv)
+		})
+	} else {
+		err = iterateValues(env, it, func(value object.Object) bool {
+			loopEnv := object.NewEnclosedEnvironment(env)
+			loopEnv.Set(fs.Value.Value, value)
+			return body(loopEnv)
+		})
+	}
+	if err != nil {
+		return err
+	}
 	return result
 }
 
+// iterate is it.Iterate for code run in env. Waiting on a channel for its next value is given
+// up with an error once the context of env is done
+func iterate(env *object.Environment, it object.Iterable, fn func(key, value object.Object) bool) *object.Error {
+	ch, ok := it.(*object.Channel)
+	if !ok {
+		it.Iterate(fn)
+		return nil
+	}
+	ctx := contextOf(env)
+	if err := ch.IterateContext(ctx, fn); err != nil {
+		return newInterrupt("receive interrupted: %s", err)
+	}
+	return nil
+}
+
+// iterateValues calls fn with what a one variable for-in loop binds for each element of it: its
+// value, or its key for a hash. Like iterate, it fails if it's interrupted
+func iterateValues(env *object.Environment, it object.Iterable, fn func(value object.Object) bool) *object.Error {
+	_, isHash := it.(*object.Hash)
+	return iterate(env, it, func(key, value object.Object) bool {
+		if isHash {
+			return fn(key)
+		}
+		return fn(value)
+	})
+}
+
+// loopControlError turns a break or continue that made it out of every loop into an error
+func loopControlError(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Break:
+		return &object.Error{Message: "break outside of a loop", Pos: obj.Pos}
+	case *object.Continue:
+		return &object.Error{Message: "continue outside of a loop", Pos: obj.Pos}
+	}
+	return obj
+}
+
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range block.Statements {
 		result = Eval(statement, env)
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -238,6 +568,159 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
+// evalTailBlock evaluates the body of a function like evalBlockStatement, except that the calls in
+// tail position aren't made but returned as an *object.TailCall. The value of a return statement
+// isn't wrapped in an *object.ReturnValue either, the function ends with it anyway
+func evalTailBlock(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+	for i, statement := range block.Statements {
+		switch statement := statement.(type) {
+		case *ast.ReturnStatement:
+			return evalTail(statement.ReturnValue, env)
+		case *ast.ExpressionStatement:
+			if i == len(block.Statements)-1 {
+				return evalTail(statement.Expression, env)
+			}
+		}
+		result = Eval(statement, env)
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// evalTail evaluates an expression in tail position of a function body: a call of a Function
+// becomes an *object.TailCall, the branches of an if are in tail position themselves
+func evalTail(exp ast.Expression, env *object.Environment) object.Object {
+	var result object.Object
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		function, args := evalCall(exp, env)
+		if isError(function) {
+			return function
+		}
+		if fn, ok := function.(*object.Function); ok {
+			return &object.TailCall{Function: fn, Arguments: args, Pos: position(exp)}
+		}
+		result = applyFunction(function, args, env)
+		if recordFailure(function, result, position(exp)) {
+			return NULL
+		}
+	case *ast.IfExpression:
+		condition := Eval(exp.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			result = evalTailBlock(exp.Consequence, object.NewEnclosedEnvironment(env))
+		} else if exp.Alternative != nil {
+			result = evalTailBlock(exp.Alternative, object.NewEnclosedEnvironment(env))
+		} else {
+			result = NULL
+		}
+	default:
+		return Eval(exp, env)
+	}
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = position(exp)
+	}
+	return result
+}
+
+// evalTryExpression runs the block and, if an error comes out of it, the catch block with the
+// error bound to the catch parameter: the value that was thrown, or the message of an error the
+// interpreter raised. Returns, breaks and continues go through to the code around the try, and so
+// do errors of interrupted code, which has to stop whatever it's in. A block that ends without a
+// value, eg with a let, evaluates to null
+func evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(node.Block, env)
+	if result == nil {
+		return NULL
+	}
+	err, ok := result.(*object.Error)
+	if !ok || err.Interrupted {
+		return result
+	}
+	caught := err.Value
+	if caught == nil {
+		caught = &object.String{Value: err.Message}
+	}
+	catchEnv := object.NewEnclosedEnvironment(env)
+	catchEnv.Set(node.CatchParam.Value, caught)
+	result = Eval(node.CatchBlock, catchEnv)
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// evalSelectExpression evaluates the channels, and the values of the sends, of every case in order,
+// then waits until one of the cases can go ahead and evaluates to its body, with the received
+// value bound for `case v = recv(c)`. Like receive, that's null for a closed and empty channel.
+// The default case, if there is one, runs when no other case can go ahead right away
+func evalSelectExpression(node *ast.SelectExpression, env *object.Environment) object.Object {
+	var ops []object.ChannelOp
+	var cases []*ast.SelectCase // the case of each op
+	var defaultCase *ast.SelectCase
+	for _, c := range node.Cases {
+		if c.Channel == nil {
+			defaultCase = c
+			continue
+		}
+		channel := Eval(c.Channel, env)
+		if isError(channel) {
+			return channel
+		}
+		ch, ok := channel.(*object.Channel)
+		if !ok {
+			err := newError("argument to '%s()' in select must be CHANNEL, got %s", c.Op.Literal, channel.Type())
+			err.Pos = c.Op.Position
+			return err
+		}
+		op := object.ChannelOp{Channel: ch}
+		if c.Value != nil {
+			value := Eval(c.Value, env)
+			if isError(value) {
+				return value
+			}
+			op.Send, op.Value = true, value
+		}
+		ops = append(ops, op)
+		cases = append(cases, c)
+	}
+
+	index, value, ok, err := object.SelectOps(contextOf(env), ops, defaultCase == nil)
+	switch {
+	case err == object.ErrChannelClosed:
+		e := newError("%s", err)
+		e.Pos = cases[index].Op.Position
+		return e
+	case err != nil:
+		return newInterrupt("select interrupted: %s", err)
+	}
+
+	chosen := defaultCase
+	caseEnv := object.NewEnclosedEnvironment(env)
+	if index >= 0 {
+		chosen = cases[index]
+		if chosen.Name != nil {
+			if !ok {
+				value = NULL
+			}
+			caseEnv.Set(chosen.Name.Value, value)
+		}
+	}
+	result := evalBlockStatement(chosen.Body, caseEnv)
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
@@ -245,10 +728,10 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
-	return newError("identifier not found: " + node.Value)
+	return newError("identifier not found: %s", node.Value)
 }
 
-//iterate over list of ast.Expressions and evaluate them in the context of the current env
+// iterate over list of ast.Expressions and evaluate them in the context of the current env
 // if we encounter an error, stop the evaluation and return the error
 // Here it is also decided to evaluate the arguments from left-to-right
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
@@ -263,14 +746,102 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+// evalCall evaluates the callee and the arguments of a call. If either fails, function is the error
+func evalCall(node *ast.CallExpression, env *object.Environment) (function object.Object, args []object.Object) {
+	function = Eval(node.Function, env)
+	if isError(function) {
+		return function, nil
+	}
+	args = evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0], nil
+	}
+	if len(node.NamedArguments) > 0 {
+		args = evalNamedArguments(function, args, node.NamedArguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0], nil
+		}
+	}
+	return function, args
+}
+
+// evalNamedArguments slots the named arguments of a call into the parameter positions of fn,
+// after the already evaluated positional args. Like evalExpressions, an error is returned as the only element
+func evalNamedArguments(fn object.Object, args []object.Object, named []*ast.NamedArgument, env *object.Environment) []object.Object {
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return []object.Object{newError("named arguments not supported: %s", fn.Type())}
 	}
 
+	result := make([]object.Object, len(function.Parameters))
+	if len(args) > len(result) {
+		return []object.Object{newError("wrong number of arguments. got=%d, want=%d", len(args)+len(named), len(result))}
+	}
+	copy(result, args)
+
+	for _, na := range named {
+		idx := -1
+		for paramIdx, param := range function.Parameters {
+			if param.Value == na.Name.Value {
+				idx = paramIdx
+				break
+			}
+		}
+		if idx == -1 {
+			return []object.Object{newError("unknown named argument: %s", na.Name.Value)}
+		}
+		if result[idx] != nil {
+			return []object.Object{newError("argument %s given more than once", na.Name.Value)}
+		}
+		evaluated := Eval(na.Value, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result[idx] = evaluated
+	}
+
+	for paramIdx, param := range function.Parameters {
+		if result[paramIdx] == nil {
+			return []object.Object{newError("missing argument: %s", param.Value)}
+		}
+	}
+	return result
+}
+
+// evalInterpolatedString joins the text of the string with the values of its expressions, which
+// are turned into strings like str() does. The first error stops it
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out bytes.Buffer
+	for i, exp := range node.Exprs {
+		out.WriteString(node.Strings[i])
+		value := Eval(exp, env)
+		if isError(value) {
+			return value
+		}
+		if str, ok := value.(*object.String); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString(value.Inspect())
+		}
+	}
+	out.WriteString(node.Strings[len(node.Exprs)])
+	return &object.String{Value: out.String()}
+}
+
+// strings are compared by value, not by identity like arrays or hashes
+func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
@@ -279,43 +850,232 @@ func evalIndexExpression(left, index object.Object) object.Object {
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.BYTES_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalBytesIndexExpression(left, index)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
-// retrieve the elements with the specified index from the array.
-// if the given index is out of range it returns NULL
+// evalBytesIndexExpression gives the byte at the index as an integer
+func evalBytesIndexExpression(b, index object.Object) object.Object {
+	value := b.(*object.Bytes).Value
+	idx := index.(*object.Integer).Value
+	offset, ok := resolveIndex(idx, len(value))
+	if !ok {
+		return outOfRange(idx, len(value))
+	}
+	return &object.Integer{Value: int64(value[offset])}
+}
+
+// evalRangeExpression makes the lazy object.Range of start..end, both of which must be integers
+func evalRangeExpression(node *ast.RangeExpression, env *object.Environment) object.Object {
+	from := Eval(node.From, env)
+	if isError(from) {
+		return from
+	}
+	to := Eval(node.To, env)
+	if isError(to) {
+		return to
+	}
+	start, ok := from.(*object.Integer)
+	if !ok {
+		return newError("range start must be INTEGER, got %s", from.Type())
+	}
+	end, ok := to.(*object.Integer)
+	if !ok {
+		return newError("range end must be INTEGER, got %s", to.Type())
+	}
+	return &object.Range{Start: start.Value, End: end.Value}
+}
+
+// evalSliceExpression evaluates left[low:high] for arrays, strings and bytes, always making a copy.
+// A missing low is the start, a missing high the end. Negative bounds count back from the end like
+// negative indexes, bounds past either end are clamped to it
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	var length int
+	var runes []rune // the characters of a string, which is sliced by character like it's indexed
+	switch left := left.(type) {
+	case *object.Array:
+		length = len(left.Elements)
+	case *object.String:
+		runes = []rune(left.Value)
+		length = len(runes)
+	case *object.Bytes:
+		length = len(left.Value)
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	bounds := [2]int{0, length}
+	for i, exp := range []ast.Expression{node.Low, node.High} {
+		if exp == nil {
+			continue
+		}
+		bound := Eval(exp, env)
+		if isError(bound) {
+			return bound
+		}
+		n, ok := bound.(*object.Integer)
+		if !ok {
+			return newError("slice index must be INTEGER, got %s", bound.Type())
+		}
+		if n.Value < 0 {
+			n = &object.Integer{Value: n.Value + int64(length)}
+		}
+		switch {
+		case n.Value < 0:
+			bounds[i] = 0
+		case n.Value > int64(length):
+			bounds[i] = length
+		default:
+			bounds[i] = int(n.Value)
+		}
+	}
+	low, high := bounds[0], bounds[1]
+	if high < low {
+		high = low
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		return &object.Array{Elements: left.Snapshot()[low:high]}
+	case *object.String:
+		return &object.String{Value: string(runes[low:high])}
+	default:
+		return &object.Bytes{Value: append([]byte{}, left.(*object.Bytes).Value[low:high]...)}
+	}
+}
+
+// resolveIndex turns an index into a sequence of length elements into an offset from its start.
+// Negative indexes count back from the end, -1 is the last element. ok is false if the index is
+// out of range either way
+func resolveIndex(idx int64, length int) (offset int64, ok bool) {
+	if idx < 0 {
+		idx += int64(length)
+	}
+	return idx, idx >= 0 && idx < int64(length)
+}
+
+// outOfRange is what reading the element at idx of a sequence of length elements evaluates to
+// when there's no such element: NULL or an error, depending on OutOfRange
+func outOfRange(idx int64, length int) object.Object {
+	if OutOfRange == IndexError {
+		return newError("index out of range: %d with length %d", idx, length)
+	}
+	return NULL
+}
+
+// retrieve the elements with the specified index from the array
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	if idx < 0 || idx > max {
-		return NULL
+	offset, ok := resolveIndex(idx, len(arrayObject.Elements))
+	if !ok {
+		return outOfRange(idx, len(arrayObject.Elements))
+	}
+	return arrayObject.Get(int(offset))
+}
+
+// evalStringIndexExpression gives the character at the index as a string of its own. Strings are
+// indexed by character (Unicode code point), not by byte
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	offset, ok := resolveIndex(idx, len(runes))
+	if !ok {
+		return outOfRange(idx, len(runes))
 	}
-	return arrayObject.Elements[idx]
+	return &object.String{Value: string(runes[offset])}
+}
+
+// evalIndexAssignExpression changes an element of an array or the value of a key of a hash in
+// place, every binding of the array or hash sees the change. Arrays don't grow, assigning past
+// their end is an error whatever OutOfRange says
+func evalIndexAssignExpression(node *ast.IndexAssignExpression, env *object.Environment) object.Object {
+	left := Eval(node.Target.Left, env)
+	if isError(left) {
+		return left
+	}
+	index := Eval(node.Target.Index, env)
+	if isError(index) {
+		return index
+	}
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		if left.Frozen {
+			return newError("cannot modify frozen ARRAY")
+		}
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		offset, ok := resolveIndex(idx.Value, len(left.Elements))
+		if !ok {
+			return newError("index out of range: %d with length %d", idx.Value, len(left.Elements))
+		}
+		left.Set(int(offset), val)
+	case *object.Hash:
+		if left.Frozen {
+			return newError("cannot modify frozen HASH")
+		}
+		if _, ok := index.(object.Hashable); !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		left.Set(object.HashPair{Key: index, Value: val})
+	case *object.Bytes:
+		if left.Frozen {
+			return newError("cannot modify frozen BYTES")
+		}
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("bytes index must be INTEGER, got %s", index.Type())
+		}
+		offset, ok := resolveIndex(idx.Value, len(left.Value))
+		if !ok {
+			return newError("index out of range: %d with length %d", idx.Value, len(left.Value))
+		}
+		b, ok := val.(*object.Integer)
+		if !ok || b.Value < 0 || b.Value > 255 {
+			return newError("byte must be INTEGER from 0 to 255, got %s", val.Inspect())
+		}
+		left.Value[offset] = byte(b.Value)
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+	return val
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
-	for keyNode, valueNode := range node.Pairs { 
-		key := Eval(keyNode, env) // evaluate keyNode first
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env) // evaluate the key first
 		if isError(key) {
 			return key
 		}
-		hashKey, ok := key.(object.Hashable) // key is only usable as hash key if it implements the object.Hashable interface
+		_, ok := key.(object.Hashable) // key is only usable as hash key if it implements the object.Hashable interface
 		if !ok {
 			return newError("unusable as has key: %s", key.Type())
 		}
-		value := Eval(valueNode, env) // Then evaluate valueNode
+		value := Eval(pair.Value, env) // Then evaluate the value
 		if isError(value) {
 			return value
-		} // If there's no error, add teh newly produced key-value pair to our pairs map
-		hashed := hashKey.HashKey() // generate a hashKey object
-		// then initialize new HashPair with key and value
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		} // If there's no error, add the newly produced key-value pair to the hash, after the ones before it
+		hash.Set(object.HashPair{Key: key, Value: value})
 	}
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(hash object.Object, index object.Object) object.Object {
@@ -324,7 +1084,7 @@ func evalHashIndexExpression(hash object.Object, index object.Object) object.Obj
 	if !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key.HashKey())
 	if !ok {
 		return NULL
 	}
@@ -348,6 +1108,57 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// newInterrupt is newError for code that stops because its context is done, see interrupted
+func newInterrupt(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Interrupted: true}
+}
+
+// position gives where an error raised by evaluating node is reported: the operator of
+// operations, the start of the callee of calls. Nodes that can't raise errors themselves, only
+// pass on those of their children, have no position
+func position(node ast.Node) token.Position {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		return node.Token.Position
+	case *ast.PrefixExpression:
+		return node.Token.Position
+	case *ast.InfixExpression:
+		return node.Token.Position
+	case *ast.IndexExpression:
+		return node.Token.Position
+	case *ast.SliceExpression:
+		return node.Token.Position
+	case *ast.RangeExpression:
+		return node.Token.Position
+	case *ast.AssignExpression:
+		return node.Token.Position
+	case *ast.IndexAssignExpression:
+		return node.Token.Position
+	case *ast.HashLiteral:
+		return node.Token.Position
+	case *ast.ThrowStatement:
+		return node.Token.Position
+	case *ast.WhileStatement:
+		return node.Token.Position
+	case *ast.SelectExpression:
+		return node.Token.Position
+	case *ast.MatchExpression:
+		return node.Token.Position
+	case *ast.MacroLiteral:
+		return node.Token.Position
+	case *ast.ForInStatement:
+		return node.Token.Position
+	case *ast.FunctionLiteral:
+		return node.Token.Position
+	case *ast.CallExpression:
+		if pos := position(node.Function); pos.IsValid() {
+			return pos
+		}
+		return node.Token.Position
+	}
+	return token.Position{}
+}
+
 // We must check for errors whenever we call Eval inside of Eval, in order
 // to stop errors from being passed around and then bubbling up far away
 // from their origin
@@ -358,19 +1169,79 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+// equalObjects reports whether a and b have the same value. Numbers and strings are compared by
+// value, everything else by identity
+func equalObjects(a, b object.Object) bool {
+	switch {
+	case a.Type() == object.INTEGER_OBJ && b.Type() == object.INTEGER_OBJ:
+		return a.(*object.Integer).Value == b.(*object.Integer).Value
+	case isNumber(a) && isNumber(b):
+		return toFloat(a) == toFloat(b)
+	case a.Type() == object.STRING_OBJ && b.Type() == object.STRING_OBJ:
+		return a.(*object.String).Value == b.(*object.String).Value
+	case a.Type() == object.BYTES_OBJ && b.Type() == object.BYTES_OBJ:
+		return bytes.Equal(a.(*object.Bytes).Value, b.(*object.Bytes).Value)
+	}
+	return a == b
+}
+
 // check that we really have an *object.Function at hand
 // also convert the fn parameter to an *object.Function reference in order to get access to the fn's .Env and .Body fields (which object.Object doesn't have)
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// caller is the environment the call is made in, nil for calls from Go code outside of any
+func applyFunction(fn object.Object, args []object.Object, caller *object.Environment) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+		depth := 1 // the number of calls the call is nested in, counting itself
+		var ctx context.Context
+		if caller != nil {
+			depth = caller.Depth() + 1
+			ctx = caller.Context()
+		}
+		if MaxDepth > 0 && depth > MaxDepth {
+			return newError("stack overflow: more than %d nested calls", MaxDepth)
+		}
+		var last *object.TailCall
+		var deferred []object.Deferred
+		for {
+			err := interrupted(caller)
+			if err == nil && len(args) != len(fn.Parameters) {
+				err = newError("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+			}
+			if err != nil {
+				if last != nil {
+					err.Pos = last.Pos // it's the tail call that didn't happen
+				}
+				return runDeferred(deferred, err)
+			}
+			extendedEnv := extendFunctionEnv(fn, args, depth, ctx)
 
-		// The newly enclosed/inner and updated environment is then the env in which the fn's body is evaluated.
-		evaluated := Eval(fn.Body, extendedEnv)
+			// The newly enclosed/inner and updated environment is then the env in which the fn's body is evaluated.
+			// The body shares it with the parameters instead of getting a block scope of its own
+			evaluated := evalTailBlock(fn.Body, extendedEnv)
+			// a call the body ends with runs before what the body deferred, as if it was nested
+			deferred = append(deferred, extendedEnv.TakeDeferred()...)
+
+			// a call in tail position is made here, in a loop, rather than by recursing
+			if tc, ok := evaluated.(*object.TailCall); ok {
+				fn, args, last = tc.Function, tc.Arguments, tc
+				continue
+			}
 
-		// this is unwrapped if it's an *object.ReturnValue
-		return unwrapReturnValue(evaluated)
+			// this is unwrapped if it's an *object.ReturnValue
+			result := loopControlError(unwrapReturnValue(evaluated))
+			// the frames of the calls between are gone, but the one that failed is worth keeping
+			if err, ok := result.(*object.Error); ok && last != nil {
+				err.Stack = append(err.Stack, object.StackFrame{Function: fn.Name, Pos: last.Pos})
+			}
+			return runDeferred(deferred, result)
+		}
 	case *object.Builtin:
+		if fn.EnvFn != nil {
+			if caller == nil {
+				caller = object.NewEnvironment()
+			}
+			return fn.EnvFn(caller, args...)
+		}
 		return fn.Fn(args...)
 	default:
 		return newError("not a function: %s", fn.Type())
@@ -379,8 +1250,8 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 
 // creates a new *object.Environment that's enclosed by the fn's environment.
 // In new, inner env, the fn's environment (the outer one), binds the args of the fn call to the fn's parameter names
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+func extendFunctionEnv(fn *object.Function, args []object.Object, depth int, ctx context.Context) *object.Environment {
+	env := object.NewCallEnvironment(fn.Env, depth, ctx)
 	for paramIdx, param := range fn.Parameters {
 		env.Set(param.Value, args[paramIdx])
 	}
@@ -1,13 +1,20 @@
 package evaluator
 
 import (
+	"context"
+	"monkey/ast"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
-/// INTEGER EVAL ///
+// / INTEGER EVAL ///
 func TestEvalIntegerExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -36,7 +43,102 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
-/// BOOLEAN EVAL ///
+// / BOOLEAN EVAL ///
+func TestDivisionByZero(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 / 0", "division by zero: 5 / 0"},
+		{"5 % 0", "division by zero: 5 % 0"},
+		{"let x = 1; x / (x - 1)", "division by zero: 1 / 0"},
+		{"1.5 / 0", "division by zero: 1.5 / 0"},
+		{"3 / 0.0", "division by zero: 3.0 / 0"},
+		{"1.5 % 0", "division by zero: 1.5 % 0"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+
+	testIntegerObject(t, testEval("7 % 3"), 1)
+	testIntegerObject(t, testEval("-7 % 3"), -1)
+	testIntegerObject(t, testEval("1 + 7 % 3 * 2"), 3)
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2"},
+		{"1 - -9223372036854775807 - 1", "integer overflow: 1 - -9223372036854775807"},
+		{"4611686018427387904 * 2", "integer overflow: 4611686018427387904 * 2"},
+		{"let min = -9223372036854775807 - 1; min * -1", "integer overflow: -9223372036854775808 * -1"},
+		{"let min = -9223372036854775807 - 1; -1 * min", "integer overflow: -1 * -9223372036854775808"},
+		{"let min = -9223372036854775807 - 1; min / -1", "integer overflow: -9223372036854775808 / -1"},
+		{"let min = -9223372036854775807 - 1; -min", "integer overflow: --9223372036854775808"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+
+	fits := []struct {
+		input    string
+		expected int64
+	}{
+		{"9223372036854775806 + 1", 9223372036854775807},
+		{"-9223372036854775807 - 1", -9223372036854775807 - 1},
+		{"-4611686018427387904 * 2", -9223372036854775807 - 1},
+		{"9223372036854775807 - 9223372036854775807", 0},
+		{"0 * -1", 0},
+	}
+	for _, tt := range fits {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.5", "2.5"},
+		{"-2.5", "-2.5"},
+		{"1.5 + 1.5", "3.0"},
+		{"1 + 0.5", "1.5"},
+		{"0.5 * 4", "2.0"},
+		{"7 / 2.0", "3.5"},
+		{"10 - 0.25", "9.75"},
+		{"1 < 1.5", "true"},
+		{"1.5 > 2", "false"},
+		{"1 == 1.0", "true"},
+		{"1.0 != 1", "false"},
+		{"7.5 % 2", "1.5"},
+		{"7 / 2", "3"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -61,6 +163,11 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"null == null", true},
+		{"null != null", false},
+		{"if (false) { 1 } == null", true},
+		{"null == false", false},
+		{"!null", true},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -68,7 +175,35 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
-/// BANG OPERATOR ///
+// / BANG OPERATOR ///
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && \"a\"", true},
+		{"null || 0", true},
+		{"null && 1", false},
+		{"1 < 2 && 2 < 3 || false", true},
+		// the right side would be an error if it were evaluated
+		{"false && foobar", false},
+		{"true || foobar", true},
+		{"let x = null; x != null && x[0] == 1", false},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+
+	errObj, ok := testEval("true && foobar").(*object.Error)
+	if !ok || errObj.Message != "identifier not found: foobar" {
+		t.Errorf("expected an error for the right operand. got=%v", testEval("true && foobar"))
+	}
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -88,7 +223,7 @@ func TestBangOperator(t *testing.T) {
 	}
 }
 
-/// IF ELSE ///
+// / IF ELSE ///
 func TestIfElseExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -96,6 +231,7 @@ func TestIfElseExpressions(t *testing.T) {
 	}{
 		{"if (true) { 10 }", 10},
 		{"if (false) { 10 }", nil},
+		{"if (true) { null } else { 10 }", nil},
 		{"if (1) { 10 }", 10},
 		{"if (1 < 2) { 10 }", 10},
 		{"if (1 > 2) { 10 }", nil},
@@ -114,7 +250,7 @@ func TestIfElseExpressions(t *testing.T) {
 	}
 }
 
-/// RETURN STATEMENT ///
+// / RETURN STATEMENT ///
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -157,7 +293,205 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
-/// ERROR HANDLING ///
+func TestUnsupportedExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match (1) { 1 => "one", _ => "other" }`, "ERROR: 1:1: match expressions are not supported"},
+		{`let x = match (1) { _ => 2 }; x + 1`, "ERROR: 1:9: match expressions are not supported"},
+		{`macro(x) { quote(unquote(x)) }`, "ERROR: 1:1: macro literals are not supported"},
+		{`let m = macro(x) { x }; m(1)`, "ERROR: 1:9: macro literals are not supported"},
+	}
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"while (false) { 1 }", nil},
+		{"let f = fn() { while (false) {} }; f() + 1", "type mismatch: NULL + INTEGER"},
+		{"while (true) { break; }; 3", 3},
+		{"while (true) { if (true) { break; } 1 }; 4", 4},
+		{"let f = fn() { while (true) { return 5; } }; f()", 5},
+		{"let i = 0; let n = 0; while (i < 4) { i = i + 1; if (i == 2) { continue; } n = n + i; }; n", 8},
+		{"let i = 0; while (true) { i = i + 1; if (i == 10) { break; } }; i", 10},
+		{"while (true) { 1 + true }", "type mismatch: INTEGER + BOOLEAN"},
+		{"while (foo) { }", "identifier not found: foo"},
+		{"if (true) { break; }", "break outside of a loop"},
+		{"let f = fn() { continue; }; while (true) { f(); }", "continue outside of a loop"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			} else if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	if err := testEval("let x = 1;\nif (x) { break }"); err.Inspect() != "ERROR: 2:10: break outside of a loop" {
+		t.Errorf("wrong error. got=%q", err.Inspect())
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let x = 1; let y = 2; x = y = 3; x + y", 6},
+		{"let counter = fn() { let n = 0; fn() { n = n + 1 } }; let c = counter(); c(); c(); c()", 3},
+		{"let x = 1; let f = fn() { x = 5 }; f(); x", 5},
+		{"let x = 1; let f = fn(x) { x = 5 }; f(0); x", 1},
+		{"let a = [1, 2, 3]; a[1] = 5; a[1]", 5},
+		{"let a = [1, 2, 3]; let b = a; b[0] = 7; a[0]", 7},
+		{"let a = [[1], [2]]; a[1][0] = 9; a[1][0]", 9},
+		{`let h = {"a": 1}; h["a"] = 2; h["b"] = 3; h["a"] + h["b"]`, 5},
+		{"x = 1", "assignment to undeclared identifier: x"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1 with length 1"},
+		{"let a = [1, 2]; a[-1] = 5; a[1]", 5},
+		{"let a = [1]; a[-2] = 2", "index out of range: -2 with length 1"},
+		{`let a = [1]; a["0"] = 2`, "array index must be INTEGER, got STRING"},
+		{"let h = {}; h[fn(x) { x }] = 1", "unusable as hash key: FUNCTION"},
+		{`let s = "abc"; s[0] = "x"`, "index assignment not supported: STRING"},
+		{"let x = 1; x = foo", "identifier not found: foo"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			} else if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestCyclicValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [0]; a[0] = a; a", "[[...]]"},
+		{"let a = [1, 2]; a[1] = [a]; a", "[1, [[...]]]"},
+		{`let h = {"a": 1}; h["self"] = h; h`, "{a: 1, self: {...}}"},
+		{`let h = {}; let a = [h]; h["a"] = a; [a, h]`, "[[{a: [...]}], {a: [{...}]}]"},
+		{"let a = [0]; a[0] = a; let b = [0]; b[0] = b; assertEqual(a, b)", "null"},
+		{"let a = [0]; a[0] = a; assertEqual(a, [[1]])", "ERROR: 1:24: assertion failed: [[...]] != [[1]]"},
+		{"let a = [0]; a[0] = a; const b = a; a[0] = 1", "ERROR: 1:42: cannot modify frozen ARRAY"},
+		{`let a = [0]; a[0] = a; str(a) + join(a, "")`, "[[...]][[...]]"},
+	}
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestConcurrentIndexAssign changes one array and one hash from many goroutines at once, which
+// is only safe because they lock themselves. Run it with -race to see it
+func TestConcurrentIndexAssign(t *testing.T) {
+	input := `let a = [0, 0, 0, 0];
+let h = {};
+let fs = map([0, 1, 2, 3, 4, 5, 6, 7], fn(i) { spawn(fn() { a[i % 4] = i; h[i] = len(str(a)); str(h) }) });
+map(fs, await);
+pmap([0, 1, 2, 3, 4, 5, 6, 7], fn(i) { a[i % 4] = h[i]; h[i] = a });
+len(keys(h))`
+	testIntegerObject(t, testEval(input), 8)
+}
+
+func TestBlockScoping(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { let x = 1; }; x", "identifier not found: x"},
+		{"let i = 0; while (i < 3) { let y = i; i = i + 1 }; y", "identifier not found: y"},
+		{"let x = 1; if (true) { let x = 2; }; x", 1},
+		{"let x = 1; if (true) { let x = 2; x }", 2},
+		{"let x = 1; if (true) { x = 2; }; x", 2},
+		{"let x = 1; if (false) { 0 } else { let x = 3; x = 4 }; x", 1},
+		{"let x = 1; if (true) { let x = 2; if (true) { x = 3 }; x }", 3},
+		{"let f = fn(x) { let x = x * 2; x }; f(2)", 4},
+		{"let i = 0; let s = 0; while (i < 3) { let d = i * 2; s = s + d; i = i + 1 }; s", 6},
+		{"const x = 1; if (true) { let x = 2; x = 3; x }", 3},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			} else if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"const x = 1; x", 1},
+		{"const x = 1; x = 2", "cannot assign to constant: x"},
+		{"const x = 1; let f = fn() { x = 2 }; f()", "cannot assign to constant: x"},
+		{"const x = 1; let f = fn(x) { x = 2 }; f(0)", 2},
+		{"const x = 1; let x = 2; x = 3; x", 3},
+		{"let x = 1; const x = 2; x = 3", "cannot assign to constant: x"},
+		{"const a = [1, 2]; a[0] = 5", "cannot modify frozen ARRAY"},
+		{"const a = [[1], 2]; a[0][0] = 5", "cannot modify frozen ARRAY"},
+		{"let a = [1]; const b = a; a[0] = 5", "cannot modify frozen ARRAY"},
+		{`const h = {"a": [1]}; h["b"] = 5`, "cannot modify frozen HASH"},
+		{`const h = {"a": [1]}; h["a"][0] = 5`, "cannot modify frozen ARRAY"},
+		{"const a = [1, 2]; let b = push(a, 3); b[0] = 5; b[0] + a[0]", 6},
+		{`const b = bytes("ab"); b[0] = 1`, "cannot modify frozen BYTES"},
+		{`let b = bytes("ab"); const c = [b]; b[0] = 1`, "cannot modify frozen BYTES"},
+		{`const b = bytes("ab"); let c = bytes(b); c[0] = 99; c[0] - b[0]`, 2},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			} else if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+// / ERROR HANDLING ///
 func TestErrorHandling(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -223,7 +557,41 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
-/// LET STATEMENTS ///
+func TestErrorPositions(t *testing.T) {
+	input := `let inner = fn(x) {
+  x + true
+};
+let outer = fn() { inner(1) };
+outer();`
+
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+	expected := "ERROR: 2:5: type mismatch: INTEGER + BOOLEAN\n" +
+		"\tin inner, called at 4:20\n" +
+		"\tin outer, called at 5:1"
+	if errObj.Inspect() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errObj.Inspect())
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;\n  foobar", "ERROR: 2:3: identifier not found: foobar"},
+		{"len(1)", "ERROR: 1:1: argument to `len` not supported, got INTEGER"},
+		{"fn(x) { -x }(true)", "ERROR: 1:9: unknown operator: -BOOLEAN\n\tin anonymous function, called at 1:1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+// / LET STATEMENTS ///
 // Should assert:
 // 1. that evaluating the value producing expression in a let statement works and
 // 2. that evaluating an identifier that's bound to a name works
@@ -243,7 +611,7 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
-/// FUNCTIONS ///
+// / FUNCTIONS ///
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 	evaluated := testEval(input)
@@ -257,7 +625,7 @@ func TestFunctionObject(t *testing.T) {
 	if fn.Parameters[0].String() != "x" {
 		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
 	}
-	expectedBody := "(x + 2)"
+	expectedBody := "{ (x + 2) }"
 	if fn.Body.String() != expectedBody {
 		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
 	}
@@ -280,55 +648,39 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
-func TestClosures(t *testing.T) {
-	input := `
-		let newAdder = fn(x) {
-			fn(y) {x + y};
-		};
-		let addTwo = newAdder(2);
-		addTwo(2);
-		`
-	testIntegerObject(t, testEval(input), 4)
-}
-
-/// STRING LITERALS ///
-func TestStringLiteral(t *testing.T) {
-	input := `"Hello World!"`
-	evaluated := testEval(input)
-	str, ok := evaluated.(*object.String)
-	if !ok {
-		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
-	}
-
-	if str.Value != "Hello World!" {
-		t.Errorf("String has wrong value. got=%q", str.Value)
-	}
-}
-
-/// STRING CONCAT ///
-func TestStingConcatenation(t *testing.T) {
-	input := `"Hello" + " " + "World!"`
-	evaluated := testEval(input)
-	str, ok := evaluated.(*object.String)
-	if !ok {
-		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+func TestWrongNumberOfArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b) { a }; f(1)", "ERROR: 1:25: wrong number of arguments. got=1, want=2\n\tin f, called at 1:25"},
+		{"fn(a) { a }(1, 2)", "ERROR: 1:1: wrong number of arguments. got=2, want=1\n\tin anonymous function, called at 1:1"},
+		{"let g = fn(a) { a }; let f = fn() { g() }; f()", "ERROR: 1:37: wrong number of arguments. got=0, want=1\n\tin f, called at 1:44"},
+		{"let f = fn() { 1 }; let g = fn() { f(1) + 1 }; g()", "ERROR: 1:36: wrong number of arguments. got=1, want=0\n\tin f, called at 1:36\n\tin g, called at 1:48"},
+		{"map([1, 2], fn(x, i) { x })", "ERROR: 1:1: wrong number of arguments. got=1, want=2"},
+		{"reduce([1], 0, fn(acc) { acc })", "ERROR: 1:1: wrong number of arguments. got=2, want=1"},
 	}
-	if str.Value != "Hello World!" {
-		t.Errorf("String has wrong value. got=%q", str.Value)
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
 	}
 }
 
-/// BUILTINS ///
-func TestBuiltinFunctions(t *testing.T) {
+func TestNamedArguments(t *testing.T) {
 	tests := []struct {
 		input    string
 		expected interface{}
 	}{
-		{`len("")`, 0},
-		{`len("four")`, 4},
-		{`len("hello world")`, 11},
-		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{"let sub = fn(x, y) { x - y }; sub(y: 1, x: 5);", 4},
+		{"let sub = fn(x, y) { x - y }; sub(5, y: 1);", 4},
+		{"let sub = fn(x, y) { x - y }; sub(x: 1 + 1, y: 2 * 3);", -4},
+		{"let sub = fn(x, y) { x - y }; sub(5, z: 1);", "unknown named argument: z"},
+		{"let sub = fn(x, y) { x - y }; sub(5, x: 1);", "argument x given more than once"},
+		{"let sub = fn(x, y) { x - y }; sub(x: 5);", "missing argument: y"},
+		{"let sub = fn(x, y) { x - y }; sub(1, 2, 3, x: 5);", "wrong number of arguments. got=4, want=2"},
+		{"let sub = fn(x, y) { x - y }; sub(x: foo, y: 1);", "identifier not found: foo"},
+		{`len(x: "four")`, "named arguments not supported: BUILTIN"},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -348,43 +700,1621 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
-///// ARRAYS /////
-func TestArrayLiterals(t *testing.T) {
-	input := "[1, 2 * 2, 3 + 3]"
-	evaluated := testEval(input)
-	result, ok := evaluated.(*object.Array)
-	if !ok {
-		t.Fatalf("object is not Array. got = %T (%+v)", evaluated, evaluated)
+func TestClosures(t *testing.T) {
+	input := `
+		let newAdder = fn(x) {
+			fn(y) {x + y};
+		};
+		let addTwo = newAdder(2);
+		addTwo(2);
+		`
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(100000, 0)", 100000},
+		{"let count = fn(n) { if (n == 0) { return 0; } return count(n - 1); }; count(100000)", 0},
+		{"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; if (even(100001)) { 1 } else { 0 }", 0},
+		{"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(100)", 5050},
+		{"let f = fn(n) { let x = n * 2; len([x]) }; f(3)", 1},
 	}
-	if len(result.Elements) != 3 {
-		t.Fatalf("array has wrong num of elements, got=%d", len(result.Elements))
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
 	}
 
-	testIntegerObject(t, result.Elements[0], 1)
-	testIntegerObject(t, result.Elements[1], 4)
-	testIntegerObject(t, result.Elements[2], 6)
+	input := `let fail = fn() { 1 + true };
+let loop = fn(n) { if (n == 0) { fail() } else { loop(n - 1) } };
+loop(3);`
+	expected := "ERROR: 1:21: type mismatch: INTEGER + BOOLEAN\n" +
+		"\tin fail, called at 2:34\n" +
+		"\tin loop, called at 3:1"
+	if got := testEval(input).Inspect(); got != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, got)
+	}
 }
 
-func TestArrayIndexExpressions(t *testing.T) {
+func TestTryCatch(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected interface{}
+		expected string
 	}{
-		{
-			"[1, 2, 3][0]",
-			1,
-		},
-		{
-			"[1, 2, 3][1]",
-			2,
-		},
-		{
-			"[1, 2, 3][2]",
-			3,
-		},
-		{
-			"let i = 0; [1][i];",
-			1,
+		{`try { 1 } catch (e) { 2 }`, "1"},
+		{`try { throw "boom"; 1 } catch (e) { "caught " + e }`, "caught boom"},
+		{`try { throw [1, 2]; } catch (e) { e[1] }`, "2"},
+		{`try { 1 / 0 } catch (e) { e }`, "division by zero: 1 / 0"},
+		{`try { missing } catch (e) { type(e) }`, "STRING"},
+		{`let f = fn() { throw {"code": 7}; }; try { f() } catch (e) { e["code"] }`, "7"},
+		{`let f = fn() { try { return 1; } catch (e) { 2 }; 3 }; f()`, "1"},
+		{`let x = try { throw 1; } catch (e) { e + 1 }; x`, "2"},
+		{`let x = try { let y = 1; } catch (e) { 2 }; [x, type(x)]`, "[null, NULL]"},
+		{`let x = try { throw 1; } catch (e) { let y = e; }; type(x)`, "NULL"},
+		{`try { } catch (e) { 2 }`, "null"},
+		{`try { try { throw 1; } catch (e) { throw e + 1; } } catch (e) { e * 10 }`, "20"},
+		{`try { throw 1; } catch (e) { let y = e; }; e`, "ERROR: identifier not found: e"},
+		{`let i = 0; while (i < 3) { try { i = i + 1; break; } catch (e) { 0 } }; i`, "1"},
+		{`throw "boom"`, "ERROR: boom"},
+		{`throw 1 + 2`, "ERROR: 3"},
+		{`throw missing`, "ERROR: identifier not found: missing"},
+		{`try { 1 } catch (e) { throw "never" }; throw "after"`, "ERROR: after"},
+		{`try { throw 1; } catch (e) { 1 / 0 }`, "ERROR: division by zero: 1 / 0"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = "ERROR: " + errObj.Message
+		} else if str, ok := evaluated.(*object.String); ok {
+			got = str.Value
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	input := "let f = fn() {\n  throw \"deep\";\n};\nf()"
+	if got := testEval(input).Inspect(); got != "ERROR: 2:3: deep\n\tin f, called at 4:1" {
+		t.Errorf("uncaught throw wrong. got=%q", got)
+	}
+}
+
+func TestForInLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = 0; for (x in [1, 2, 3]) { s = s + x; }; s`, "6"},
+		{`let f = fn() { for (x in []) {} }; f() + 1`, "ERROR: type mismatch: NULL + INTEGER"},
+		{`let s = ""; for (i, x in ["a", "b"]) { s = s + str(i) + x; }; s`, "0a1b"},
+		{`let s = ""; for (k in {"b": 1, "a": 2, 3: 3}) { s = s + str(k); }; s`, "ba3"},
+		{`let s = ""; for (k, v in {"b": 1, "a": 2}) { s = s + k + str(v); }; s`, "b1a2"},
+		{`let h = {"z": 1}; h["y"] = 2; h["x"] = 3; h["z"] = 4; delete(h, "y"); h["y"] = 5; let s = ""; for (k, v in h) { s = s + k + str(v); }; s`, "z4x3y5"},
+		{`let s = ""; for (c in "héllo") { s = c + s; }; s`, "olléh"},
+		{`let s = ""; for (i, c in "ab") { s = s + str(i) + c; }; s`, "0a1b"},
+		{`let s = 0; for (b in bytes("ab")) { s = s + b; }; s`, "195"},
+		{`let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; }; if (x == 4) { break; }; s = s + x; }; s`, "4"},
+		{`let f = fn(xs) { for (x in xs) { if (x > 1) { return x; } }; 0 }; f([1, 5, 7])`, "5"},
+		{`let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x }); }; fs[0]() + fs[1]() * 10`, "21"},
+		{`for (x in [1]) { let y = x; }; y`, "ERROR: identifier not found: y"},
+		{`for (x in [1]) { x }; x`, "ERROR: identifier not found: x"},
+		{`let n = 0; for (x in []) { n = n + 1; }; n`, "0"},
+		{`for (x in 5) { x }`, "ERROR: cannot iterate over INTEGER"},
+		{`for (x in [1, 0]) { 1 / x }`, "ERROR: division by zero: 1 / 0"},
+		{`for (x in missing) { x }`, "ERROR: identifier not found: missing"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = "ERROR: " + errObj.Message
+		} else if str, ok := evaluated.(*object.String); ok {
+			got = str.Value
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	if err, ok := testEval("let x = 1;\nfor (y in x) { y }").(*object.Error); !ok || err.Pos.String() != "2:1" {
+		t.Errorf("error of a for-in loop at the wrong position. got=%v", err)
+	}
+}
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1..5`, "1..5"},
+		{`let n = 3; 0..n * 2`, "0..6"},
+		{`type(1..2)`, "RANGE"},
+		{`toArray(1..5)`, "[1, 2, 3, 4]"},
+		{`toArray(5..1)`, "[]"},
+		{`toArray(-2..1)`, "[-2, -1, 0]"},
+		{`len(1..1000000)`, "999999"},
+		{`len(3..3)`, "0"},
+		{`len(0..1000000000000000)`, "1000000000000000"},
+		{`len(-9223372036854775807..9223372036854775807)`, "9223372036854775807"},
+		{`contains(1..10, 9)`, "true"},
+		{`contains(1..10, 10)`, "false"},
+		{`contains(1..10, 0)`, "false"},
+		{`contains(1..10, "5")`, "false"},
+		{`let s = 0; for (i in 1..1000000) { s = s + i; }; s`, "499999500000"},
+		{`let s = ""; for (i, x in 10..13) { s = s + str(i) + ":" + str(x) + " "; }; s`, "0:10 1:11 2:12 "},
+		{`let n = 0; for (i in 0..1000000000000) { if (i == 3) { break; }; n = n + 1; }; n`, "3"},
+		{`toArray("héllo")`, "[h, é, l, l, o]"},
+		{`toArray({"b": 1, "a": 2})`, "[b, a]"},
+		{`toArray([1, 2])`, "[1, 2]"},
+		{`toArray(bytes("ab"))`, "[97, 98]"},
+		{`1.5..3`, "ERROR: range start must be INTEGER, got FLOAT"},
+		{`1.."3"`, "ERROR: range end must be INTEGER, got STRING"},
+		{`missing..3`, "ERROR: identifier not found: missing"},
+		{`toArray(1)`, "ERROR: argument to 'toArray()' must be iterable, got INTEGER"},
+		{`toArray()`, "ERROR: wrong number of arguments. got=0, want=1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = "ERROR: " + errObj.Message
+		} else if str, ok := evaluated.(*object.String); ok {
+			got = str.Value
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestDefer(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let log = ""; let f = fn() { defer log = log + "d"; log = log + "b"; }; f(); log`, "bd"},
+		{`let log = ""; let f = fn() { defer log = log + "d"; }; [f(), log]`, "[null, d]"},
+		{`let f = fn() { defer 1; }; type(f())`, "NULL"},
+		{`let log = ""; let f = fn() { defer log = log + "1"; defer log = log + "2"; log = log + "b"; }; f(); log`, "b21"},
+		{`let log = ""; let f = fn() { defer log = log + "d"; return 5; log = log + "never"; }; [f(), log]`, "[5, d]"},
+		{`let log = ""; let f = fn() { defer log = log + "d"; 1 / 0 }; try { f() } catch (e) { [e, log] }`, "[division by zero: 1 / 0, d]"},
+		{`let log = ""; let f = fn() { defer log = log + "d"; throw "boom"; }; try { f() } catch (e) { [e, log] }`, "[boom, d]"},
+		{`let log = ""; let f = fn(x) { if (x > 0) { defer log = log + "d"; }; log = log + "b" }; f(1); log`, "bd"},
+		{`let log = ""; let f = fn() { let x = 1; defer log = log + str(x); x = 2; }; f(); log`, "2"},
+		{`let log = ""; let g = fn() { defer log = log + "g"; }; let f = fn() { defer log = log + "f"; g() }; f(); log`, "gf"},
+		{`let log = ""; let f = fn(n) { defer log = log + str(n); if (n > 0) { return f(n - 1); }; n }; f(3); log`, "0123"},
+		{`let log = ""; let f = fn() { let i = 0; while (i < 3) { defer log = log + str(i); i = i + 1; }; log = log + "b" }; f(); log`, "b333"},
+		{`let f = fn() { defer 1 / 0; 5 }; f()`, "ERROR: division by zero: 1 / 0"},
+		{`let f = fn() { defer missing; throw "first"; }; f()`, "ERROR: first"},
+		{`let f = fn() { defer 1; 5 }; f()`, "5"},
+		{`let log = ""; defer log = log + "d"; log = log + "b"; log`, "b"},
+		{`let log = ""; let f = fn() { defer log = log + "d"; eval("1"); log = log + "e"; }; f(); log`, "ed"},
+		{`let log = ""; let f = fn() { defer log = log + "d"; eval("defer log = log + \"x\"; 1"); log = log + "e"; }; f(); log`, "xed"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = "ERROR: " + errObj.Message
+		} else if str, ok := evaluated.(*object.String); ok {
+			got = str.Value
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	// outside of any function, deferred expressions run at the end of the program
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New(`let log = ""; defer log = log + "d"; log = log + "b"`)).ParseProgram(), env)
+	if log, _ := env.Get("log"); log.Inspect() != "bd" {
+		t.Errorf("top level defer wrong. got=%s", log.Inspect())
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	input := "let f = fn(n) { 1 + f(n + 1) }; f(0)"
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+	if errObj.Message != "stack overflow: more than 10000 nested calls" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+	if len(errObj.Stack) != 10001 { // the calls in progress and the one that couldn't be made
+		t.Errorf("wrong stack depth. got=%d", len(errObj.Stack))
+	}
+	if lines := strings.Count(errObj.Inspect(), "\n"); lines != 21 {
+		t.Errorf("Inspect has wrong number of lines. got=%d", lines+1)
+	}
+
+	// calls made by builtins nest in the call of the builtin
+	input = "let f = fn(n) { 1 + first(map([n], fn(x) { f(x + 1) })) }; f(0)"
+	if errObj, ok := testEval(input).(*object.Error); !ok || errObj.Message != "stack overflow: more than 10000 nested calls" {
+		t.Errorf("recursion through map didn't overflow. got=%v", errObj)
+	}
+
+	defer func(max int) { MaxDepth = max }(MaxDepth)
+	MaxDepth = 3
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(2)", 2},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(3)", "stack overflow: more than 3 nested calls"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(100)", 0},
+		{"let f = fn() { 1 + fn() { 1 + fn() { 1 }() }() }; f()", 3},
+		{"let f = fn() { 1 + fn() { 1 + fn() { 1 + fn() { 1 }() }() }() }; f()", "stack overflow: more than 3 nested calls"},
+		{"let f = fn(n) { reduce([n], 0, fn(acc, x) { f(x) }) }; f(1)", "stack overflow: more than 3 nested calls"},
+		{`let f = fn(n) { eval("f(n + 1)", {"f": f, "n": n}) + 0 }; f(1)`, "stack overflow: more than 3 nested calls"},
+		{`let f = fn(n) { eval("f(n + 1)") + 0 }; f(1)`, "stack overflow: more than 3 nested calls"},
+		{"sort([2, 1], fn(a, b) { filter([a], fn(x) { map([x], fn(y) { 1 + fn() { y }() }) }) == [] })", "stack overflow: more than 3 nested calls"},
+		{"first(map([1], fn(x) { first(map([x], fn(y) { y })) }))", 1},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			} else if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestLambdas(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let add = |x, y| x + y; add(2, 3);", 5},
+		{"let newAdder = |x| |y| x + y; newAdder(2)(3);", 5},
+		{"(|| 7)()", 7},
+		{"let apply = fn(f, x) { f(x) }; apply(|x| x * x, 4);", 16},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+// / STRING LITERALS ///
+func TestObjectExpressions(t *testing.T) {
+	program := parser.New(lexer.New("double(x) + 1")).ParseProgram()
+	double := testEval("fn(x) { x * 2 }")
+	objects := map[string]object.Object{"double": double, "x": &object.Integer{Value: 20}}
+
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok {
+			return &ast.ObjectExpression{Token: ident.Token, Value: objects[ident.Value]}
+		}
+		return node
+	})
+	testIntegerObject(t, Eval(program, object.NewEnvironment()), 41)
+
+	quote := &object.Quote{Node: program.Statements[0]}
+	if quote.Inspect() != "QUOTE((fn(x) { (x * 2) }(20) + 1))" {
+		t.Errorf("quote.Inspect() wrong. got=%q", quote.Inspect())
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	input := `"Hello World!"`
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"tab\there"`, "tab\there"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"caf\u00e9"`, "café"},
+		{`split("a\nb", "\n")[1]`, "b"},
+	}
+	for _, tt := range tests {
+		testStringObject(t, testEval(tt.input), tt.expected)
+	}
+	testIntegerObject(t, testEval(`len("\u00e9\n")`), 2)
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Ann"; "Hello ${name}!"`, "Hello Ann!"},
+		{`"${1 + 2} ${2.5} ${true} ${null} ${[1, "a"]}"`, "3 2.5 true null [1, a]"},
+		{`let x = 2; "${x}${x * x}"`, "24"},
+		{`let n = "in"; "out ${"${n}ner"} \${n}"`, "out inner ${n}"},
+		{`let f = fn(x) { "<${x}>" }; "${f(f("a"))}"`, "<<a>>"},
+		{`"a ${missing} b"`, "ERROR: identifier not found: missing"},
+		{`"a ${"b ${1 / 0}"}"`, "ERROR: division by zero: 1 / 0"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = "ERROR: " + errObj.Message
+		} else if str, ok := evaluated.(*object.String); ok {
+			got = str.Value
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	err, ok := testEval("let x = 1;\n\"a ${x + y}\"").(*object.Error)
+	if !ok || err.Pos.String() != "2:10" {
+		t.Errorf("error from an interpolation at the wrong position. got=%v", err)
+	}
+}
+
+// / STRING CONCAT ///
+func TestStingConcatenation(t *testing.T) {
+	input := `"Hello" + " " + "World!"`
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"ab" == "a" + "b"`, true},
+		{`let s = "x"; s != "x"`, false},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+// / BUILTINS ///
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestPuts(t *testing.T) {
+	var out strings.Builder
+	stdout := Stdout
+	Stdout = &out
+	defer func() { Stdout = stdout }()
+
+	result := testEval(`puts("a", 1, [2, "b"]); puts(); puts({"k": null})`)
+	testNullObject(t, result)
+	expected := "a\n1\n[2, b]\n{k: null}\n"
+	if out.String() != expected {
+		t.Errorf("puts wrote the wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`split("a,b,,c", ",")`, []string{"a", "b", "", "c"}},
+		{`split("abc", "")`, []string{"a", "b", "c"}},
+		{`split("", ",")`, []string{""}},
+		{`join(["a", "b", "c"], ", ")`, "a, b, c"},
+		{`join([], "-")`, ""},
+		{`join([1, true, "x"], "")`, "1truex"},
+		{`join(split("a b c", " "), "_")`, "a_b_c"},
+		{`upper("Hello")`, "HELLO"},
+		{`lower("Hello")`, "hello"},
+		{"trim(\"  \tpadded \n\")", "padded"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`replace("aaa", "a", "")`, ""},
+		{`upper(1)`, errorMessage("argument to 'upper()' must be STRING, got INTEGER")},
+		{`split("a", 1)`, errorMessage("second argument to 'split()' must be STRING, got INTEGER")},
+		{`join("a", "")`, errorMessage("first argument to 'join()' must be ARRAY, got STRING")},
+		{`replace("a", "b")`, errorMessage("wrong number of arguments. got=2, want=3")},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			testStringObject(t, evaluated, expected)
+		case []string:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testStringObject(t, array.Elements[i], expectedElem)
+			}
+		case errorMessage:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != string(expected) {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestStringPredicates(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`contains("hello world", "o w")`, true},
+		{`contains("hello", "")`, true},
+		{`contains("hello", "x")`, false},
+		{`startsWith("hello", "he")`, true},
+		{`startsWith("hello", "lo")`, false},
+		{`endsWith("hello", "lo")`, true},
+		{`endsWith("hello", "he")`, false},
+		{`indexOf("hello", "l")`, 2},
+		{`indexOf("hello", "x")`, -1},
+		{`indexOf("hello", "")`, 0},
+		{`startsWith(1, "a")`, "first argument to 'startsWith()' must be STRING, got INTEGER"},
+		{`indexOf("a")`, "wrong number of arguments. got=1, want=2"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestArrayBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort([2.5, 1, 2])`, "[1, 2, 2.5]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`sort([])`, "[]"},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, "[3, 2, 1]"},
+		{`sort(["bb", "a", "ccc", "dd"], |a, b| len(a) < len(b))`, "[a, bb, dd, ccc]"},
+		{`let a = [2, 1]; sort(a); a`, "[2, 1]"},
+		{`reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`reverse([])`, "[]"},
+		{`let a = [1, 2]; reverse(a); a`, "[1, 2]"},
+		{`contains([1, 2, 3], 2)`, "true"},
+		{`contains([1, 2, 3], 2.0)`, "true"},
+		{`contains(["a", "b"], "b")`, "true"},
+		{`contains([1, 2, 3], 4)`, "false"},
+		{`contains([null, true], true)`, "true"},
+		{`contains([], 1)`, "false"},
+		{`sort([1, "a"])`, errorMessage("sort() can't compare STRING and INTEGER without a function")},
+		{`sort([1, 2], fn(a, b) { a + true })`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{`sort(1)`, errorMessage("argument to 'sort()' must be ARRAY, got INTEGER")},
+		{`sort([1], 1)`, errorMessage("second argument to 'sort()' must be FUNCTION, got INTEGER")},
+		{`reverse("ab")`, errorMessage("argument to 'reverse()' must be ARRAY, got STRING")},
+		{`contains(1, 1)`, errorMessage("first argument to 'contains()' must be STRING, got INTEGER")},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		case errorMessage:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != string(expected) {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`keys({"b": 1, "a": 2, 3: 3, 1.5: 4, true: 5, false: 6})`, "[b, a, 3, 1.5, true, false]"},
+		{`values({"b": 1, "a": 2, 10: 3, 9: 4})`, "[1, 2, 3, 4]"},
+		{`keys({})`, "[]"},
+		{`{"b": 1, "a": 2}`, "{b: 1, a: 2}"},
+		{`let h = {"z": 1}; h["a"] = 2; h["z"] = 3; [keys(h), values(h)]`, "[[z, a], [3, 2]]"},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`has({1: null}, 1)`, "true"},
+		{`let h = {"a": 1, "b": 2}; delete(h, "a")`, "true"},
+		{`let h = {"a": 1, "b": 2}; delete(h, "a"); h`, "{b: 2}"},
+		{`let h = {"a": 1}; delete(h, "x"); h`, "{a: 1}"},
+		{`let h = {"a": 1}; let sum = 0; let ks = keys(h); map(ks, fn(k) { sum = sum + h[k] }); sum`, "1"},
+		{`const h = {"a": 1}; delete(h, "a")`, errorMessage("cannot modify frozen HASH")},
+		{`has({}, [1])`, errorMessage("unusable as hash key: ARRAY")},
+		{`keys([1])`, errorMessage("argument to 'keys()' must be HASH, got ARRAY")},
+		{`delete([1], 0)`, errorMessage("first argument to 'delete()' must be HASH, got ARRAY")},
+		{`has({})`, errorMessage("wrong number of arguments. got=1, want=2")},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		case errorMessage:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != string(expected) {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`int(5)`, 5},
+		{`int(2.9)`, 2},
+		{`int(-2.9)`, -2},
+		{`int("12")`, 12},
+		{`int("-12")`, -12},
+		{`int(true)`, 1},
+		{`int(false)`, 0},
+		{`float(2)`, 2.0},
+		{`float("1.5")`, 1.5},
+		{`float("3")`, 3.0},
+		{`float(true)`, 1.0},
+		{`str(12)`, "12"},
+		{`str(1.0)`, "1.0"},
+		{`str("a")`, "a"},
+		{`str([1, "a"])`, "[1, a]"},
+		{`str(null)`, "null"},
+		{`bool(0)`, true},
+		{`bool("")`, true},
+		{`bool(null)`, false},
+		{`bool(false)`, false},
+		{`int(str(42)) + 1`, 43},
+		{`int("12a")`, errorMessage(`cannot convert "12a" to INTEGER`)},
+		{`int("")`, errorMessage(`cannot convert "" to INTEGER`)},
+		{`int(" 1")`, errorMessage(`cannot convert " 1" to INTEGER`)},
+		{`int("99999999999999999999")`, errorMessage(`cannot convert "99999999999999999999" to INTEGER`)},
+		{`int(float("1e300") * float("1e300"))`, errorMessage(`cannot convert +Inf to INTEGER`)},
+		{`float("x")`, errorMessage(`cannot convert "x" to FLOAT`)},
+		{`int([1])`, errorMessage("argument to 'int()' not supported, got ARRAY")},
+		{`float(null)`, errorMessage("argument to 'float()' not supported, got NULL")},
+		{`str()`, errorMessage("wrong number of arguments. got=0, want=1")},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			testStringObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case errorMessage:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != string(expected) {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestTypeBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`type(1)`, "INTEGER"},
+		{`type(1.5)`, "FLOAT"},
+		{`type("a")`, "STRING"},
+		{`type(true)`, "BOOLEAN"},
+		{`type(null)`, "NULL"},
+		{`type([])`, "ARRAY"},
+		{`type({})`, "HASH"},
+		{`type(fn() {})`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`type(type(1))`, "STRING"},
+		{`let describe = fn(x) { if (type(x) == "ARRAY") { "list" } else { "other" } }; describe([1])`, "list"},
+	}
+	for _, tt := range tests {
+		testStringObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestTimeBuiltins(t *testing.T) {
+	before := time.Now().UnixMilli()
+	ms, ok := testEval("now()").(*object.Integer)
+	if !ok || ms.Value < before || ms.Value > time.Now().UnixMilli() {
+		t.Errorf("now() is wrong. got=%+v", ms)
+	}
+
+	elapsed, ok := testEval("let start = clock(); let i = 0; while (i < 1000) { i = i + 1 }; clock() - start").(*object.Float)
+	if !ok || elapsed.Value < 0 {
+		t.Errorf("clock() difference is wrong. got=%+v", elapsed)
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`formatTime(0)`, "1970-01-01T00:00:00.000Z"},
+		{`formatTime(1700000000123)`, "2023-11-14T22:13:20.123Z"},
+		{`formatTime(86400000, "2006-01-02")`, "1970-01-02"},
+		{`type(formatTime(now()))`, "STRING"},
+		{`now(1)`, errorMessage("wrong number of arguments. got=1, want=0")},
+		{`formatTime("0")`, errorMessage("first argument to 'formatTime()' must be INTEGER, got STRING")},
+		{`formatTime(0, 1)`, errorMessage("second argument to 'formatTime()' must be STRING, got INTEGER")},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			testStringObject(t, evaluated, expected)
+		case errorMessage:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != string(expected) {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestSleepBuiltin(t *testing.T) {
+	start := time.Now()
+	testNullObject(t, testEval("sleep(20)"))
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("sleep(20) returned after %s", elapsed)
+	}
+
+	defer func(ctx context.Context) { Context = ctx }(Context)
+	ctx, cancel := context.WithCancel(context.Background())
+	Context = ctx
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start = time.Now()
+	errObj, ok := testEval("sleep(10000); 1").(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+	if errObj.Message != "sleep interrupted: context canceled" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("interrupted sleep returned after %s", elapsed)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sleep("1")`, "argument to 'sleep()' must be INTEGER, got STRING"},
+		{`sleep(-1)`, "argument to 'sleep()' must not be negative, got -1"},
+		{`sleep()`, "wrong number of arguments. got=0, want=1"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+		} else if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestRandomBuiltins(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		f, ok := testEval("rand()").(*object.Float)
+		if !ok || f.Value < 0 || f.Value >= 1 {
+			t.Fatalf("rand() out of range. got=%+v", f)
+		}
+		n, ok := testEval("randInt(-2, 2)").(*object.Integer)
+		if !ok || n.Value < -2 || n.Value > 2 {
+			t.Fatalf("randInt(-2, 2) out of range. got=%+v", n)
+		}
+	}
+	testIntegerObject(t, testEval("randInt(7, 7)"), 7)
+	if _, ok := testEval("randInt(-9223372036854775807 - 1, 9223372036854775807)").(*object.Integer); !ok {
+		t.Errorf("randInt() over the whole range failed")
+	}
+
+	first := testEval("seed(42); [rand(), randInt(1, 1000000), rand()]").Inspect()
+	second := testEval("seed(42); [rand(), randInt(1, 1000000), rand()]").Inspect()
+	if first != second {
+		t.Errorf("seed() doesn't make the sequence repeat. got %s and %s", first, second)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`randInt(2, 1)`, "randInt() min must not be greater than max, got 2 and 1"},
+		{`randInt(1.5, 2)`, "first argument to 'randInt()' must be INTEGER, got FLOAT"},
+		{`seed("x")`, "argument to 'seed()' must be INTEGER, got STRING"},
+		{`rand(1)`, "wrong number of arguments. got=1, want=0"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+		} else if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`abs(-3)`, "3"},
+		{`abs(3)`, "3"},
+		{`abs(-2.5)`, "2.5"},
+		{`floor(2.7)`, "2.0"},
+		{`floor(-2.5)`, "-3.0"},
+		{`floor(4)`, "4"},
+		{`ceil(2.1)`, "3.0"},
+		{`ceil(-2.5)`, "-2.0"},
+		{`sqrt(16)`, "4.0"},
+		{`sqrt(2.25)`, "1.5"},
+		{`pow(2, 10)`, "1024"},
+		{`pow(-3, 3)`, "-27"},
+		{`pow(5, 0)`, "1"},
+		{`pow(2, -1)`, "0.5"},
+		{`pow(2.0, 3)`, "8.0"},
+		{`pow(4, 0.5)`, "2.0"},
+		{`pow(2, 62)`, "4611686018427387904"},
+		{`pow(-2, 63)`, "-9223372036854775808"},
+		{`min(3, 1, 2)`, "1"},
+		{`max(3, 1, 2)`, "3"},
+		{`min(1, 0.5)`, "0.5"},
+		{`max([4, 9, 2])`, "9"},
+		{`min(7)`, "7"},
+		{`abs(-9223372036854775807 - 1)`, "ERROR: integer overflow: --9223372036854775808"},
+		{`pow(2, 63)`, "ERROR: integer overflow: pow(2, 63)"},
+		{`pow(10, 100)`, "ERROR: integer overflow: pow(10, 100)"},
+		{`sqrt(-1)`, "ERROR: square root of negative number: -1"},
+		{`floor("1")`, "ERROR: argument to 'floor()' must be INTEGER or FLOAT, got STRING"},
+		{`pow(2, "1")`, "ERROR: second argument to 'pow()' must be INTEGER or FLOAT, got STRING"},
+		{`max(1, "2")`, "ERROR: arguments to 'max()' must be INTEGER or FLOAT, got STRING"},
+		{`min([])`, "ERROR: argument to 'min()' must not be an empty ARRAY"},
+		{`max()`, "ERROR: wrong number of arguments. got=0, want at least 1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = "ERROR: " + errObj.Message
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestRegexBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`regex("a+b")`, "/a+b/"},
+		{`type(regex("a"))`, "REGEX"},
+		{`regexMatch(regex("^[0-9]+$"), "123")`, "true"},
+		{`regexMatch("^[0-9]+$", "12a")`, "false"},
+		{`regexFind("([a-z]+)@([a-z]+)", "mail bob@example now")`, "[bob@example, bob, example]"},
+		{`regexFind("a(x)?b", "ab")`, "[ab, null]"},
+		{`regexFind("x", "abc")`, "null"},
+		{`regexReplace("[0-9]+", "a1b22c", "#")`, "a#b#c"},
+		{`regexReplace(regex("(\\w+)=(\\w+)"), "k=v", "$2=$1")`, "v=k"},
+		{`regexReplace("(?P<k>\\w+)=(?P<v>\\w+)", "k=v", "\${v}=\${k}")`, "v=k"},
+		{`let re = regex("o"); map(["foo", "bar"], |s| regexMatch(re, s))`, "[true, false]"},
+		{`regex("(")`, "ERROR: invalid regex: error parsing regexp: missing closing ): `(`"},
+		{`regexMatch("[", "a")`, "ERROR: invalid regex: error parsing regexp: missing closing ]: `[`"},
+		{`regexMatch(1, "a")`, "ERROR: first argument to 'regexMatch()' must be REGEX or STRING, got INTEGER"},
+		{`regexReplace("a", "b", 1)`, "ERROR: third argument to 'regexReplace()' must be STRING, got INTEGER"},
+		{`regexFind("a")`, "ERROR: wrong number of arguments. got=1, want=2"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = "ERROR: " + errObj.Message
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestExecBuiltin(t *testing.T) {
+	errObj, ok := testEval(`exec("echo", "hi")`).(*object.Error)
+	if !ok || errObj.Message != "exec is disabled" {
+		t.Fatalf("exec isn't disabled by default. got=%+v", errObj)
+	}
+
+	defer func(allow bool) { AllowExec = allow }(AllowExec)
+	AllowExec = true
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh to run")
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`exec("echo", "hello", "world")`, `{stdout: hello world` + "\n, stderr: , code: 0}"},
+		{`exec("sh", "-c", "echo out; echo err >&2; exit 3")`, "{stdout: out\n, stderr: err\n, code: 3}"},
+		{`exec("sh", "-c", "exit 1")["code"]`, "1"},
+		{`exec("no-such-command-hopefully")`, `ERROR: exec no-such-command-hopefully: exec: "no-such-command-hopefully": executable file not found in $PATH`},
+		{`exec("echo", 1)`, "ERROR: arguments to 'exec()' must be STRING, got INTEGER"},
+		{`exec()`, "ERROR: wrong number of arguments. got=0, want at least 1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = "ERROR: " + errObj.Message
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestEnvBuiltins(t *testing.T) {
+	t.Setenv("MONKEY_TEST_VAR", "banana")
+	for _, input := range []string{`env("MONKEY_TEST_VAR")`, `setEnv("MONKEY_TEST_SET", "1")`} {
+		name := input[:strings.Index(input, "(")]
+		if errObj, ok := testEval(input).(*object.Error); !ok || errObj.Message != name+" is disabled" {
+			t.Fatalf("%s isn't disabled by default. got=%+v", name, errObj)
+		}
+	}
+
+	defer func(allow bool) { AllowEnv = allow }(AllowEnv)
+	AllowEnv = true
+	defer os.Unsetenv("MONKEY_TEST_SET")
+	defer func(args []string) { Args = args }(Args)
+	Args = []string{"-v", "file.txt"}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`env("MONKEY_TEST_VAR")`, "banana"},
+		{`env("MONKEY_TEST_UNSET_VAR")`, "null"},
+		{`setEnv("MONKEY_TEST_SET", "1"); env("MONKEY_TEST_SET")`, "1"},
+		{`args()`, "[-v, file.txt]"},
+		{`len(args())`, "2"},
+		{`env(1)`, "ERROR: argument to 'env()' must be STRING, got INTEGER"},
+		{`setEnv("A")`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`setEnv("", "x")`, "ERROR: setEnv : setenv: invalid argument"},
+		{`args(1)`, "ERROR: wrong number of arguments. got=1, want=0"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = "ERROR: " + errObj.Message
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+	if os.Getenv("MONKEY_TEST_SET") != "1" {
+		t.Errorf("setEnv didn't set the variable of the process")
+	}
+}
+
+func TestAssertBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`assert(true)`, "null"},
+		{`assert(1 < 2, "math works")`, "null"},
+		{`assertEqual(1 + 1, 2)`, "null"},
+		{`assertEqual([1, "a", {"b": [2]}], [1, "a", {"b": [2]}])`, "null"},
+		{`assertEqual(1, 1.0)`, "null"},
+		{`assert(false)`, "ERROR: 1:1: assertion failed"},
+		{`assert(null, "x is set")`, "ERROR: 1:1: assertion failed: x is set"},
+		{`assertEqual(1, "1")`, `ERROR: 1:1: assertion failed: 1 != "1"`},
+		{`assertEqual([1, 2], [1, 3])`, "ERROR: 1:1: assertion failed: [1, 2] != [1, 3]"},
+		{`assertEqual({"a": 1}, {"b": 1})`, "ERROR: 1:1: assertion failed: {a: 1} != {b: 1}"},
+		{"assert(true);\nassert(false); 1", "ERROR: 2:1: assertion failed"},
+		{`let f = fn() { assertEqual(1, 2) }; f()`, "ERROR: 1:16: assertion failed: 1 != 2\n\tin f, called at 1:37"},
+		{`assert()`, "ERROR: 1:1: wrong number of arguments. got=0, want=1 or 2"},
+	}
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestTestMode(t *testing.T) {
+	defer func() { TestMode = false; ResetFailures() }()
+	TestMode = true
+	ResetFailures()
+
+	input := `assert(1 == 1, "fine");
+assert(1 == 2, "first");
+let f = fn() { assertEqual("a", "b") };
+f();
+assert()`
+	result := testEval(input)
+	errObj, ok := result.(*object.Error)
+	if !ok || errObj.Message != "wrong number of arguments. got=0, want=1 or 2" {
+		t.Errorf("a broken assertion doesn't end the program. got=%s", result.Inspect())
+	}
+
+	expected := []string{
+		"ERROR: 2:1: assertion failed: first",
+		`ERROR: 3:16: assertion failed: "a" != "b"`,
+	}
+	failures := Failures()
+	if len(failures) != len(expected) {
+		t.Fatalf("wrong number of failures. want=%d, got=%d", len(expected), len(failures))
+	}
+	for i, failure := range failures {
+		if failure.Inspect() != expected[i] {
+			t.Errorf("failures[%d] wrong. expected=%q, got=%q", i, expected[i], failure.Inspect())
+		}
+	}
+
+	ResetFailures()
+	if len(Failures()) != 0 {
+		t.Errorf("ResetFailures() left %d failures", len(Failures()))
+	}
+}
+
+func TestEvalBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`eval("1 + 2")`, "3"},
+		{`eval("")`, "null"},
+		{`let x = 5; eval("x * 2")`, "10"},
+		{`eval("let y = 7"); y`, "7"},
+		{`let f = fn() { let z = 1; eval("z + 1") }; f()`, "2"},
+		{`eval("return 4; 5")`, "4"},
+		{`let code = "1 + 1"; eval("eval(code)")`, "2"},
+		{`let x = 1; eval("x = 2"); x`, "2"},
+		{`let x = 5; eval("x", {})`, "ERROR: 1:1: identifier not found: x"},
+		{`eval("a + b", {"a": 1, "b": 2})`, "3"},
+		{`eval("let q = 1", {}); q`, "ERROR: 1:24: identifier not found: q"},
+		{`map(["1 + 1", "2 * 3"], eval)`, "[2, 6]"},
+		{`eval("1 +")`, "ERROR: 1:1: eval: no prefix parse functions for EOF found"},
+		{`eval("1 + true")`, "ERROR: 1:3: type mismatch: INTEGER + BOOLEAN"},
+		{`eval(1)`, "ERROR: 1:1: first argument to 'eval()' must be STRING, got INTEGER"},
+		{`eval("1", {1: 2})`, "ERROR: 1:1: names bound by 'eval()' must be STRING, got INTEGER"},
+	}
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc"[0]`, "a"},
+		{`"abc"[2]`, "c"},
+		{`"abc"[-1]`, "c"},
+		{`"abc"[-3]`, "a"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-4]`, nil},
+		{`""[0]`, nil},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[2]`, "l"},
+		{`"日本語"[-1]`, "語"},
+		{`"héllo"[1:3]`, "él"},
+		{`"日本語"[:-1]`, "日本"},
+		{`charAt("héllo", 1)`, "é"},
+		{`charAt("héllo", 5)`, nil},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if str, ok := tt.expected.(string); ok {
+			testStringObject(t, evaluated, str)
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestUnicodeStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("héllo")`, 5},
+		{`len("日本語")`, 3},
+		{`len("")`, 0},
+		{`indexOf("héllo", "l")`, 2},
+		{`indexOf("日本語", "語")`, 2},
+		{`codePoint("héllo", 1)`, 233},
+		{`codePoint("a", 0)`, 97},
+		{`codePoint("日本語", -1)`, 35486},
+		{`codePoint("a", 1)`, nil},
+		{`charAt(1, 0)`, "first argument to 'charAt()' must be STRING, got INTEGER"},
+		{`codePoint("a", "0")`, "second argument to 'codePoint()' must be INTEGER, got STRING"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+			} else if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3, 4][1:3]`, "[2, 3]"},
+		{`[1, 2, 3][:2]`, "[1, 2]"},
+		{`[1, 2, 3][1:]`, "[2, 3]"},
+		{`[1, 2, 3][:]`, "[1, 2, 3]"},
+		{`[1, 2, 3][2:1]`, "[]"},
+		{`[1, 2, 3][1:10]`, "[2, 3]"},
+		{`let a = [1, 2]; let b = a[:]; b[0] = 5; a`, "[1, 2]"},
+		{`"hello"[1:4]`, "ell"},
+		{`"hello"[3:]`, "lo"},
+		{`[1][1 + true:]`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`[1]["a":]`, "ERROR: slice index must be INTEGER, got STRING"},
+		{`{}[0:1]`, "ERROR: slice operator not supported: HASH"},
+		{`[1, 2, 3, 4][-2:]`, "[3, 4]"},
+		{`[1, 2, 3, 4][:-1]`, "[1, 2, 3]"},
+		{`[1, 2, 3, 4][-3:-1]`, "[2, 3]"},
+		{`[1, 2, 3][-10:]`, "[1, 2, 3]"},
+		{`"hello"[-3:]`, "llo"},
+		{`bytes("hello")[:-2]`, `b"hel"`},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = "ERROR: " + errObj.Message
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(file, []byte{0, 1, 'a', 255}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if errObj, ok := testEval(`readFileBytes("` + file + `")`).(*object.Error); !ok || errObj.Message != "readFileBytes is disabled" {
+		t.Fatalf("readFileBytes isn't disabled by default. got=%+v", errObj)
+	}
+	defer func(allow bool) { AllowFiles = allow }(AllowFiles)
+	AllowFiles = true
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`bytes("hi")`, `b"hi"`},
+		{`bytes([104, 0, 255])`, `b"h\x00\xff"`},
+		{`type(bytes(""))`, "BYTES"},
+		{`len(bytes("héllo"))`, "6"},
+		{`bytes("abc")[1]`, "98"},
+		{`bytes("abc")[5]`, "null"},
+		{`bytes("abc")[-1]`, "99"},
+		{`let b = bytes("abc"); b[-1] = 68; toString(b)`, "abD"},
+		{`bytes("hello")[1:3]`, `b"el"`},
+		{`toString(bytes("héllo")[0:3])`, "hé"},
+		{`let b = bytes("abc"); b[0] = 65; toString(b)`, "Abc"},
+		{`let b = bytes("abc"); let c = bytes(b); c[0] = 65; toString(b)`, "abc"},
+		{`contains([bytes("a")], bytes("a"))`, "true"},
+		{`readFileBytes("` + file + `")`, `b"\x00\x01a\xff"`},
+		{`bytes([256])`, "ERROR: byte must be INTEGER from 0 to 255, got 256"},
+		{`let b = bytes("a"); b[0] = -1`, "ERROR: byte must be INTEGER from 0 to 255, got -1"},
+		{`bytes(1)`, "ERROR: argument to 'bytes()' not supported, got INTEGER"},
+		{`toString("a")`, "ERROR: argument to 'toString()' must be BYTES, got STRING"},
+		{`readFileBytes("/no/such/file")`, "ERROR: readFileBytes: open /no/such/file: no such file or directory"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = "ERROR: " + errObj.Message
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestApplyFunction(t *testing.T) {
+	fn := testEval("fn(a, b) { a * b }")
+	testIntegerObject(t, ApplyFunction(fn, &object.Integer{Value: 6}, &object.Integer{Value: 7}), 42)
+	testIntegerObject(t, ApplyFunction(builtins["len"], &object.String{Value: "four"}), 4)
+
+	errObj, ok := ApplyFunction(&object.Integer{Value: 1}).(*object.Error)
+	if !ok || errObj.Message != "not a function: INTEGER" {
+		t.Errorf("wrong result for calling an integer. got=%+v", errObj)
+	}
+}
+
+// errorMessage is the expected message of an error in tests that expect strings too
+type errorMessage string
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	str, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if str.Value != expected {
+		t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestPmapBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`pmap([1, 2, 3, 4], fn(x) { x * 2 })`, []int64{2, 4, 6, 8}},
+		{`let n = 10; pmap([1, 2, 3], fn(x) { let y = x + n; y })`, []int64{11, 12, 13}},
+		{`pmap([], fn(x) { x })`, []int64{}},
+		{`pmap(["a", "bb"], len)`, []int64{1, 2}},
+		{`pmap([1, true, 3], fn(x) { -x })`, "unknown operator: -BOOLEAN"},
+		{`pmap([1, 2], fn(a, b) { a })`, "wrong number of arguments. got=1, want=2"},
+		{`pmap(1, fn(x) { x })`, "argument to 'pmap()' must be ARRAY, got INTEGER"},
+		{`pmap([1], 1)`, "second argument to 'pmap()' must be FUNCTION, got INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], expectedElem)
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestMapFilterReduce(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, []int64{2, 4, 6}},
+		{`map([], fn(x) { x })`, []int64{}},
+		{`map(["a", "bb"], len)`, []int64{1, 2}},
+		{`let a = [1, 2]; map(a, fn(x) { x + 1 }); a`, []int64{1, 2}},
+		{`filter([1, 2, 3, 4], fn(x) { x % 2 == 0 })`, []int64{2, 4}},
+		{`filter([1, 2, 3], fn(x) { null })`, []int64{}},
+		{`reduce([1, 2, 3, 4], 0, fn(acc, x) { acc + x })`, 10},
+		{`reduce([], 7, fn(acc, x) { acc + x })`, 7},
+		{`reduce([[1], [2, 3]], 0, fn(acc, x) { acc + len(x) })`, 3},
+		{`let n = 0; map([1, 2, 3], fn(x) { n = n + x }); n`, 6},
+		{`reduce(map(filter([1, 2, 3, 4, 5, 6], |x| x > 2), |x| x * x), 0, |a, b| a + b)`, 86},
+		{`map([1, true, 3], fn(x) { -x })`, "unknown operator: -BOOLEAN"},
+		{`filter([1, 2], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`reduce([1], 0, fn(acc, x) { y })`, "identifier not found: y"},
+		{`map(1, fn(x) { x })`, "argument to 'map()' must be ARRAY, got INTEGER"},
+		{`filter([1], 1)`, "second argument to 'filter()' must be FUNCTION, got INTEGER"},
+		{`reduce([1], fn(x) { x }, 0)`, "third argument to 'reduce()' must be FUNCTION, got INTEGER"},
+		{`reduce([1], 0)`, "wrong number of arguments. got=2, want=3"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], expectedElem)
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestFutures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let f = spawn(fn(x, y) { x + y }, 1, 2); await(f)`, 3},
+		{`let n = 5; await(spawn(fn() { n * n }))`, 25},
+		{`let f = spawn(fn() { 1 }); await(f); isDone(f)`, true},
+		{`await(spawn(len, "four"))`, 4},
+		{`let f = spawn(fn() { 1 }); await(f); cancel(f)`, false},
+		{`await(spawn(fn() { -true }))`, "unknown operator: -BOOLEAN"},
+		{`await(spawn(fn(a) { a }))`, "wrong number of arguments. got=0, want=1"},
+		{`await(spawn(fn(a) { a }, 1, 2))`, "wrong number of arguments. got=2, want=1"},
+		{`spawn(1)`, "argument to 'spawn()' must be FUNCTION, got INTEGER"},
+		{`await(1)`, "argument to 'await()' must be FUTURE, got INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestWaitAllAndRace(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`waitAll([spawn(fn() { sleep(10); 1 }), spawn(fn() { 2 }), spawn(fn() { "three" })])`, "[1, 2, three]"},
+		{`waitAll([])`, "[]"},
+		{`waitAll([spawn(fn() { if (false) { 1 } })])`, "[null]"},
+		{`waitAll([spawn(fn() { 1 }), spawn(fn() { 1 / 0 }), spawn(fn() { throw "boom" })])`,
+			"ERROR: 1:1: waitAll: 2 of 3 futures failed: 1: division by zero: 1 / 0; 2: boom"},
+		{`let f = spawn(fn() { sleep(1000000) }); cancel(f); waitAll([spawn(fn() { 1 }), f])`,
+			"ERROR: 1:52: waitAll: 1 of 2 futures failed: 1: future was cancelled"},
+		{`let log = ""; let f = spawn(fn() { sleep(20); log = log + "slow" }); try { waitAll([f, spawn(fn() { 1 / 0 })]) } catch (e) { log }`, "slow"},
+		{`race([spawn(fn() { sleep(1000000); 1 }), spawn(fn() { 2 })])`, "2"},
+		{`let slow = spawn(fn() { sleep(1000000) }); race([slow, spawn(fn() { 2 })]); sleep(5); slow`, "future(cancelled)"},
+		{`race([spawn(fn() { sleep(1000000) }), spawn(fn() { throw "boom" })])`, "ERROR: 1:52: boom"},
+		{`let f = spawn(fn() { 1 }); await(f); race([f, f])`, "1"},
+		{`race([])`, "ERROR: 1:1: argument to 'race()' must not be empty"},
+		{`waitAll(1)`, "ERROR: 1:1: argument to 'waitAll()' must be ARRAY, got INTEGER"},
+		{`race([spawn(fn() { 1 }), 2])`, "ERROR: 1:1: element 1 of the argument to 'race()' must be FUTURE, got INTEGER"},
+		{`waitAll()`, "ERROR: 1:1: wrong number of arguments. got=0, want=1"},
+	}
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	defer func(ctx context.Context) { Context = ctx }(Context)
+	interrupts := []struct {
+		input    string
+		expected string
+	}{
+		{"try { waitAll([spawn(fn() { sleep(1000000) })]) } catch (e) { 1 }", "ERROR: 1:7: waitAll interrupted: context canceled"},
+		{"try { race([spawn(fn() { sleep(1000000) })]) } catch (e) { 1 }", "ERROR: 1:7: race interrupted: context canceled"},
+	}
+	for _, tt := range interrupts {
+		ctx, cancel := context.WithCancel(context.Background())
+		Context = ctx
+		time.AfterFunc(10*time.Millisecond, cancel)
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestCancelInterrupts cancels spawned functions that would otherwise never end, and waits for
+// their deferred expressions to say they did
+func TestCancelInterrupts(t *testing.T) {
+	bodies := []string{
+		"while (true) {}",
+		"for (i in 0..1000000000000) {}",
+		"let loop = fn() { loop() }; loop()",
+		"let loop = fn(n) { if (n > 0) { loop(n - 1) }; loop(n + 1) }; loop(0)",
+		"sleep(1000000)",
+		"receive(channel())",
+		"send(channel(), 1)",
+		"select([channel()])",
+		"for (x in channel()) {}",
+		"toArray(channel())",
+		"select { case recv(channel()): 1 }",
+		"await(spawn(fn() { receive(channel()) }))",
+		"pmap([1, 2], fn(x) { while (true) {} })",
+		"map([1], fn(x) { while (true) {} })",
+		`eval("while (true) {}", {})`,
+		"while (true) { try { sleep(1000000) } catch (e) {} }",
+	}
+	for _, body := range bodies {
+		input := `let state = "running";
+let f = spawn(fn() { defer state = "stopped"; ` + body + ` });
+sleep(5);
+[cancel(f), isDone(f)];
+let i = 0;
+while (state == "running" && i < 500) { sleep(10); i = i + 1 };
+state`
+		if got := testEval(input).Inspect(); got != "stopped" {
+			t.Errorf("spawned %q wasn't interrupted by cancel. got=%q", body, got)
+		}
+	}
+}
+
+func TestContextInterrupts(t *testing.T) {
+	defer func(ctx context.Context) { Context = ctx }(Context)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (true) {}", "ERROR: 1:1: interrupted: context canceled"},
+		{`eval("while (true) {}", {"x": 1})`, "ERROR: 1:1: interrupted: context canceled"},
+		{"let f = fn() { f() }; f()", "ERROR: 1:16: interrupted: context canceled\n\tin f, called at 1:23"},
+		{"for (x in 0..1000000000000) {}", "ERROR: 1:1: interrupted: context canceled"},
+		{"for (x in channel()) {}", "ERROR: 1:1: receive interrupted: context canceled"},
+		{"toArray(channel())", "ERROR: 1:1: receive interrupted: context canceled"},
+		{"select { case send(channel(), 1): 1 }", "ERROR: 1:1: select interrupted: context canceled"},
+		{"await(spawn(fn() { while (true) {} }))", "ERROR: 1:1: await interrupted: context canceled"},
+		{"let f = spawn(fn() { while (true) {} }); sleep(1000000)", "ERROR: 1:42: sleep interrupted: context canceled"},
+		{"while (true) { try { sleep(10) } catch (e) {} }", "ERROR: 1:22: sleep interrupted: context canceled"},
+		{`let s = "none"; try { receive(channel()) } catch (e) { s = e }; s`, "ERROR: 1:23: receive interrupted: context canceled"},
+		{"let f = fn() { try { sleep(1000000) } catch (e) { 1 } }; f()", "ERROR: 1:22: sleep interrupted: context canceled\n\tin f, called at 1:58"},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+		Context = ctx
+		time.AfterFunc(10*time.Millisecond, cancel)
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSelectExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let c = channel(1); send(c, 5); select { case v = recv(c): v * 2 }", "10"},
+		{`let c = channel(); select { case recv(c): "got" default: "none" }`, "none"},
+		{`let c = channel(1); [select { case send(c, 3): "sent" }, receive(c)]`, "[sent, 3]"},
+		{`let c = channel(); select { case send(c, 1): "sent" default: "full" }`, "full"},
+		{"let c = channel(); close(c); select { case v = recv(c): [v] }", "[null]"},
+		{`let a = channel(); let b = channel(1); send(b, "b"); select { case v = recv(a): v case v = receive(b): v }`, "b"},
+		{"let c = channel(); spawn(fn() { sleep(5); send(c, 7) }); select { case v = recv(c): v + 1 }", "8"},
+		{"let c = channel(); let f = spawn(fn() { receive(c) }); select { case send(c, 9): 1 }; await(f)", "9"},
+		{"let v = 1; let c = channel(1); send(c, 2); select { case v = recv(c): v }; v", "1"},
+		{"let c = channel(1); send(c, 1); select { case recv(c): }", "null"},
+		{"select { default: 5 }", "5"},
+		{"let c = channel(); let n = 0; select { case send(c, n = n + 1): 1 default: n }", "1"},
+		{`let c = channel(4); for (i in 1..4) { send(c, i) }; close(c);
+		  let s = 0; while (true) { select { case v = recv(c): if (v == null) { break }; s = s + v } }; s`, "6"},
+		{"let f = fn(c) { select { case v = recv(c): return v * 3 }; 0 }; let c = channel(1); send(c, 2); f(c)", "6"},
+		{"select { case recv(1): 1 }", "ERROR: 1:15: argument to 'recv()' in select must be CHANNEL, got INTEGER"},
+		{"let c = channel(); close(c); select { case send(c, 1): 1 }", "ERROR: 1:44: send on closed channel"},
+		{"let c = channel(1); send(c, 1); select { case recv(c): 1 / 0 }", "ERROR: 1:58: division by zero: 1 / 0"},
+		{"select { case recv(x): 1 }", "ERROR: 1:20: identifier not found: x"},
+	}
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestChannels(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let c = channel(1); send(c, 5); receive(c)`, "5"},
+		{`let c = channel(); spawn(fn() { send(c, "hi") }); receive(c)`, "hi"},
+		{`let c = channel(2); send(c, 1); send(c, 2); close(c); [receive(c), receive(c), receive(c)]`, "[1, 2, null]"},
+		{`let c = channel(); close(c); [close(c), c]`, "[false, channel(closed)]"},
+		{`channel(3)`, "channel(open)"},
+		{`let c = channel(); close(c); send(c, 1)`, "ERROR: send on closed channel"},
+		{`let c = channel();
+		  spawn(fn() { for (i in 0..5) { send(c, i) }; close(c) });
+		  let sum = 0; for (x in c) { sum = sum + x }; sum`, "10"},
+		{`let c = channel(); let done = channel();
+		  let f = spawn(fn() { let n = 0; for (x in c) { n = n + x }; send(done, n); n });
+		  for (i in 1..101) { send(c, i) }; close(c); [receive(done), await(f)]`, "[5050, 5050]"},
+		{`let a = channel(); let b = channel(1); send(b, "b"); select([a, b])`, "[1, b]"},
+		{`let a = channel(); close(a); select([a])`, "[0, null]"},
+		{`let a = channel(); spawn(fn() { send(a, 1) }); select([channel(), a])`, "[1, 1]"},
+		{`channel(-1)`, "ERROR: argument to 'channel()' must not be negative, got -1"},
+		{`channel(9223372036854775807)`, "ERROR: argument to 'channel()' must not be more than 1048576, got 9223372036854775807"},
+		{`channel(1048576)`, "channel(open)"},
+		{`channel("1")`, "ERROR: argument to 'channel()' must be INTEGER, got STRING"},
+		{`channel(1, 2)`, "ERROR: wrong number of arguments. got=2, want=0 or 1"},
+		{`send(1, 2)`, "ERROR: first argument to 'send()' must be CHANNEL, got INTEGER"},
+		{`receive([])`, "ERROR: argument to 'receive()' must be CHANNEL, got ARRAY"},
+		{`close("c")`, "ERROR: argument to 'close()' must be CHANNEL, got STRING"},
+		{`select([])`, "ERROR: argument to 'select()' must not be empty"},
+		{`select([channel(), 1])`, "ERROR: element 1 of the argument to 'select()' must be CHANNEL, got INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = "ERROR: " + errObj.Message
+		} else if str, ok := evaluated.(*object.String); ok {
+			got = str.Value
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// /// ARRAYS /////
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got = %T (%+v)", evaluated, evaluated)
+	}
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements, got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			"[1, 2, 3][0]",
+			1,
+		},
+		{
+			"[1, 2, 3][1]",
+			2,
+		},
+		{
+			"[1, 2, 3][2]",
+			3,
+		},
+		{
+			"let i = 0; [1][i];",
+			1,
 		},
 		{
 			"[1, 2, 3][1 + 1];",
@@ -408,6 +2338,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -422,7 +2360,33 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
-// when Eval encounters a *ast.HashLiteral, we want a frest *object.Hash 
+func TestArrayIndexOutOfRangeError(t *testing.T) {
+	OutOfRange = IndexError
+	defer func() { OutOfRange = IndexNull }()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3][3]", "index out of range: 3 with length 3"},
+		{"[1, 2, 3][-4]", "index out of range: -4 with length 3"},
+		{`"abc"[3]`, "index out of range: 3 with length 3"},
+		{"[][0]", "index out of range: 0 with length 0"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+	testIntegerObject(t, testEval("[1, 2, 3][2]"), 3)
+}
+
+// when Eval encounters a *ast.HashLiteral, we want a frest *object.Hash
 // with the correct number of HashPairs mapped to the matching HashKeys in its Pairs attribute
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
@@ -440,12 +2404,12 @@ func TestHashLiterals(t *testing.T) {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
 	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey(): 1, 
-		(&object.String{Value: "two"}).HashKey(): 2,
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
 		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey(): 4,
-		TRUE.HashKey(): 5,
-		FALSE.HashKey(): 6,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
 	}
 	if len(result.Pairs) != len(expected) {
 		t.Fatalf("Has has wrong number of pairs. got=%d", len(result.Pairs))
@@ -461,36 +2425,36 @@ func TestHashLiterals(t *testing.T) {
 
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
-		input string
+		input    string
 		expected interface{}
 	}{
 		{
 			`{"foo": 5}["foo"]`,
-			5, 
-		}, 
+			5,
+		},
 		{
 			`{"foo": 5}["bar"]`,
-			nil, 
-		},		
+			nil,
+		},
 		{
 			`let key = "foo"; {"foo": 5}[key]`,
-			5, 
-		}, 
+			5,
+		},
 		{
 			`{}["foo"]`,
-			nil, 
-		}, 
+			nil,
+		},
 		{
 			`{5: 5}[5]`,
-			5, 
-		}, 
+			5,
+		},
 		{
 			`{true: 5}[true]`,
-			5, 
-		}, 
+			5,
+		},
 		{
 			`{false: 5}[false]`,
-			5, 
+			5,
 		},
 	}
 	for _, tt := range tests {
@@ -504,7 +2468,7 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
-/// HELPERS ///
+// / HELPERS ///
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -530,6 +2494,19 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 
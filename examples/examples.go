@@ -0,0 +1,86 @@
+// Package examples bundles a few annotated Monkey programs, shown by `monkey examples`. Each one
+// starts with a `// Title: summary` line and ends with an `// Output:` comment listing what it
+// prints, which the tests check, so the examples can't drift away from what the interpreter does
+package examples
+
+import (
+	"bytes"
+	"embed"
+	"io"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed *.monkey
+var files embed.FS
+
+const outputMarker = "// Output:\n"
+
+type Example struct {
+	Name    string // the file name without .monkey, eg higher_order
+	Summary string // the first line, without the leading //
+	Source  string // the whole program, including the Output comment
+	Output  string // what the program is expected to print
+}
+
+// All returns every bundled example, sorted by name
+func All() []*Example {
+	entries, _ := files.ReadDir(".")
+	all := []*Example{}
+	for _, e := range entries {
+		src, _ := files.ReadFile(e.Name())
+		all = append(all, parse(strings.TrimSuffix(e.Name(), path.Ext(e.Name())), string(src)))
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Lookup returns the example called name, or nil if there isn't one
+func Lookup(name string) *Example {
+	for _, e := range All() {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+func parse(name, src string) *Example {
+	e := &Example{Name: name, Source: src}
+	firstLine := strings.SplitN(src, "\n", 2)[0]
+	e.Summary = strings.TrimSpace(strings.TrimPrefix(firstLine, "//"))
+
+	if i := strings.LastIndex(src, outputMarker); i >= 0 {
+		var out bytes.Buffer
+		for _, line := range strings.Split(strings.TrimSpace(src[i+len(outputMarker):]), "\n") {
+			out.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "//"), " ") + "\n")
+		}
+		e.Output = out.String()
+	}
+	return e
+}
+
+// Run evaluates the example in a fresh environment, sending whatever it prints to out. It returns
+// the value of the program, which is an *object.Error if it failed, or nil on a syntax error, in
+// which case the parser errors are written to out.
+// Run redirects evaluator.Stdout while it runs, so it mustn't be called from several goroutines
+func (e *Example) Run(out io.Writer) object.Object {
+	p := parser.New(lexer.New(e.Source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			io.WriteString(out, msg+"\n")
+		}
+		return nil
+	}
+
+	stdout := evaluator.Stdout
+	evaluator.Stdout = out
+	defer func() { evaluator.Stdout = stdout }()
+	return evaluator.Eval(program, object.NewEnvironment())
+}
@@ -0,0 +1,42 @@
+package examples
+
+import (
+	"bytes"
+	"monkey/object"
+	"testing"
+)
+
+func TestExamples(t *testing.T) {
+	all := All()
+	if len(all) == 0 {
+		t.Fatalf("no examples bundled")
+	}
+	for _, e := range all {
+		if e.Summary == "" || e.Output == "" {
+			t.Errorf("%s: missing summary line or Output comment", e.Name)
+			continue
+		}
+
+		var out bytes.Buffer
+		result := e.Run(&out)
+		if err, ok := result.(*object.Error); ok {
+			t.Errorf("%s: failed: %s", e.Name, err.Message)
+		}
+		if out.String() != e.Output {
+			t.Errorf("%s: wrong output. want=\n%s\ngot=\n%s", e.Name, e.Output, out.String())
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	e := Lookup("closures")
+	if e == nil {
+		t.Fatalf("Lookup(\"closures\") returned nil")
+	}
+	if e.Summary != "Closures: functions that remember the bindings around them" {
+		t.Errorf("wrong summary. got=%q", e.Summary)
+	}
+	if Lookup("nope") != nil {
+		t.Errorf("Lookup(\"nope\") returned an example")
+	}
+}
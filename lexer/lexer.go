@@ -1,6 +1,11 @@
 package lexer
 
-import "monkey/token"
+import (
+	"fmt"
+	"monkey/token"
+	"strconv"
+	"strings"
+)
 
 // A Lexer has an input (the code we're interpreting), a current character ch, ch's position, and the next position
 type Lexer struct {
@@ -8,17 +13,41 @@ type Lexer struct {
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // line of the current char, starting at 1
+	lineStart    int  // position of the first char of the current line
+	base         int  // offset of the input in the source it was taken from, see NewAt
+	filename     string
 }
 
 // New creates a Lexer with the given input (Monkey) code
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
-// readChar reads the next position, incrementing l.position (current) and l.readPosition (next)
+// NewFile creates a Lexer over the source of f, whose tokens have f's name as their Filename
+func NewFile(f *token.File) *Lexer {
+	l := New(f.Source())
+	l.filename = f.Name()
+	return l
+}
+
+// NewAt creates a Lexer over input that was taken from a bigger source at pos, eg the expression
+// of a ${...} in a string literal, so its tokens have their positions in that source
+func NewAt(input string, pos token.Position) *Lexer {
+	l := &Lexer{input: input, line: pos.Line, lineStart: 1 - pos.Column, base: pos.Offset, filename: pos.Filename}
+	l.readChar()
+	return l
+}
+
+// readChar reads the next position, incrementing l.position (current) and l.readPosition (next).
+// Stepping past a newline moves us to the start of the next line
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.readPosition
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -37,12 +66,20 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
-// NextToken looks at the current character, returns a token depending on which character it is. However, before doing so, though, it advances out pointer into the input, so the next time it is called, 1.ch is already updated
+// NextToken skips whitespace and returns the next token, stamped with the position it starts at
 func (l *Lexer) NextToken() token.Token {
+	l.skipWhitespace()
 
-	var tok token.Token
+	pos := token.Position{Filename: l.filename, Offset: l.base + l.position, Line: l.line, Column: l.position - l.lineStart + 1}
+	tok := l.readToken()
+	tok.Position = pos
+	return tok
+}
 
-	l.skipWhitespace()
+// readToken looks at the current character, returns a token depending on which character it is. However, before doing so, though, it advances out pointer into the input, so the next time it is called, 1.ch is already updated
+func (l *Lexer) readToken() token.Token {
+
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
@@ -51,6 +88,11 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.ARROW, Literal: literal}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -68,9 +110,30 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
+		if l.peekChar() == '/' {
+			tok.Type = token.COMMENT
+			tok.Literal = l.readComment()
+			return tok
+		}
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
+	case '|':
+		if l.peekChar() == '|' {
+			l.readChar()
+			tok = token.Token{Type: token.OR, Literal: "||"}
+		} else {
+			tok = newToken(token.PIPE, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			l.readChar()
+			tok = token.Token{Type: token.AND, Literal: "&&"}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '<':
 		tok = newToken(token.LT, l.ch)
 	case '>':
@@ -99,6 +162,15 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Literal = l.readString()
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.DOTDOT, Literal: literal}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	default: // checks for identifiers whenever the l.ch is not a recognized character
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
@@ -107,6 +179,11 @@ func (l *Lexer) NextToken() token.Token {
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			if l.ch == '.' && isDigit(l.peekChar()) { // but not 1..10
+				l.readChar()
+				tok.Type = token.FLOAT
+				tok.Literal += "." + l.readNumber()
+			}
 			return tok
 		} else { // if we end up here, we don't know how to handle the current character
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -154,14 +231,153 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-// calls readChar until it encounters a closing double quote of the end of input
+// readComment reads from the current '/' up to, but not including, the end of the line
+func (l *Lexer) readComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	return l.input[position:l.position]
+}
+
+// calls readChar until it encounters a closing double quote of the end of input. The literal is the
+// source between the quotes with its escape sequences and interpolations left in, a backslash only
+// keeps the character after it from closing the string. SplitString and Unescape take it apart
 func (l *Lexer) readString() string {
 	position := l.position + 1
 	for {
 		l.readChar()
+		if l.ch == '\\' && l.peekChar() != 0 {
+			l.readChar()
+			continue
+		}
+		if l.ch == '$' && l.peekChar() == '{' {
+			l.readChar()
+			l.skipInterpolation()
+		}
 		if l.ch == '"' || l.ch == 0 {
 			break
 		}
 	}
 	return l.input[position:l.position]
 }
+
+// skipInterpolation moves from the '{' of a ${...} to its closing '}', over nested braces and
+// strings, which can have interpolations of their own. It stops at the end of input if there's none
+func (l *Lexer) skipInterpolation() {
+	depth := 0
+	for {
+		switch l.ch {
+		case 0:
+			return
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return
+			}
+		case '"':
+			l.readString()
+			if l.ch == 0 {
+				return
+			}
+		}
+		l.readChar()
+	}
+}
+
+// A StringPart is a piece of the literal of a STRING token, either text or a ${...}
+type StringPart struct {
+	Offset int    // of the part in the literal, for a ${...} that of the expression after "${"
+	Text   string // the text with its escape sequences, or the source of the expression
+	Expr   bool   // whether the part is a ${...}
+	// Unterminated is set for a ${...} that runs to the end of the literal without its closing '}'
+	Unterminated bool
+}
+
+// SplitString splits the literal of a STRING token into its text and its interpolated
+// expressions, in order. A literal without any ${...} is a single text part
+func SplitString(literal string) []StringPart {
+	var parts []StringPart
+	l := New(literal)
+	start := 0
+	for l.ch != 0 {
+		switch {
+		case l.ch == '\\':
+			l.readChar()
+			if l.ch != 0 {
+				l.readChar()
+			}
+		case l.ch == '$' && l.peekChar() == '{':
+			if l.position > start {
+				parts = append(parts, StringPart{Offset: start, Text: literal[start:l.position]})
+			}
+			l.readChar()
+			open := l.position + 1
+			l.skipInterpolation()
+			parts = append(parts, StringPart{Offset: open, Text: literal[open:l.position], Expr: true, Unterminated: l.ch == 0})
+			if l.ch != 0 {
+				l.readChar()
+			}
+			start = l.position
+		default:
+			l.readChar()
+		}
+	}
+	if start < len(literal) || len(parts) == 0 {
+		parts = append(parts, StringPart{Offset: start, Text: literal[start:]})
+	}
+	return parts
+}
+
+// An EscapeError is an invalid escape sequence in a string literal
+type EscapeError struct {
+	Offset int // byte offset of the backslash in the literal
+	Msg    string
+}
+
+func (e *EscapeError) Error() string { return e.Msg }
+
+// Unescape turns the literal of a STRING token into the string it stands for. The escape sequences
+// are \n, \t, \r, \\, \", \$ and \uXXXX with four hex digits for a Unicode code point
+func Unescape(literal string) (string, error) {
+	if !strings.Contains(literal, `\`) {
+		return literal, nil
+	}
+	var out strings.Builder
+	for i := 0; i < len(literal); i++ {
+		ch := literal[i]
+		if ch != '\\' {
+			out.WriteByte(ch)
+			continue
+		}
+		if i+1 == len(literal) {
+			return "", &EscapeError{Offset: i, Msg: "unterminated escape sequence"}
+		}
+		switch esc := literal[i+1]; esc {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case '\\', '"', '$':
+			out.WriteByte(esc)
+		case 'u':
+			if i+6 > len(literal) {
+				return "", &EscapeError{Offset: i, Msg: fmt.Sprintf("truncated escape sequence %s, want 4 hex digits", literal[i:])}
+			}
+			r, err := strconv.ParseUint(literal[i+2:i+6], 16, 32)
+			if err != nil {
+				return "", &EscapeError{Offset: i, Msg: fmt.Sprintf("invalid escape sequence %s, want 4 hex digits", literal[i:i+6])}
+			}
+			out.WriteRune(rune(r))
+			i += 4
+		default:
+			return "", &EscapeError{Offset: i, Msg: fmt.Sprintf("invalid escape sequence \\%c", esc)}
+		}
+		i++
+	}
+	return out.String(), nil
+}
@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"monkey/token"
+	"reflect"
 	"testing"
 )
 
@@ -29,7 +30,15 @@ func TestNextToken(t *testing.T) {
   "foo bar"
   [1, 2];
   {"foo": "bar"}
-  `
+  1..10
+  match (x) { _ => 1 }
+  try {} catch (e) { throw e; }
+  a / b // a comment
+  // another one
+  macro(x) { x }
+  |a| a
+  null
+  const c = 1;`
 
 	tests := []struct {
 		expectedType    token.TokenType
@@ -121,6 +130,52 @@ func TestNextToken(t *testing.T) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.INT, "1"},
+		{token.DOTDOT, ".."},
+		{token.INT, "10"},
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "_"},
+		{token.ARROW, "=>"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.TRY, "try"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.CATCH, "catch"},
+		{token.LPAREN, "("},
+		{token.IDENT, "e"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.THROW, "throw"},
+		{token.IDENT, "e"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "a"},
+		{token.SLASH, "/"},
+		{token.IDENT, "b"},
+		{token.COMMENT, "// a comment"},
+		{token.COMMENT, "// another one"},
+		{token.MACRO, "macro"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		{token.PIPE, "|"},
+		{token.IDENT, "a"},
+		{token.PIPE, "|"},
+		{token.IDENT, "a"},
+		{token.NULL, "null"},
+		{token.CONST, "const"},
+		{token.IDENT, "c"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
 		{token.EOF, ""},
 	}
 	l := New(input)
@@ -137,3 +192,209 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  \"a\nb\" + y"
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedOffset int
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 0, 1, 1},
+		{token.IDENT, 4, 1, 5},
+		{token.ASSIGN, 6, 1, 7},
+		{token.INT, 8, 1, 9},
+		{token.SEMICOLON, 9, 1, 10},
+		{token.STRING, 13, 2, 3},
+		{token.PLUS, 19, 3, 4},
+		{token.IDENT, 21, 3, 6},
+		{token.EOF, 22, 3, 7},
+	}
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token-type wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Offset != tt.expectedOffset || tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d %d:%d, got=%d %d:%d", i,
+				tt.expectedOffset, tt.expectedLine, tt.expectedColumn, tok.Offset, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("main.mk", "let x = 5;\n  \"a\nb\" + y")
+	l := NewFile(f)
+
+	for tok := l.NextToken(); ; tok = l.NextToken() {
+		if tok.Position != f.Position(tok.Offset) {
+			t.Fatalf("%s token at the wrong position. expected=%+v, got=%+v", tok.Type, f.Position(tok.Offset), tok.Position)
+		}
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	input := `"say \"hi\"" "a\\" "\`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, `say \"hi\"`},
+		{token.STRING, `a\\`},
+		{token.STRING, `\`},
+		{token.EOF, ""},
+	}
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"a ${f("}", {"x": 1})} b" "$x {y}" "${"in${"most"}"}"+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, `a ${f("}", {"x": 1})} b`},
+		{token.STRING, `$x {y}`},
+		{token.STRING, `${"in${"most"}"}`},
+		{token.PLUS, "+"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestSplitString(t *testing.T) {
+	tests := []struct {
+		literal  string
+		expected []StringPart
+	}{
+		{``, []StringPart{{Offset: 0, Text: ""}}},
+		{`plain \${x}`, []StringPart{{Offset: 0, Text: `plain \${x}`}}},
+		{`a ${x + 1} b`, []StringPart{{Offset: 0, Text: "a "}, {Offset: 4, Text: "x + 1", Expr: true}, {Offset: 10, Text: " b"}}},
+		{`${x}${"}"}`, []StringPart{{Offset: 2, Text: "x", Expr: true}, {Offset: 6, Text: `"}"`, Expr: true}}},
+		{`a ${x`, []StringPart{{Offset: 0, Text: "a "}, {Offset: 4, Text: "x", Expr: true, Unterminated: true}}},
+		{`${"`, []StringPart{{Offset: 2, Text: `"`, Expr: true, Unterminated: true}}},
+	}
+
+	for _, tt := range tests {
+		parts := SplitString(tt.literal)
+		if !reflect.DeepEqual(parts, tt.expected) {
+			t.Errorf("SplitString(%q) wrong. expected=%+v, got=%+v", tt.literal, tt.expected, parts)
+		}
+	}
+}
+
+func TestNewAt(t *testing.T) {
+	l := NewAt("x +\n y", token.Position{Filename: "main.mk", Offset: 20, Line: 3, Column: 7})
+
+	expected := []token.Position{
+		{Filename: "main.mk", Offset: 20, Line: 3, Column: 7},
+		{Filename: "main.mk", Offset: 22, Line: 3, Column: 9},
+		{Filename: "main.mk", Offset: 25, Line: 4, Column: 2},
+	}
+	for i, pos := range expected {
+		tok := l.NextToken()
+		if tok.Position != pos {
+			t.Errorf("tests[%d] - position wrong. expected=%+v, got=%+v", i, pos, tok.Position)
+		}
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		literal  string
+		expected string
+		offset   int    // of the EscapeError
+		err      string // empty if there's none
+	}{
+		{`hello`, "hello", 0, ""},
+		{`a\nb\tc\rd`, "a\nb\tc\rd", 0, ""},
+		{`say \"hi\" \\o/`, `say "hi" \o/`, 0, ""},
+		{`caf\u00e9 \u65e5`, "café 日", 0, ""},
+		{`\${x}`, "${x}", 0, ""},
+		{`ab\q`, "", 2, `invalid escape sequence \q`},
+		{`\u12`, "", 0, `truncated escape sequence \u12, want 4 hex digits`},
+		{`x\u12zz`, "", 1, `invalid escape sequence \u12zz, want 4 hex digits`},
+		{`a\`, "", 1, "unterminated escape sequence"},
+	}
+
+	for _, tt := range tests {
+		value, err := Unescape(tt.literal)
+		if tt.err == "" {
+			if err != nil || value != tt.expected {
+				t.Errorf("Unescape(%q) wrong. expected=%q, got=%q, %v", tt.literal, tt.expected, value, err)
+			}
+			continue
+		}
+		escErr, ok := err.(*EscapeError)
+		if !ok {
+			t.Errorf("Unescape(%q) error is not *EscapeError. got=%T (%v)", tt.literal, err, err)
+			continue
+		}
+		if escErr.Msg != tt.err || escErr.Offset != tt.offset {
+			t.Errorf("Unescape(%q) error wrong. expected=%d %q, got=%d %q", tt.literal, tt.offset, tt.err, escErr.Offset, escErr.Msg)
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	input := "a && b || c | & ||"
+
+	expected := []token.TokenType{token.IDENT, token.AND, token.IDENT, token.OR, token.IDENT, token.PIPE, token.ILLEGAL, token.OR, token.EOF}
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("tests[%d] - token-type wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+}
+
+func TestFloats(t *testing.T) {
+	input := "3.14 1..10 2."
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "3.14"},
+		{token.INT, "1"},
+		{token.DOTDOT, ".."},
+		{token.INT, "10"},
+		{token.INT, "2"},
+		{token.ILLEGAL, "."},
+		{token.EOF, ""},
+	}
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
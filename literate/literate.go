@@ -0,0 +1,98 @@
+// Package literate runs Markdown documents: every fenced code block tagged monkey is evaluated,
+// in order and against a single environment, and what it printed and evaluated to is written
+// in an output block right under it. Output blocks left by a previous run are replaced, so
+// running a document again updates it in place
+package literate
+
+import (
+	"bytes"
+	"io"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+)
+
+const (
+	fence       = "```"
+	codeFence   = "```monkey"
+	outputFence = "```output"
+)
+
+// Run evaluates the monkey blocks of the Markdown document src and returns the document with
+// their results. It redirects evaluator.Stdout while it runs, so it mustn't be called from
+// several goroutines
+func Run(src string) string {
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	lines := strings.SplitAfter(src, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, fence) {
+			out.WriteString(line)
+			continue
+		}
+
+		// copy the whole block, keeping track of its code if it's a monkey one
+		var code bytes.Buffer
+		out.WriteString(line)
+		for i++; i < len(lines) && strings.TrimSpace(lines[i]) != fence; i++ {
+			out.WriteString(lines[i])
+			code.WriteString(lines[i])
+		}
+		if i == len(lines) { // unterminated, leave it alone
+			break
+		}
+		out.WriteString(lines[i])
+		if trimmed != codeFence {
+			continue
+		}
+
+		// drop the output of the previous run
+		if i+1 < len(lines) && strings.TrimSpace(lines[i+1]) == outputFence {
+			end := i + 2
+			for end < len(lines) && strings.TrimSpace(lines[end]) != fence {
+				end++
+			}
+			if end < len(lines) {
+				i = end
+			}
+		}
+
+		if result := eval(code.String(), env); result != "" {
+			if !strings.HasSuffix(lines[i], "\n") {
+				out.WriteString("\n")
+			}
+			out.WriteString(outputFence + "\n" + result + fence + "\n")
+		}
+	}
+	return out.String()
+}
+
+// eval runs a single block and returns what it printed followed by its value. Null values
+// aren't shown, blocks ending in a let statement would always have one
+func eval(code string, env *object.Environment) string {
+	var out bytes.Buffer
+
+	p := parser.New(lexer.New(code))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			io.WriteString(&out, "parse error: "+msg+"\n")
+		}
+		return out.String()
+	}
+
+	stdout := evaluator.Stdout
+	evaluator.Stdout = &out
+	defer func() { evaluator.Stdout = stdout }()
+	result := evaluator.Eval(program, env)
+
+	if result != nil && result.Type() != object.NULL_OBJ {
+		io.WriteString(&out, result.Inspect()+"\n")
+	}
+	return out.String()
+}
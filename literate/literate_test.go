@@ -0,0 +1,74 @@
+package literate
+
+import "testing"
+
+func TestRun(t *testing.T) {
+	input := "# Tutorial\n" +
+		"\n" +
+		"```monkey\n" +
+		"let double = fn(x) { x * 2 };\n" +
+		"```\n" +
+		"Blocks share their bindings:\n" +
+		"```monkey\n" +
+		"puts(\"doubling\");\n" +
+		"double(21)\n" +
+		"```\n" +
+		"```go\n" +
+		"fmt.Println(1)\n" +
+		"```\n" +
+		"```monkey\n" +
+		"double(\"x\")\n" +
+		"```\n" +
+		"```monkey\n" +
+		"let = 1\n" +
+		"```"
+
+	expected := "# Tutorial\n" +
+		"\n" +
+		"```monkey\n" +
+		"let double = fn(x) { x * 2 };\n" +
+		"```\n" +
+		"Blocks share their bindings:\n" +
+		"```monkey\n" +
+		"puts(\"doubling\");\n" +
+		"double(21)\n" +
+		"```\n" +
+		"```output\n" +
+		"doubling\n" +
+		"42\n" +
+		"```\n" +
+		"```go\n" +
+		"fmt.Println(1)\n" +
+		"```\n" +
+		"```monkey\n" +
+		"double(\"x\")\n" +
+		"```\n" +
+		"```output\n" +
+		"ERROR: 1:24: type mismatch: STRING * INTEGER\n" +
+		"\tin double, called at 1:1\n" +
+		"```\n" +
+		"```monkey\n" +
+		"let = 1\n" +
+		"```\n" +
+		"```output\n" +
+		"parse error: expected next token to be IDENT, got = instead\n" +
+		"parse error: no prefix parse functions for = found\n" +
+		"```\n"
+
+	got := Run(input)
+	if got != expected {
+		t.Fatalf("wrong output. expected=\n%s\ngot=\n%s", expected, got)
+	}
+
+	// running it again replaces the output blocks instead of adding more
+	if again := Run(got); again != expected {
+		t.Errorf("second run changed the document. got=\n%s", again)
+	}
+}
+
+func TestRunUnterminatedBlock(t *testing.T) {
+	input := "```monkey\n1 + 1\n"
+	if got := Run(input); got != input {
+		t.Errorf("unterminated block was changed. got=%q", got)
+	}
+}
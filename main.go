@@ -1,13 +1,49 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io/ioutil"
+	"monkey/callgraph"
+	"monkey/crash"
+	"monkey/evaluator"
+	"monkey/examples"
+	"monkey/lexer"
+	"monkey/literate"
+	"monkey/object"
+	"monkey/parser"
 	"monkey/repl"
+	"monkey/token"
 	"os"
 	"os/user"
 )
 
 func main() {
+	// scripts run from the command line can do what the user running them can
+	evaluator.AllowExec = true
+	evaluator.AllowEnv = true
+	evaluator.AllowFiles = true
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "callgraph":
+			os.Exit(runCallgraph(os.Args[2:]))
+		case "examples":
+			os.Exit(runExamples(os.Args[2:]))
+		case "literate":
+			os.Exit(runLiterate(os.Args[2:]))
+		case "run":
+			os.Exit(runScript(os.Args[2:]))
+		case "test":
+			os.Exit(runTests(os.Args[2:]))
+		}
+	}
+
+	if dir, ok := crash.Enabled(); ok {
+		repl.CrashBundles = true
+		repl.CrashDir = dir
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -16,3 +52,173 @@ func main() {
 	fmt.Printf("Feel free to type in commands\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+// runCallgraph implements `monkey callgraph [-json] file`, printing the call graph of file as DOT or JSON
+func runCallgraph(args []string) int {
+	flags := flag.NewFlagSet("callgraph", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the graph as JSON instead of DOT")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey callgraph [-json] file")
+		return 2
+	}
+
+	src, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	f := token.NewFileSet().AddFile(flags.Arg(0), string(src))
+	p := parser.New(lexer.NewFile(f))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, err := range p.ParseErrors() {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+		return 1
+	}
+
+	g := callgraph.Build(program)
+	if !*asJSON {
+		fmt.Print(g.DOT())
+		return 0
+	}
+	data, err := g.JSON()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout.Write(data)
+	return 0
+}
+
+// runExamples implements `monkey examples [name]`. Without a name it lists the bundled examples,
+// with one it prints the example's source and runs it
+func runExamples(args []string) int {
+	if len(args) == 0 {
+		for _, e := range examples.All() {
+			fmt.Printf("%-14s %s\n", e.Name, e.Summary)
+		}
+		fmt.Println("\nrun one with: monkey examples <name>")
+		return 0
+	}
+
+	e := examples.Lookup(args[0])
+	if e == nil {
+		fmt.Fprintf(os.Stderr, "no example called %q, see monkey examples for the list\n", args[0])
+		return 1
+	}
+	fmt.Print(e.Source)
+	fmt.Println("\n--- running " + e.Name + " ---")
+	if err, ok := e.Run(os.Stdout).(*object.Error); ok {
+		fmt.Fprintln(os.Stderr, err.Inspect())
+		return 1
+	}
+	return 0
+}
+
+// runScript implements `monkey run file [args...]`, evaluating the script in file. The arguments
+// after it are what the script's args() returns
+func runScript(args []string) int {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey run file [args...]")
+		return 2
+	}
+
+	src, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	f := token.NewFileSet().AddFile(args[0], string(src))
+	p := parser.New(lexer.NewFile(f))
+	program := p.ParseProgram()
+	if len(p.ParseErrors()) != 0 {
+		for _, err := range p.ParseErrors() {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+		return 1
+	}
+
+	evaluator.Args = args[1:]
+	if err, ok := evaluator.Eval(program, object.NewEnvironment()).(*object.Error); ok {
+		fmt.Fprintln(os.Stderr, err.Inspect())
+		return 1
+	}
+	return 0
+}
+
+// runTests implements `monkey test file...`, running every file in test mode and reporting the
+// assertions that failed. A file that ends in an error counts as failed too
+func runTests(files []string) int {
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey test file...")
+		return 2
+	}
+
+	evaluator.TestMode = true
+	fset := token.NewFileSet()
+	status := 0
+	for _, name := range files {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		p := parser.New(lexer.NewFile(fset.AddFile(name, string(src))))
+		program := p.ParseProgram()
+		if len(p.ParseErrors()) != 0 {
+			for _, err := range p.ParseErrors() {
+				fmt.Fprintln(os.Stderr, err.Error())
+			}
+			status = 1
+			continue
+		}
+
+		evaluator.ResetFailures()
+		result := evaluator.Eval(program, object.NewEnvironment())
+		failures := evaluator.Failures()
+		if err, ok := result.(*object.Error); ok {
+			failures = append(failures, err)
+		}
+		for _, failure := range failures {
+			fmt.Println(failure.Inspect())
+		}
+		if len(failures) > 0 {
+			fmt.Printf("FAIL\t%s\n", name)
+			status = 1
+		} else {
+			fmt.Printf("ok\t%s\n", name)
+		}
+	}
+	return status
+}
+
+// runLiterate implements `monkey literate [-w] file.md`, running the monkey code blocks of a
+// Markdown file and printing it with their results. With -w the file is updated instead
+func runLiterate(args []string) int {
+	flags := flag.NewFlagSet("literate", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result back to the file instead of printing it")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey literate [-w] file.md")
+		return 2
+	}
+
+	src, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	result := literate.Run(string(src))
+	if !*write {
+		fmt.Print(result)
+		return 0
+	}
+	if err := ioutil.WriteFile(flags.Arg(0), []byte(result), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// ErrChannelClosed is returned by Channel.Send when the channel is closed
+var ErrChannelClosed = errors.New("send on closed channel")
+
+// A Channel passes values between goroutines, eg the functions started by spawn. Unlike a Go channel,
+// sending on a closed Channel or closing it twice isn't a panic, so it's safe to hand to programs
+type Channel struct {
+	mu     sync.Mutex // guards closing closed
+	values chan Object
+	closed chan struct{} // closed by Close
+}
+
+// NewChannel makes a channel that buffers up to size values. With size 0 every send waits for a receive
+func NewChannel(size int) *Channel {
+	return &Channel{values: make(chan Object, size), closed: make(chan struct{})}
+}
+
+func (c *Channel) Inspect() string {
+	if c.IsClosed() {
+		return "channel(closed)"
+	}
+	return "channel(open)"
+}
+
+// Close reports whether the channel was still open. Values sent before it can still be received
+func (c *Channel) Close() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.IsClosed() {
+		return false
+	}
+	close(c.closed)
+	return true
+}
+
+// IsClosed reports whether Close has been called
+func (c *Channel) IsClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Send blocks until value is taken by a receiver or fits in the buffer. It gives up with
+// ErrChannelClosed if the channel is closed, or with the context's error if ctx is done first
+func (c *Channel) Send(ctx context.Context, value Object) error {
+	if c.IsClosed() {
+		return ErrChannelClosed
+	}
+	select {
+	case c.values <- value:
+		return nil
+	case <-c.closed:
+		return ErrChannelClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Receive blocks until there's a value to take. Once the channel is closed and its buffer is empty
+// ok is false. It gives up with the context's error if ctx is done first
+func (c *Channel) Receive(ctx context.Context) (value Object, ok bool, err error) {
+	select {
+	case value := <-c.values:
+		return value, true, nil
+	case <-c.closed:
+		value, ok := c.drain()
+		return value, ok, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// drain takes a value left in the buffer of a closed channel, if there is one
+func (c *Channel) drain() (Object, bool) {
+	select {
+	case value := <-c.values:
+		return value, true
+	default:
+		return nil, false
+	}
+}
+
+// Iterate receives values until the channel is closed and empty, with the number of values
+// received before as keys. It may wait forever, IterateContext is for when it mustn't
+func (c *Channel) Iterate(fn func(key, value Object) bool) {
+	c.IterateContext(context.Background(), fn)
+}
+
+// IterateContext is Iterate that gives up with the context's error if ctx is done while it's
+// waiting for a value
+func (c *Channel) IterateContext(ctx context.Context, fn func(key, value Object) bool) error {
+	for i := int64(0); ; i++ {
+		value, ok, err := c.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok || !fn(&Integer{Value: i}, value) {
+			return nil
+		}
+	}
+}
+
+// Select blocks until one of the channels has a value to take, or is closed and empty, and
+// receives from it like Receive. If several are ready one is picked at random
+func Select(ctx context.Context, channels []*Channel) (index int, value Object, ok bool, err error) {
+	ops := make([]ChannelOp, len(channels))
+	for i, c := range channels {
+		ops[i] = ChannelOp{Channel: c}
+	}
+	return SelectOps(ctx, ops, true)
+}
+
+// ChannelOp is a receive from Channel or, if Send is set, a send of Value to it, for SelectOps
+type ChannelOp struct {
+	Channel *Channel
+	Send    bool
+	Value   Object
+}
+
+// SelectOps blocks until one of ops can go ahead, does it and returns its index. For a receive
+// value and ok are what Receive would return, a send on a closed channel fails with
+// ErrChannelClosed. If several are ready one is picked at random. Unless block is set, it returns
+// -1 right away if none is ready; either way it gives up with the context's error if ctx is done
+func SelectOps(ctx context.Context, ops []ChannelOp, block bool) (index int, value Object, ok bool, err error) {
+	for i, op := range ops {
+		if op.Send && op.Channel.IsClosed() {
+			return i, nil, false, ErrChannelClosed
+		}
+	}
+
+	// every op has two cases, one for its values and one for being closed, then comes ctx and, if
+	// it doesn't block, the default
+	cases := make([]reflect.SelectCase, 0, 2*len(ops)+2)
+	for _, op := range ops {
+		values := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(op.Channel.values)}
+		if op.Send {
+			values.Dir, values.Send = reflect.SelectSend, reflect.ValueOf(&op.Value).Elem()
+		}
+		cases = append(cases, values, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(op.Channel.closed)})
+	}
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+	if !block {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+
+	chosen, recv, _ := reflect.Select(cases)
+	switch {
+	case chosen == 2*len(ops):
+		return -1, nil, false, ctx.Err()
+	case chosen > 2*len(ops):
+		return -1, nil, false, nil
+	}
+	index = chosen / 2
+	switch {
+	case ops[index].Send && chosen%2 == 1:
+		return index, nil, false, ErrChannelClosed
+	case ops[index].Send:
+		return index, nil, false, nil
+	case chosen%2 == 1:
+		value, ok = ops[index].Channel.drain()
+		return index, value, ok, nil
+	}
+	return index, recv.Interface().(Object), true, nil
+}
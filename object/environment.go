@@ -1,8 +1,27 @@
 package object
 
+import (
+	"context"
+	"monkey/ast"
+	"sync"
+)
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.depth = outer.depth
+	env.ctx = outer.ctx
+	return env
+}
+
+// NewCallEnvironment is the environment of a function call nested depth calls deep. outer is the
+// environment the function was defined in, which may be at any depth or run under any context, so
+// ctx is the context of the caller, which interrupts the call along with the caller
+func NewCallEnvironment(outer *Environment, depth int, ctx context.Context) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.depth = depth
+	env.ctx = ctx
+	env.call = true
 	return env
 }
 
@@ -11,13 +30,86 @@ func NewEnvironment() *Environment {
 	return &Environment{store: s, outer: nil}
 }
 
+// Environments can be shared between goroutines (eg by spawn), so access to the store is guarded by mu
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	mu     sync.RWMutex
+	store  map[string]Object
+	consts map[string]bool // the names of store bound by const, nil until there's one
+	outer  *Environment
+	depth  int
+	ctx    context.Context // nil for the evaluator's default, see SetContext
+
+	call     bool       // it's the environment of a function call, see NewCallEnvironment
+	deferred []Deferred // the defer statements run in the call, in order
+}
+
+// Deferred is the expression of a defer statement, to be evaluated in Env when the function call
+// (or the program) the statement ran in ends
+type Deferred struct {
+	Expr ast.Expression
+	Env  *Environment
+}
+
+// frame is the environment of the function call e is in, or the top level one outside of any
+func (e *Environment) frame() *Environment {
+	for !e.call && e.outer != nil {
+		e = e.outer
+	}
+	return e
+}
+
+// Defer adds exp, to be evaluated in e, to the deferred expressions of the function call e is in
+func (e *Environment) Defer(exp ast.Expression) {
+	frame := e.frame()
+	frame.mu.Lock()
+	frame.deferred = append(frame.deferred, Deferred{Expr: exp, Env: e})
+	frame.mu.Unlock()
+}
+
+// TakeDeferred removes the deferred expressions of the function call e is in and returns them in
+// the order they were deferred
+func (e *Environment) TakeDeferred() []Deferred {
+	return e.TakeDeferredSince(0)
+}
+
+// NumDeferred is the number of deferred expressions of the function call e is in, so that
+// TakeDeferredSince can later take the ones deferred after now
+func (e *Environment) NumDeferred() int {
+	frame := e.frame()
+	frame.mu.Lock()
+	defer frame.mu.Unlock()
+	return len(frame.deferred)
+}
+
+// TakeDeferredSince is TakeDeferred for the deferred expressions after the first n, leaving those
+// in place. It's for code evaluated within a call, eg by eval, that runs its own deferred expressions
+func (e *Environment) TakeDeferredSince(n int) []Deferred {
+	frame := e.frame()
+	frame.mu.Lock()
+	defer frame.mu.Unlock()
+	if n >= len(frame.deferred) {
+		return nil
+	}
+	deferred := append([]Deferred(nil), frame.deferred[n:]...)
+	frame.deferred = frame.deferred[:n:n]
+	return deferred
 }
 
+// Depth is the number of function calls the environment is nested in, 0 at the top level
+func (e *Environment) Depth() int { return e.depth }
+
+// Context is what the code run in the environment is interrupted by, nil if it's up to the
+// evaluator. Environments enclosed by e and calls made in it have the same one
+func (e *Environment) Context() context.Context { return e.ctx }
+
+// SetContext sets the Context of e, eg to one that's cancelled along with a spawned function. It
+// must be called before e is used
+func (e *Environment) SetContext(ctx context.Context) { e.ctx = ctx }
+
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -25,6 +117,52 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
 	e.store[name] = val
+	delete(e.consts, name)
+	e.mu.Unlock()
 	return val
 }
+
+// SetConst binds name like Set, but as a constant: Assign can't change it anymore
+func (e *Environment) SetConst(name string, val Object) Object {
+	e.mu.Lock()
+	e.store[name] = val
+	if e.consts == nil {
+		e.consts = map[string]bool{}
+	}
+	e.consts[name] = true
+	e.mu.Unlock()
+	return val
+}
+
+// IsConst reports whether the innermost binding of name is a constant
+func (e *Environment) IsConst(name string) bool {
+	e.mu.RLock()
+	_, ok := e.store[name]
+	constant := e.consts[name]
+	e.mu.RUnlock()
+	if !ok && e.outer != nil {
+		return e.outer.IsConst(name)
+	}
+	return constant
+}
+
+// Assign changes the value of an existing binding, in the innermost scope that has one. It
+// reports false, and binds nothing, if name isn't bound anywhere or that binding is a constant
+func (e *Environment) Assign(name string, val Object) bool {
+	e.mu.Lock()
+	_, ok := e.store[name]
+	if ok && e.consts[name] {
+		e.mu.Unlock()
+		return false
+	}
+	if ok {
+		e.store[name] = val
+	}
+	e.mu.Unlock()
+	if !ok && e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return ok
+}
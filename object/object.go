@@ -2,25 +2,42 @@ package object
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"hash/fnv"
+	"math"
 	"monkey/ast"
+	"monkey/token"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
-	"hash/fnv"
+	"sync"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	TAIL_CALL_OBJ    = "TAIL_CALL"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
-	HASH_OBJ = "HASH"
+	HASH_OBJ         = "HASH"
+	FUTURE_OBJ       = "FUTURE"
+	QUOTE_OBJ        = "QUOTE"
+	REGEX_OBJ        = "REGEX"
+	BYTES_OBJ        = "BYTES"
+	RANGE_OBJ        = "RANGE"
+	CHANNEL_OBJ      = "CHANNEL"
 )
 
 type Object interface {
@@ -32,6 +49,10 @@ type Integer struct {
 	Value int64
 }
 
+type Float struct {
+	Value float64
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -42,14 +63,50 @@ type ReturnValue struct {
 	Value Object
 }
 
+// Break and Continue unwind the statements of a loop body up to the loop, the way ReturnValue
+// unwinds a function body. Pos is the position of the statement, for the error when there's no
+// loop to unwind to
+type Break struct {
+	Pos token.Position
+}
+
+type Continue struct {
+	Pos token.Position
+}
+
+// TailCall is a call in tail position of a function body that hasn't been made yet. The function
+// returns it instead of making the call, so the caller can make it in its place without the Go
+// stack growing. Pos is the position of the call
+type TailCall struct {
+	Function  *Function
+	Arguments []Object
+	Pos       token.Position
+}
+
+// Error is a runtime error. Pos is where it happened, it's invalid for errors that never made it
+// out of a builtin into a program. Stack holds the calls it unwound, innermost first
 type Error struct {
 	Message string
+	Pos     token.Position
+	Stack   []StackFrame
+	Value   Object // what a throw statement threw, nil for errors raised by the interpreter
+
+	// Interrupted is set for errors raised because the code was cancelled, which try doesn't
+	// catch, so that nothing can keep cancelled code running
+	Interrupted bool
+}
+
+// StackFrame is a call of a Function an Error went through
+type StackFrame struct {
+	Function string         // the name of the function, empty for anonymous ones
+	Pos      token.Position // of the call
 }
 
 type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Name       string // the name the function was first bound to by let, if any
 }
 
 type String struct {
@@ -58,31 +115,132 @@ type String struct {
 
 type Builtin struct {
 	Fn BuiltinFunction
+	// EnvFn is called instead of Fn if it's set, with the environment of the call, by builtins
+	// that work on the environment of their caller
+	EnvFn func(env *Environment, args ...Object) Object
 }
 
+// Array is a fixed number of elements. Index assignment changes them in place, possibly while
+// other goroutines read them, so code that might share an array uses Get, Set and Snapshot, which
+// hold mu. Elements itself is never replaced, so its length can be read without the lock
 type Array struct {
 	Elements []Object
+	Frozen   bool // the elements can't be changed, see Freeze
+	mu       sync.RWMutex
 }
 
 type BuiltinFunction func(args ...Object) Object
 
+// Bytes is binary data, a sequence of bytes that, unlike a String, needn't be text
+type Bytes struct {
+	Value  []byte
+	Frozen bool // the bytes can't be changed, see Freeze
+}
+
+// Range is the integers from Start up to, but not including, End, the value of `start..end`. It's
+// lazy: the integers are made one at a time as it's iterated over, never all at once
+type Range struct {
+	Start, End int64
+}
+
+// Len is the number of integers in the range, 0 if End isn't past Start. It's at most
+// math.MaxInt64, even for a range with more integers than that
+func (r *Range) Len() int64 {
+	if r.End <= r.Start {
+		return 0
+	}
+	if n := r.End - r.Start; n > 0 {
+		return n
+	}
+	return math.MaxInt64
+}
+
+// Contains reports whether n is one of the integers of the range
+func (r *Range) Contains(n int64) bool { return r.Start <= n && n < r.End }
+
+// Quote is the result of quote(...): the unevaluated tree of its argument, for macros to return.
+// ast.ObjectExpression goes the other way, putting an object back into a tree
+type Quote struct {
+	Node ast.Node
+}
+
+// Regex is a compiled regular expression, in the syntax of Go's regexp package
+type Regex struct {
+	Regexp *regexp.Regexp
+}
+
+// A Future holds the result of a computation running on another goroutine.
+// It is resolved exactly once, either with a result or by being cancelled
+type Future struct {
+	mu        sync.Mutex
+	done      chan struct{}
+	result    Object
+	cancelled bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewFuture makes a pending future whose Context is cancelled along with parent
+func NewFuture(parent context.Context) *Future {
+	ctx, cancel := context.WithCancel(parent)
+	return &Future{done: make(chan struct{}), ctx: ctx, cancel: cancel}
+}
+
 // This interface can be used in our evaluator to check if the given object is usable as a hash key when we evaluate has literals or index expression for hashes
 type Hashable interface {
 	HashKey() HashKey
 }
- 
+
 type HashKey struct {
-	Type ObjectType // Type field effectively 'scopes' HashKeys to different object types
-	Value uint64 // Holds an integer, and thus we can easily compare a HashKey to another HashKey
+	Type  ObjectType // Type field effectively 'scopes' HashKeys to different object types
+	Value uint64     // Holds an integer, and thus we can easily compare a HashKey to another HashKey
 }
 
 type HashPair struct {
-	Key Object
+	Key   Object
 	Value Object
 }
 
+// Hash maps keys to values. Like an Array it can be changed in place while it's shared, so Pairs
+// is only read or written directly while the hash is being made, and through the methods, which
+// hold mu, after that
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs  map[HashKey]HashPair
+	Frozen bool      // no key can be added, changed or removed, see Freeze
+	order  []HashKey // the keys in the order they were added by Set
+	mu     sync.RWMutex
+}
+
+// Iterable is implemented by the objects a for-in loop can iterate over
+type Iterable interface {
+	// Iterate calls fn with the key and the value of each element in order, until fn returns false
+	Iterate(fn func(key, value Object) bool)
+}
+
+// Freeze makes obj immutable if it's an array, hash or bytes, along with every array and hash
+// inside it. It's what const does to the values it binds
+func Freeze(obj Object) {
+	switch obj := obj.(type) {
+	case *Array:
+		if obj.Frozen {
+			return
+		}
+		obj.Frozen = true
+		for _, el := range obj.Snapshot() {
+			Freeze(el)
+		}
+	case *Hash:
+		if obj.Frozen {
+			return
+		}
+		obj.Frozen = true
+		for _, pair := range obj.OrderedPairs() {
+			Freeze(pair.Key)
+			Freeze(pair.Value)
+		}
+	case *Bytes:
+		obj.Frozen = true
+	}
 }
 
 func (b *Boolean) HashKey() HashKey {
@@ -99,6 +257,10 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (f *Float) HashKey() HashKey {
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -106,21 +268,71 @@ func (s *String) HashKey() HashKey {
 }
 
 func (i *Integer) Type() ObjectType      { return INTEGER_OBJ }
+func (f *Float) Type() ObjectType        { return FLOAT_OBJ }
 func (b *Boolean) Type() ObjectType      { return BOOLEAN_OBJ }
 func (n *Null) Type() ObjectType         { return NULL_OBJ }
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (b *Break) Type() ObjectType        { return BREAK_OBJ }
+func (c *Continue) Type() ObjectType     { return CONTINUE_OBJ }
+func (tc *TailCall) Type() ObjectType    { return TAIL_CALL_OBJ }
 func (e *Error) Type() ObjectType        { return ERROR_OBJ }
 func (f *Function) Type() ObjectType     { return FUNCTION_OBJ }
 func (s *String) Type() ObjectType       { return STRING_OBJ }
 func (b *Builtin) Type() ObjectType      { return BUILTIN_OBJ }
 func (ao *Array) Type() ObjectType       { return ARRAY_OBJ }
-func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Type() ObjectType         { return HASH_OBJ }
+func (f *Future) Type() ObjectType       { return FUTURE_OBJ }
+func (q *Quote) Type() ObjectType        { return QUOTE_OBJ }
+func (r *Regex) Type() ObjectType        { return REGEX_OBJ }
+func (b *Bytes) Type() ObjectType        { return BYTES_OBJ }
+func (r *Range) Type() ObjectType        { return RANGE_OBJ }
+func (c *Channel) Type() ObjectType      { return CHANNEL_OBJ }
 
-func (i *Integer) Inspect() string      { return fmt.Sprintf("%d", i.Value) }
+func (i *Integer) Inspect() string { return fmt.Sprintf("%d", i.Value) }
+
+// Inspect always shows a whole float with a fractional part, 2.0 rather than 2, so it reads back
+// as a float and can't be mistaken for an Integer
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") { // not 1.5, 1e+21, +Inf or NaN
+		s += ".0"
+	}
+	return s
+}
 func (b *Boolean) Inspect() string      { return fmt.Sprintf("%t", b.Value) }
 func (n *Null) Inspect() string         { return "null" }
 func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
-func (e *Error) Inspect() string        { return "ERROR: " + e.Message }
+func (b *Break) Inspect() string        { return "break" }
+func (c *Continue) Inspect() string     { return "continue" }
+func (tc *TailCall) Inspect() string    { return "tail call" }
+
+// stackEdge is how many of the innermost and of the outermost calls Error.Inspect shows of a long stack
+const stackEdge = 10
+
+// Inspect gives the message prefixed with the position and followed by the stack, one call per line.
+// The middle of a stack deeper than 2*stackEdge calls, eg from runaway recursion, is left out
+func (e *Error) Inspect() string {
+	var out bytes.Buffer
+	out.WriteString("ERROR: ")
+	if e.Pos.IsValid() {
+		out.WriteString(e.Pos.String() + ": ")
+	}
+	out.WriteString(e.Message)
+	for i, frame := range e.Stack {
+		if len(e.Stack) > 2*stackEdge && i >= stackEdge && i < len(e.Stack)-stackEdge {
+			if i == stackEdge {
+				fmt.Fprintf(&out, "\n\t... %d more calls", len(e.Stack)-2*stackEdge)
+			}
+			continue
+		}
+		name := frame.Function
+		if name == "" {
+			name = "anonymous function"
+		}
+		out.WriteString("\n\tin " + name + ", called at " + frame.Pos.String())
+	}
+	return out.String()
+}
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 	params := []string{}
@@ -130,32 +342,308 @@ func (f *Function) Inspect() string {
 	out.WriteString("fn")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") {\n")
+	out.WriteString(") ")
 	out.WriteString(f.Body.String())
-	out.WriteString("\n}")
 	return out.String()
 }
 func (s *String) Inspect() string  { return s.Value }
 func (b *Builtin) Inspect() string { return "builtin function" }
-func (ao *Array) Inspect() string {
+func (ao *Array) Inspect() string  { return inspect(ao, map[Object]bool{}) }
+func (h *Hash) Inspect() string    { return inspect(h, map[Object]bool{}) }
+
+// inspect is Inspect for obj inside the arrays and hashes in seen. An array or hash that contains
+// itself is shown as [...] or {...} where it comes up again, rather than over and over forever
+func inspect(obj Object, seen map[Object]bool) string {
 	var out bytes.Buffer
-	elements := []string{}
-	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+	switch obj := obj.(type) {
+	case *Array:
+		if seen[obj] {
+			return "[...]"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+		elements := []string{}
+		for _, e := range obj.Snapshot() {
+			elements = append(elements, inspect(e, seen))
+		}
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ", "))
+		out.WriteString("]")
+	case *Hash:
+		if seen[obj] {
+			return "{...}"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+		pairs := []string{}
+		for _, pair := range obj.OrderedPairs() {
+			pairs = append(pairs, fmt.Sprintf("%s: %s", inspect(pair.Key, seen), inspect(pair.Value, seen)))
+		}
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+	default:
+		return obj.Inspect()
 	}
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
 	return out.String()
 }
-func (h *Hash) Inspect() string {
-	var out bytes.Buffer
-	pairs := []string{}
+func (q *Quote) Inspect() string { return "QUOTE(" + q.Node.String() + ")" }
+func (r *Regex) Inspect() string { return "/" + r.Regexp.String() + "/" }
+
+// Inspect shows the bytes as a quoted string prefixed with b, with Go escapes for those that
+// aren't printable ASCII
+func (b *Bytes) Inspect() string { return "b" + strconv.QuoteToASCII(string(b.Value)) }
+func (r *Range) Inspect() string { return fmt.Sprintf("%d..%d", r.Start, r.End) }
+func (f *Future) Inspect() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case f.cancelled:
+		return "future(cancelled)"
+	case f.IsDone():
+		return "future(done)"
+	default:
+		return "future(pending)"
+	}
+}
+
+// Context is cancelled once Cancel is called, or when the parent context of the future is
+func (f *Future) Context() context.Context { return f.ctx }
+
+// Done is closed once the future is resolved or cancelled
+func (f *Future) Done() <-chan struct{} { return f.done }
+
+// Resolve stores the result and wakes up everyone waiting on the future. It's a no-op if
+// the future was already resolved or cancelled
+func (f *Future) Resolve(result Object) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.IsDone() {
+		return
+	}
+	f.result = result
+	close(f.done)
+}
+
+// Cancel reports whether the future was still pending, in which case it's now cancelled
+func (f *Future) Cancel() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.IsDone() {
+		return false
+	}
+	f.cancelled = true
+	f.cancel()
+	close(f.done)
+	return true
+}
+
+// IsDone reports whether the future has been resolved or cancelled
+func (f *Future) IsDone() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait blocks until the future is resolved or cancelled. For a cancelled future ok is false
+func (f *Future) Wait() (result Object, ok bool) {
+	<-f.done
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.result, !f.cancelled
+}
+
+// Get returns the pair of key, if the hash has one
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	pair, ok := h.Pairs[key]
+	return pair, ok
+}
+
+// Len is the number of pairs in the hash
+func (h *Hash) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.Pairs)
+}
+
+// Set adds the pair to the hash, or changes the value of its key if the hash has it already. The
+// key must be Hashable
+func (h *Hash) Set(pair HashPair) {
+	key := pair.Key.(Hashable).HashKey()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.Pairs == nil {
+		h.Pairs = map[HashKey]HashPair{}
+	}
+	if _, ok := h.Pairs[key]; !ok {
+		h.order = append(h.order, key)
+	}
+	h.Pairs[key] = pair
+}
+
+// Delete removes the pair of key from the hash, reporting whether it had one
+func (h *Hash) Delete(key HashKey) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.Pairs[key]; !ok {
+		return false
+	}
+	delete(h.Pairs, key)
+	for i, k := range h.order {
+		if k == key {
+			h.order = append(h.order[:i:i], h.order[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// OrderedPairs returns the pairs of the hash in the order their keys were added by Set. Pairs put
+// in Pairs directly, without Set, come last in the order of SortedPairs
+func (h *Hash) OrderedPairs() []HashPair {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	seen := make(map[HashKey]bool, len(h.order))
+	for _, key := range h.order {
+		if pair, ok := h.Pairs[key]; ok && !seen[key] {
+			pairs = append(pairs, pair)
+			seen[key] = true
+		}
+	}
+	if len(pairs) < len(h.Pairs) {
+		for _, pair := range h.sortedPairs() {
+			if !seen[pair.Key.(Hashable).HashKey()] {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	return pairs
+}
+
+// Get returns the element at the offset i, which must be in range
+func (ao *Array) Get(i int) Object {
+	ao.mu.RLock()
+	defer ao.mu.RUnlock()
+	return ao.Elements[i]
+}
+
+// Set changes the element at the offset i, which must be in range
+func (ao *Array) Set(i int, value Object) {
+	ao.mu.Lock()
+	defer ao.mu.Unlock()
+	ao.Elements[i] = value
+}
+
+// Snapshot returns a copy of the elements as they are now
+func (ao *Array) Snapshot() []Object {
+	ao.mu.RLock()
+	defer ao.mu.RUnlock()
+	return append([]Object(nil), ao.Elements...)
+}
+
+// Iterate goes over the elements of the array, with their indexes as keys. Elements changed while
+// it's iterating are seen with their new values if it hasn't got to them yet
+func (ao *Array) Iterate(fn func(key, value Object) bool) {
+	for i := 0; i < len(ao.Elements); i++ {
+		if !fn(&Integer{Value: int64(i)}, ao.Get(i)) {
+			return
+		}
+	}
+}
+
+// Iterate goes over the pairs of the hash in the order of OrderedPairs, as they were when it started
+func (h *Hash) Iterate(fn func(key, value Object) bool) {
+	for _, pair := range h.OrderedPairs() {
+		if !fn(pair.Key, pair.Value) {
+			return
+		}
+	}
+}
+
+// Iterate goes over the characters of the string, each a string of its own, with their indexes as
+// keys. Like indexing, it counts characters rather than bytes
+func (s *String) Iterate(fn func(key, value Object) bool) {
+	i := 0
+	for _, r := range s.Value {
+		if !fn(&Integer{Value: int64(i)}, &String{Value: string(r)}) {
+			return
+		}
+		i++
+	}
+}
+
+// Iterate goes over the bytes as integers, with their indexes as keys
+func (b *Bytes) Iterate(fn func(key, value Object) bool) {
+	for i := 0; i < len(b.Value); i++ {
+		if !fn(&Integer{Value: int64(i)}, &Integer{Value: int64(b.Value[i])}) {
+			return
+		}
+	}
+}
+
+// Iterate goes over the integers of the range, with their indexes as keys
+func (r *Range) Iterate(fn func(key, value Object) bool) {
+	for i := int64(0); i < r.Len(); i++ {
+		if !fn(&Integer{Value: i}, &Integer{Value: r.Start + i}) {
+			return
+		}
+	}
+}
+
+// SortedPairs returns the pairs of the hash ordered by their keys, so enumerating a hash always gives
+// the same order: booleans first, then numbers, then strings, each in ascending order
+func (h *Hash) SortedPairs() []HashPair {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.sortedPairs()
+}
+
+// sortedPairs is SortedPairs for callers that hold mu already
+func (h *Hash) sortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
 	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+		pairs = append(pairs, pair)
 	}
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", ")) 
-	out.WriteString("}")
-	return out.String()
-}
\ No newline at end of file
+	sort.Slice(pairs, func(i, j int) bool { return keyLess(pairs[i].Key, pairs[j].Key) })
+	return pairs
+}
+
+func keyLess(a, b Object) bool {
+	if ra, rb := keyRank(a), keyRank(b); ra != rb {
+		return ra < rb
+	}
+	switch a := a.(type) {
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			return a.Value < b.Value
+		}
+	}
+	return keyNumber(a) < keyNumber(b)
+}
+
+func keyRank(key Object) int {
+	switch key.(type) {
+	case *Boolean:
+		return 0
+	case *Integer, *Float:
+		return 1
+	}
+	return 2
+}
+
+func keyNumber(key Object) float64 {
+	if i, ok := key.(*Integer); ok {
+		return float64(i.Value)
+	}
+	return key.(*Float).Value
+}
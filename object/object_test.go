@@ -1,5 +1,10 @@
 package object
-import "testing"
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -55,4 +60,100 @@ func TestIntegerHashKey(t *testing.T) {
 	if one1.HashKey() == two1.HashKey() {
 		t.Errorf("integers with different content have same hash keys")
 	}
-}
\ No newline at end of file
+}
+
+func TestFutureResolve(t *testing.T) {
+	future := NewFuture(context.Background())
+	if future.IsDone() {
+		t.Fatalf("new future is already done")
+	}
+	future.Resolve(&Integer{Value: 1})
+	future.Resolve(&Integer{Value: 2})
+
+	result, ok := future.Wait()
+	if !ok {
+		t.Fatalf("resolved future reports cancellation")
+	}
+	if result.(*Integer).Value != 1 {
+		t.Errorf("future resolved twice. got=%d", result.(*Integer).Value)
+	}
+	if future.Cancel() {
+		t.Errorf("resolved future could be cancelled")
+	}
+	if future.Inspect() != "future(done)" {
+		t.Errorf("future.Inspect() wrong. got=%q", future.Inspect())
+	}
+}
+
+func TestChannelCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := NewChannel(0)
+	if _, _, err := c.Receive(ctx); err != context.Canceled {
+		t.Errorf("receive on a cancelled context wrong error. got=%v", err)
+	}
+	if err := c.Send(ctx, &Integer{Value: 1}); err != context.Canceled {
+		t.Errorf("send on a cancelled context wrong error. got=%v", err)
+	}
+	if index, _, _, err := Select(ctx, []*Channel{c}); err != context.Canceled || index != -1 {
+		t.Errorf("select on a cancelled context wrong result. got=%d, %v", index, err)
+	}
+	if err := c.IterateContext(ctx, func(key, value Object) bool { return true }); err != context.Canceled {
+		t.Errorf("iterate on a cancelled context wrong error. got=%v", err)
+	}
+
+	if !c.Close() || c.Close() {
+		t.Errorf("channel should close exactly once")
+	}
+	if err := c.Send(context.Background(), &Integer{Value: 1}); err != ErrChannelClosed {
+		t.Errorf("send on a closed channel wrong error. got=%v", err)
+	}
+}
+
+func TestFutureCancel(t *testing.T) {
+	future := NewFuture(context.Background())
+	if !future.Cancel() {
+		t.Fatalf("pending future could not be cancelled")
+	}
+	future.Resolve(&Integer{Value: 1})
+
+	if _, ok := future.Wait(); ok {
+		t.Errorf("cancelled future reports a result")
+	}
+	if future.Context().Err() == nil {
+		t.Errorf("context of cancelled future not cancelled")
+	}
+	if future.Inspect() != "future(cancelled)" {
+		t.Errorf("future.Inspect() wrong. got=%q", future.Inspect())
+	}
+
+	parent, cancel := context.WithCancel(context.Background())
+	future = NewFuture(parent)
+	cancel()
+	if future.Context().Err() == nil {
+		t.Errorf("context of future not cancelled with its parent")
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	h := &Hash{}
+	for _, k := range []string{"c", "a", "b"} {
+		h.Set(HashPair{Key: &String{Value: k}, Value: &Integer{Value: 1}})
+	}
+	h.Set(HashPair{Key: &String{Value: "c"}, Value: &Integer{Value: 2}})
+	if !h.Delete((&String{Value: "a"}).HashKey()) || h.Delete((&String{Value: "x"}).HashKey()) {
+		t.Errorf("Delete reported the wrong result")
+	}
+	d := &String{Value: "d"}
+	h.Pairs[d.HashKey()] = HashPair{Key: d, Value: &Integer{Value: 3}}
+
+	var got []string
+	h.Iterate(func(key, value Object) bool {
+		got = append(got, key.Inspect()+"="+value.Inspect())
+		return true
+	})
+	if strings.Join(got, " ") != "c=2 b=1 d=3" {
+		t.Errorf("hash iterated in the wrong order. got=%q", got)
+	}
+}
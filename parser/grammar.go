@@ -0,0 +1,241 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// The grammar below mirrors what the parser accepts, in the format tree-sitter reads from
+// grammar.json. It's maintained by hand next to the parsing functions: whenever the parser
+// learns new syntax, the matching rule has to be added here too, otherwise the conformance
+// test in grammar_test.go fails. Precedences are the parser's own precedence constants.
+
+// Rule is a single node of a tree-sitter grammar: SEQ, CHOICE, REPEAT, REPEAT1, BLANK,
+// STRING, PATTERN, SYMBOL or one of the PREC variants
+type Rule struct {
+	Type    string  `json:"type"`
+	Name    string  `json:"name,omitempty"`    // SYMBOL
+	Value   string  `json:"value,omitempty"`   // STRING and PATTERN
+	Prec    int     `json:"-"`                 // PREC, PREC_LEFT and PREC_RIGHT
+	Members []*Rule `json:"members,omitempty"` // SEQ and CHOICE
+	Content *Rule   `json:"content,omitempty"` // REPEAT, REPEAT1 and the PREC variants
+}
+
+// NamedRule is a named entry of Grammar.Rules
+type NamedRule struct {
+	Name string
+	Rule *Rule
+}
+
+// Grammar is a complete tree-sitter grammar. The first rule is the start rule
+type Grammar struct {
+	Name   string
+	Word   string // the rule keywords are extracted from
+	Extras []*Rule
+	Rules  []NamedRule
+}
+
+// MonkeyGrammar returns the grammar of the language accepted by Parser
+func MonkeyGrammar() *Grammar {
+	return &Grammar{
+		Name:   "monkey",
+		Word:   "identifier",
+		Extras: []*Rule{pattern(`\s`), sym("comment")},
+		Rules: []NamedRule{
+			{"source_file", repeat(sym("_statement"))},
+
+			//// Statements ////
+			{"_statement", choice(
+				sym("let_statement"),
+				sym("return_statement"),
+				sym("throw_statement"),
+				sym("defer_statement"),
+				sym("while_statement"),
+				sym("for_in_statement"),
+				sym("break_statement"),
+				sym("continue_statement"),
+				sym("expression_statement"),
+			)},
+			{"let_statement", seq(choice(str("let"), str("const")), sym("identifier"), str("="), sym("_expression"), optional(str(";")))},
+			{"return_statement", seq(str("return"), sym("_expression"), optional(str(";")))},
+			{"throw_statement", seq(str("throw"), sym("_expression"), optional(str(";")))},
+			{"defer_statement", seq(str("defer"), sym("_expression"), optional(str(";")))},
+			{"while_statement", seq(str("while"), str("("), sym("_expression"), str(")"), sym("block"), optional(str(";")))},
+			{"for_in_statement", seq(str("for"), str("("), sym("identifier"), optional(seq(str(","), sym("identifier"))), str("in"), sym("_expression"), str(")"), sym("block"), optional(str(";")))},
+			{"break_statement", seq(str("break"), optional(str(";")))},
+			{"continue_statement", seq(str("continue"), optional(str(";")))},
+			{"expression_statement", seq(sym("_expression"), optional(str(";")))},
+			{"block", seq(str("{"), repeat(sym("_statement")), str("}"))},
+
+			//// Expressions ////
+			{"_expression", choice(
+				sym("identifier"),
+				sym("integer"),
+				sym("float"),
+				sym("string"),
+				sym("boolean"),
+				sym("null"),
+				sym("prefix_expression"),
+				sym("binary_expression"),
+				sym("range_expression"),
+				sym("assignment"),
+				sym("parenthesized_expression"),
+				sym("if_expression"),
+				sym("function_literal"),
+				sym("call_expression"),
+				sym("array"),
+				sym("hash"),
+				sym("index_expression"),
+				sym("slice_expression"),
+				sym("match_expression"),
+				sym("try_expression"),
+				sym("select_expression"),
+				sym("macro_literal"),
+				sym("lambda"),
+			)},
+			{"comment", pattern(`//[^\n]*`)},
+			{"identifier", pattern(`[a-zA-Z_]+`)},
+			{"integer", pattern(`[0-9]+`)},
+			{"float", pattern(`[0-9]+\.[0-9]+`)},
+			{"string", pattern(`"([^"\\]|\\.)*"`)},
+			{"boolean", choice(str("true"), str("false"))},
+			{"null", str("null")},
+			{"prefix_expression", prec(PREFIX, seq(choice(str("!"), str("-")), sym("_expression")))},
+			{"binary_expression", choice(
+				binary(OR, "||"),
+				binary(AND, "&&"),
+				binary(EQUALS, "=="),
+				binary(EQUALS, "!="),
+				binary(LESSGREATER, "<"),
+				binary(LESSGREATER, ">"),
+				binary(SUM, "+"),
+				binary(SUM, "-"),
+				binary(PRODUCT, "*"),
+				binary(PRODUCT, "/"),
+				binary(PRODUCT, "%"),
+			)},
+			{"range_expression", binary(RANGE, "..")},
+			{"assignment", precRight(ASSIGN, seq(
+				choice(sym("identifier"), sym("index_expression")), str("="), sym("_expression"),
+			))},
+			{"parenthesized_expression", seq(str("("), sym("_expression"), str(")"))},
+			{"if_expression", seq(
+				str("if"), str("("), sym("_expression"), str(")"), sym("block"),
+				optional(seq(str("else"), sym("block"))),
+			)},
+			{"function_literal", seq(str("fn"), sym("parameters"), sym("block"))},
+			{"lambda", prec(LOWEST, seq(
+				choice(seq(str("|"), commaSep(sym("identifier")), str("|")), str("||")),
+				sym("_expression"),
+			))},
+			{"macro_literal", seq(str("macro"), sym("parameters"), sym("block"))},
+			{"parameters", seq(str("("), commaSep(sym("identifier")), str(")"))},
+			{"call_expression", prec(CALL, seq(sym("_expression"), sym("arguments")))},
+			{"arguments", seq(str("("), optional(seq(
+				choice(
+					seq(sym("_expression"), repeat(seq(str(","), sym("_expression"))), repeat(seq(str(","), sym("named_argument")))),
+					seq(sym("named_argument"), repeat(seq(str(","), sym("named_argument")))),
+				),
+				optional(str(",")),
+			)), str(")"))},
+			{"named_argument", seq(sym("identifier"), str(":"), sym("_expression"))},
+			{"array", seq(str("["), commaSep(sym("_expression")), str("]"))},
+			{"hash", seq(str("{"), commaSep(sym("pair")), str("}"))},
+			{"pair", seq(sym("_expression"), str(":"), sym("_expression"))},
+			{"index_expression", prec(INDEX, seq(sym("_expression"), str("["), sym("_expression"), str("]")))},
+			{"slice_expression", prec(INDEX, seq(
+				sym("_expression"), str("["), optional(sym("_expression")), str(":"), optional(sym("_expression")), str("]"),
+			))},
+			{"match_expression", seq(
+				str("match"), str("("), sym("_expression"), str(")"),
+				str("{"), commaSep(sym("match_arm")), str("}"),
+			)},
+			{"match_arm", seq(sym("_expression"), str("=>"), sym("_expression"))},
+			{"try_expression", seq(
+				str("try"), sym("block"),
+				str("catch"), str("("), sym("identifier"), str(")"), sym("block"),
+			)},
+			// select, case, default, recv, receive and send are identifiers everywhere else, so
+			// they're patterns here: a string would make them keywords
+			{"select_expression", seq(pattern(`select`), str("{"), repeat(sym("select_case")), str("}"))},
+			{"select_case", seq(
+				choice(
+					seq(pattern(`case`), optional(seq(sym("identifier"), str("="))), pattern(`recv|receive`), str("("), sym("_expression"), str(")")),
+					seq(pattern(`case`), pattern(`send`), str("("), sym("_expression"), str(","), sym("_expression"), str(")")),
+					pattern(`default`),
+				),
+				str(":"), repeat(sym("_statement")),
+			)},
+		},
+	}
+}
+
+// MarshalJSON writes the grammar as a grammar.json document. The rules are written in order,
+// as tree-sitter takes the first one to be the start rule
+func (g *Grammar) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString(`{"name":`)
+	name, _ := json.Marshal(g.Name)
+	out.Write(name)
+	out.WriteString(`,"word":`)
+	word, _ := json.Marshal(g.Word)
+	out.Write(word)
+	out.WriteString(`,"extras":`)
+	extras, err := json.Marshal(g.Extras)
+	if err != nil {
+		return nil, err
+	}
+	out.Write(extras)
+	out.WriteString(`,"rules":{`)
+	for i, nr := range g.Rules {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		key, _ := json.Marshal(nr.Name)
+		out.Write(key)
+		out.WriteString(":")
+		rule, err := json.Marshal(nr.Rule)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(rule)
+	}
+	out.WriteString("}}")
+	return out.Bytes(), nil
+}
+
+// MarshalJSON adds the numeric "value" of the PREC variants, which can't share the string Value field
+func (r *Rule) MarshalJSON() ([]byte, error) {
+	type plain Rule // same fields, no MarshalJSON method
+	if r.Type != "PREC" && r.Type != "PREC_LEFT" && r.Type != "PREC_RIGHT" {
+		return json.Marshal((*plain)(r))
+	}
+	return json.Marshal(struct {
+		Type    string `json:"type"`
+		Value   int    `json:"value"`
+		Content *Rule  `json:"content"`
+	}{r.Type, r.Prec, r.Content})
+}
+
+//// Rule constructors, named after their grammar.js counterparts ////
+
+func seq(members ...*Rule) *Rule     { return &Rule{Type: "SEQ", Members: members} }
+func choice(members ...*Rule) *Rule  { return &Rule{Type: "CHOICE", Members: members} }
+func repeat(r *Rule) *Rule           { return &Rule{Type: "REPEAT", Content: r} }
+func optional(r *Rule) *Rule         { return choice(r, &Rule{Type: "BLANK"}) }
+func str(value string) *Rule         { return &Rule{Type: "STRING", Value: value} }
+func pattern(value string) *Rule     { return &Rule{Type: "PATTERN", Value: value} }
+func sym(name string) *Rule          { return &Rule{Type: "SYMBOL", Name: name} }
+func prec(p int, r *Rule) *Rule      { return &Rule{Type: "PREC", Prec: p, Content: r} }
+func precLeft(p int, r *Rule) *Rule  { return &Rule{Type: "PREC_LEFT", Prec: p, Content: r} }
+func precRight(p int, r *Rule) *Rule { return &Rule{Type: "PREC_RIGHT", Prec: p, Content: r} }
+
+// binary is a left associative infix operator at the given parser precedence
+func binary(p int, operator string) *Rule {
+	return precLeft(p, seq(sym("_expression"), str(operator), sym("_expression")))
+}
+
+// commaSep is an optional comma separated list of r, with an optional trailing comma
+func commaSep(r *Rule) *Rule {
+	return optional(seq(r, repeat(seq(str(","), r)), optional(str(","))))
+}
@@ -0,0 +1,315 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"monkey/lexer"
+	"monkey/token"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// grammarCorpus is checked against both the parser and MonkeyGrammar(). Every entry must be
+// accepted (or rejected) by both of them
+var grammarCorpus = []struct {
+	input string
+	valid bool
+}{
+	{"let x = 5;", true},
+	{"let x = 5 let y = x", true},
+	{"const PI = 3; const E = 2", true},
+	{"return add(1, 2);", true},
+	{"throw err;", true},
+	{"for (x in xs) { puts(x) } for (k, v in {\"a\": 1}) { break; };", true},
+	{"defer close(f); defer x", true},
+	{"-a * b + !c / d - e", true},
+	{"a == b != c < d > e", true},
+	{"(1 + 2) * 3", true},
+	{"1..n + 1", true},
+	{"if (x < y) { x } else { y }", true},
+	{"if (x) { let y = 1; y; }", true},
+	{"let add = fn(x, y,) { return x + y; };", true},
+	{"fn() {}()", true},
+	{"add(1, b: 2, c: 3,)", true},
+	{"makeServer(port: 8080, host: \"x\")", true},
+	{`[1, "two", [3],]`, true},
+	{`{"one": 1, true: fn(x) { x }, 2: [],}`, true},
+	{"{}[0]", true},
+	{"arr[1:3]; arr[:3]; arr[2:]; arr[:];", true},
+	{`match (x) { 1 => "one", _ => "other", }`, true},
+	{"try { throw 1; } catch (e) { e }", true},
+	{"select { case v = recv(c): puts(v); v case receive(d): 1 case send(e, 2): default: }", true},
+	{"let x = select { }; select([c, d])", true},
+	{"a[0](1)[fn(x) { x }]", true},
+	{"let x = 1; // one\n// done", true},
+	{"if (x == null) { return null; }", true},
+	{"x != null && x[0] == 1 || !y; || 1", true},
+	{"x = y = 1; arr[0] = 2; h[\"a\"][i] = x || y", true},
+	{"while (x < 10) { if (x == 5) { break; } continue } while (true) { };", true},
+	{"map(arr, |x| x * 2); reduce(arr, 0, |acc, x,| acc + x); | | 1", true},
+	{"let unless = macro(cond, a, b) { quote(if (!(unquote(cond))) { unquote(a); } else { unquote(b); }); };", true},
+
+	{"let = 5;", false},
+	{"for (x, in xs) {}", false},
+	{"for x in xs {}", false},
+	{"let x 5;", false},
+	{"const PI;", false},
+	{"const = 3;", false},
+	{"return;", false},
+	{"5 +", false},
+	{"(1 + 2", false},
+	{"[1,,]", false},
+	{"add(,)", false},
+	{"f(a: 1, 2)", false},
+	{"fn(1) { 1 }", false},
+	{"fn(x) x", false},
+	{"if x { 1 }", false},
+	{`{"a" 1}`, false},
+	{"arr[1:2:3]", false},
+	{"match x { 1 => 2 }", false},
+	{"match (x) { 1 }", false},
+	{"try { 1 }", false},
+	{"try { 1 } catch { 2 }", false},
+	{"select { case c: 1 }", false},
+	{"select { case v = send(c, 1): 1 }", false},
+	{"select { case recv(c) 1 }", false},
+	{"select { 1 }", false},
+	{"1 = 2", false},
+	{"x.y", false},
+	{"1 / / 2", false},
+	{"macro { x }", false},
+	{"|x y| x", false},
+	{"let null = 1;", false},
+	{"|x|", false},
+	{"|1| x", false},
+	{"macro(x) x", false},
+}
+
+func TestGrammarConformance(t *testing.T) {
+	rec := newRecognizer(t, MonkeyGrammar())
+	for _, tt := range grammarCorpus {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		parserAccepts := len(p.Errors()) == 0
+		grammarAccepts := rec.accepts(tt.input)
+
+		if parserAccepts != tt.valid {
+			t.Errorf("parser: %q valid=%t, want %t (errors: %v)", tt.input, parserAccepts, tt.valid, p.Errors())
+		}
+		if grammarAccepts != tt.valid {
+			t.Errorf("grammar: %q valid=%t, want %t", tt.input, grammarAccepts, tt.valid)
+		}
+	}
+}
+
+func TestGrammarJSON(t *testing.T) {
+	data, err := json.Marshal(MonkeyGrammar())
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %s", err)
+	}
+	if !strings.HasPrefix(string(data), `{"name":"monkey","word":"identifier","extras":[{"type":"PATTERN","value":"\\s"},{"type":"SYMBOL","name":"comment"}],"rules":{"source_file":`) {
+		t.Errorf("grammar.json has the wrong header. got=%.120s", data)
+	}
+
+	var decoded struct {
+		Rules map[string]json.RawMessage `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("grammar.json is not valid JSON: %s", err)
+	}
+	if len(decoded.Rules) != len(MonkeyGrammar().Rules) {
+		t.Errorf("wrong number of rules. want=%d, got=%d", len(MonkeyGrammar().Rules), len(decoded.Rules))
+	}
+	expected := fmt.Sprintf(`{"type":"PREC_LEFT","value":%d,"content":{"type":"SEQ","members":[{"type":"SYMBOL","name":"_expression"},{"type":"STRING","value":"+"},{"type":"SYMBOL","name":"_expression"}]}}`, SUM)
+	if !strings.Contains(string(decoded.Rules["binary_expression"]), expected) {
+		t.Errorf("binary_expression does not contain %s", expected)
+	}
+}
+
+//// An Earley recognizer over tree-sitter rules ////
+
+// symbol is either a nonterminal (by name) or a terminal STRING/PATTERN rule
+type symbol struct {
+	nonterminal string
+	terminal    *Rule
+	re          *regexp.Regexp
+}
+
+type production struct {
+	lhs string
+	rhs []symbol
+}
+
+type item struct {
+	prod   int
+	dot    int
+	origin int
+}
+
+type recognizer struct {
+	productions []production
+	byLHS       map[string][]int
+	nullable    map[string]bool
+	fresh       int
+}
+
+func newRecognizer(t *testing.T, g *Grammar) *recognizer {
+	r := &recognizer{byLHS: map[string][]int{}, nullable: map[string]bool{}}
+	r.add("$start", symbol{nonterminal: g.Rules[0].Name})
+	for _, nr := range g.Rules {
+		r.add(nr.Name, r.symbolFor(t, nr.Rule))
+	}
+
+	// a nonterminal is nullable if one of its productions consists only of nullable nonterminals
+	for changed := true; changed; {
+		changed = false
+		for _, p := range r.productions {
+			if r.nullable[p.lhs] {
+				continue
+			}
+			all := true
+			for _, s := range p.rhs {
+				if s.terminal != nil || !r.nullable[s.nonterminal] {
+					all = false
+					break
+				}
+			}
+			if all {
+				r.nullable[p.lhs] = true
+				changed = true
+			}
+		}
+	}
+	return r
+}
+
+func (r *recognizer) add(lhs string, rhs ...symbol) {
+	r.byLHS[lhs] = append(r.byLHS[lhs], len(r.productions))
+	r.productions = append(r.productions, production{lhs: lhs, rhs: rhs})
+}
+
+func (r *recognizer) freshName() string {
+	r.fresh++
+	return fmt.Sprintf("$%d", r.fresh)
+}
+
+// symbolFor turns rule into a single symbol, adding anonymous nonterminals for the nested rules
+func (r *recognizer) symbolFor(t *testing.T, rule *Rule) symbol {
+	switch rule.Type {
+	case "SYMBOL":
+		return symbol{nonterminal: rule.Name}
+	case "STRING":
+		return symbol{terminal: rule}
+	case "PATTERN":
+		return symbol{terminal: rule, re: regexp.MustCompile("^(?:" + rule.Value + ")$")}
+	case "PREC", "PREC_LEFT", "PREC_RIGHT":
+		return r.symbolFor(t, rule.Content)
+	}
+
+	name := r.freshName()
+	switch rule.Type {
+	case "BLANK":
+		r.add(name)
+	case "SEQ":
+		rhs := []symbol{}
+		for _, m := range rule.Members {
+			rhs = append(rhs, r.symbolFor(t, m))
+		}
+		r.add(name, rhs...)
+	case "CHOICE":
+		for _, m := range rule.Members {
+			r.add(name, r.symbolFor(t, m))
+		}
+	case "REPEAT":
+		r.add(name)
+		r.add(name, symbol{nonterminal: name}, r.symbolFor(t, rule.Content))
+	case "REPEAT1":
+		content := r.symbolFor(t, rule.Content)
+		r.add(name, content)
+		r.add(name, symbol{nonterminal: name}, content)
+	default:
+		t.Fatalf("unknown rule type %q", rule.Type)
+	}
+	return symbol{nonterminal: name}
+}
+
+// matches mimics tree-sitter's keyword extraction: keywords and punctuation only ever match
+// STRING rules, PATTERN rules only match identifiers and literals
+func (s symbol) matches(tok token.Token) bool {
+	switch tok.Type {
+	case token.IDENT, token.INT, token.FLOAT:
+		return s.re != nil && s.re.MatchString(tok.Literal)
+	case token.STRING:
+		return s.re != nil && s.re.MatchString(`"`+tok.Literal+`"`)
+	case token.ILLEGAL:
+		return false
+	default:
+		return s.re == nil && s.terminal.Value == tok.Literal
+	}
+}
+
+func (r *recognizer) accepts(input string) bool {
+	tokens := []token.Token{}
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type != token.COMMENT { // comments are extras, allowed anywhere
+			tokens = append(tokens, tok)
+		}
+	}
+
+	chart := make([][]item, len(tokens)+1)
+	seen := make([]map[item]bool, len(tokens)+1)
+	for i := range seen {
+		seen[i] = map[item]bool{}
+	}
+	push := func(i int, it item) {
+		if !seen[i][it] {
+			seen[i][it] = true
+			chart[i] = append(chart[i], it)
+		}
+	}
+	for _, p := range r.byLHS["$start"] {
+		push(0, item{prod: p, origin: 0})
+	}
+
+	for i := 0; i <= len(tokens); i++ {
+		for j := 0; j < len(chart[i]); j++ {
+			it := chart[i][j]
+			prod := r.productions[it.prod]
+
+			if it.dot == len(prod.rhs) { // complete
+				for _, parent := range chart[it.origin] {
+					pp := r.productions[parent.prod]
+					if parent.dot < len(pp.rhs) && pp.rhs[parent.dot].nonterminal == prod.lhs {
+						push(i, item{parent.prod, parent.dot + 1, parent.origin})
+					}
+				}
+				continue
+			}
+
+			next := prod.rhs[it.dot]
+			if next.terminal != nil { // scan
+				if i < len(tokens) && next.matches(tokens[i]) {
+					push(i+1, item{it.prod, it.dot + 1, it.origin})
+				}
+				continue
+			}
+
+			for _, p := range r.byLHS[next.nonterminal] { // predict
+				push(i, item{prod: p, origin: i})
+			}
+			if r.nullable[next.nonterminal] {
+				push(i, item{it.prod, it.dot + 1, it.origin})
+			}
+		}
+	}
+
+	for _, it := range chart[len(tokens)] {
+		prod := r.productions[it.prod]
+		if prod.lhs == "$start" && it.dot == len(prod.rhs) && it.origin == 0 {
+			return true
+		}
+	}
+	return false
+}
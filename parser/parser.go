@@ -2,49 +2,96 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
+	"os"
 	"strconv"
 )
 
 type (
-	// prefixParseFn gets called when we encounter the associated token type in prefix position
-	prefixParseFn func() ast.Expression
+	// PrefixParseFn gets called when we encounter the associated token type in prefix position
+	PrefixParseFn func() ast.Expression
 
-	// infixParseFn gets called when we encounter the token type in infix position
-	infixParseFn func(ast.Expression) ast.Expression // takes the 'left side' of the infix operator
+	// InfixParseFn gets called when we encounter the token type in infix position
+	InfixParseFn func(ast.Expression) ast.Expression // takes the 'left side' of the infix operator
 )
 
+// Mode is a set of flags controlling optional parser behaviour
+type Mode uint
+
+const (
+	Trace            Mode = 1 << iota // print BEGIN/END lines for parseExpression and friends
+	ParseComments                     // collect comments instead of dropping them, see Comments()
+	StrictSemicolons                  // require a ';' after every statement that isn't the last one of its block
+)
+
+// TokenSource is anything the parser can read tokens from. *lexer.Lexer is the usual one, tests
+// and generated code can feed a token stream of their own. After the input is used up NextToken
+// must keep returning EOF tokens
+type TokenSource interface {
+	NextToken() token.Token
+}
+
 type Parser struct {
-	l *lexer.Lexer // pointer to an instance of the lexer
+	l    TokenSource // usually a *lexer.Lexer
+	mode Mode
 
 	// similar to 'pointers' in our lexer (position and readPosition)
 	// But instead of pointing to a charcter of the input, they point to the current and next token
 	curToken  token.Token
 	peekToken token.Token
 
-	errors []string
+	errors   []ParseError
+	comments []token.Token
+
+	// what the comments sit between, and where each statement starts and ends, for CommentMap.
+	// Only filled in ParseComments mode, see parser_comments.go
+	commentLinks []commentLink
+	spans        []statementSpan
+
+	// tracing state, see parser_tracing.go
+	traceOut   io.Writer
+	traceLevel int
+
+	// depth counts how many parseExpression calls are currently active. Once it goes past
+	// maxDepth the parser gives up on the rest of the input and sets bailed
+	depth    int
+	maxDepth int
+	bailed   bool
 
 	// allows us to check if the appropriate map has a parsing function associated with curToken.Type
-	prefixParseFns map[token.TokenType]prefixParseFn
-	infixParseFns  map[token.TokenType]infixParseFn
+	prefixParseFns map[token.TokenType]PrefixParseFn
+	infixParseFns  map[token.TokenType]InfixParseFn
+
+	// the binding power of every infix token, a copy of the package level precedences so that
+	// SetPrecedence only affects this parser
+	precedences map[token.TokenType]int
 }
 
-// User iota to increment these constants starting at 1 for LOWEST and 7 for CALL
+// User iota to increment these constants starting at 1 for LOWEST and 9 for INDEX
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // x = y
+	OR          // ||
+	AND         // &&
+	RANGE       // 1..10
 	EQUALS      // ==
 	LESSGREATER // < or >
 	SUM         // +
-	PRODUCT     // *
+	PRODUCT     // * / %
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)
 	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
+	token.ASSIGN:   ASSIGN,
+	token.OR:       OR,
+	token.AND:      AND,
+	token.DOTDOT:   RANGE,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
@@ -53,46 +100,76 @@ var precedences = map[token.TokenType]int{
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
 }
 
-func New(l *lexer.Lexer) *Parser {
+// DefaultMaxDepth is how deeply expressions may nest before the parser reports an error,
+// generous for any hand-written program while keeping the Go stack small
+const DefaultMaxDepth = 1000
+
+func New(l TokenSource) *Parser {
+	return NewWithMode(l, 0)
+}
+
+// NewWithMode creates a parser with the optional behaviour selected by mode switched on
+func NewWithMode(l TokenSource, mode Mode) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:        l,
+		mode:     mode,
+		errors:   []ParseError{},
+		comments: []token.Token{},
+		traceOut: os.Stdout,
+		maxDepth: DefaultMaxDepth,
 	}
 
 	// Initialize the prefixParseFns map on Parser and register a parsing function. Do the same for infixParseFns
-	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
-	p.infixParseFns = make(map[token.TokenType]infixParseFn)
+	p.prefixParseFns = make(map[token.TokenType]PrefixParseFn)
+	p.infixParseFns = make(map[token.TokenType]InfixParseFn)
+	p.precedences = make(map[token.TokenType]int, len(precedences))
+	for t, prec := range precedences {
+		p.precedences[t] = prec
+	}
 
 	// If, for eg, we encounter a token in a prefix expression
 	// of type: token.IDENT, the parsing function
 	// to call is parseIdentifier. Same for infix expressions
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefix(token.PIPE, p.parseLambda)
+	p.registerPrefix(token.OR, p.parseLambda) // `|| x`, a lambda without parameters
 
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOTDOT, p.parseRangeExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
 
 	// Read two tokents, so curToken and peekToken are both set
 	p.nextToken()
@@ -110,11 +187,13 @@ func (p *Parser) ParseProgram() *ast.Program {
 	for !p.curTokenIs(token.EOF) {
 
 		// during each iteration it parses a statement
+		start := p.curToken
 		stmt := p.parseStatement()
 
 		// unless the statement is nil, it adds the statement to the program's list of statements
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
+			p.recordSpan(stmt, start)
 		}
 		p.nextToken()
 	}
@@ -123,21 +202,54 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// ParseExpr parses src as a single expression, optionally followed by a semicolon
+func ParseExpr(src string) (ast.Expression, error) {
+	p := New(lexer.New(src))
+	exp := p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	if !p.peekTokenIs(token.EOF) {
+		p.errorAt(p.peekToken, token.EOF, fmt.Sprintf("unexpected %s after expression", p.peekToken.Type))
+	}
+	if len(p.errors) > 0 {
+		return nil, ErrorList(p.errors)
+	}
+	return exp, nil
+}
+
 // Main idea of Pratt parser: association of parsing functions with token types. EG: When I encounter LET token type, appropriate parseLetStatement() function is called
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.DEFER:
+		return p.parseDeferStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForInStatement()
+	case token.BREAK:
+		stmt := &ast.BreakStatement{Token: p.curToken}
+		p.skipSemicolon()
+		return stmt
+	case token.CONTINUE:
+		stmt := &ast.ContinueStatement{Token: p.curToken}
+		p.skipSemicolon()
+		return stmt
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 // parseLetStatement constructs an *ast.LetStatement node with the token its currently sitting on (a LET token), then advances the tokens while making assertions about the next token with calls to expectPeek
+// const statements are parsed here too, they only differ in the keyword
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+	stmt := &ast.LetStatement{Token: p.curToken, Const: p.curTokenIs(token.CONST)}
 
 	// First, an Identifier is expected
 	if !p.expectPeek(token.IDENT) {
@@ -146,7 +258,11 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
-	// Then, an equal sign is expected
+	// Then, an equal sign is expected. A const without a value could never get one, so it gets its own error
+	if stmt.Const && !p.peekTokenIs(token.ASSIGN) {
+		p.errorAt(p.peekToken, token.ASSIGN, fmt.Sprintf("missing initializer in const declaration of %s", stmt.Name.Value))
+		return nil
+	}
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -155,9 +271,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
-	if p.peekTokenIs(token.SEMICOLON) {
-		p.nextToken()
-	}
+	p.skipSemicolon()
 	return stmt
 }
 
@@ -167,34 +281,135 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
+	p.skipSemicolon()
+
+	return stmt
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	p.skipSemicolon()
+
+	return stmt
+}
+
+func (p *Parser) parseDeferStatement() *ast.DeferStatement {
+	stmt := &ast.DeferStatement{Token: p.curToken}
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	p.skipSemicolon()
+
+	return stmt
+}
+
+// parseWhileStatement parses `while (cond) { ... }`. Like a block, it needs no ';' after it
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
+	return stmt
+}
+
+// parseForInStatement parses `for (x in iterable) { ... }` and `for (k, v in iterable) { ... }`.
+// Like a while loop, it needs no ';' after it
+func (p *Parser) parseForInStatement() ast.Statement {
+	stmt := &ast.ForInStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
 
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
 	return stmt
 }
 
 // parseExpressionStatement constructs an AST node, and only advance curToken if the next token is a semicolon
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	// defer untrace(trace("parseExperessionStatement"))
+	if p.mode&Trace != 0 {
+		defer p.untrace(p.trace("parseExpressionStatement"))
+	}
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
-	if p.peekTokenIs(token.SEMICOLON) {
-		p.nextToken()
-	}
+	p.skipSemicolon()
 	return stmt
 }
 
 // All parsing functions, this one, prefixParseFun, and infixParseFn - don't advance tokens.
+// Except for `select {`, which starts a SelectExpression: select isn't a keyword, so the select
+// builtin can still be called, and nothing else has an identifier followed by a '{'
 func (p *Parser) parseIdentifier() ast.Expression {
+	if p.curToken.Literal == "select" && p.peekTokenIs(token.LBRACE) {
+		return p.parseSelectExpression()
+	}
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 
-	// defer untrace(trace("parseExpression"))
+	if p.mode&Trace != 0 {
+		defer p.untrace(p.trace("parseExpression"))
+	}
+
+	// Every level of nesting - (, [, {, if, fn, prefix operators... - goes through here,
+	// so this is the one place that needs to guard against runaway recursion
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > p.maxDepth {
+		p.bail("expression nesting too deep")
+		return nil
+	}
+
 	// Check: Do we have a parsing function associated with p.curToken.Type in the prefix position?
 	prefix := p.prefixParseFns[p.curToken.Type]
 
@@ -222,15 +437,16 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 // parses the literal "5" from input into the numeric expression
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	// defer untrace(trace("parseIntegerLiteral"))
+	if p.mode&Trace != 0 {
+		defer p.untrace(p.trace("parseIntegerLiteral"))
+	}
 
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorAt(p.curToken, "", fmt.Sprintf("could not parse %q as integer", p.curToken.Literal))
 		return nil
 	}
 
@@ -238,15 +454,35 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+// parses the literal "3.14" into a float expression
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	if p.mode&Trace != 0 {
+		defer p.untrace(p.trace("parseFloatLiteral"))
+	}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.errorAt(p.curToken, "", fmt.Sprintf("could not parse %q as float", p.curToken.Literal))
+		return nil
+	}
+	return &ast.FloatLiteral{Token: p.curToken, Value: value}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 // Builds an AST node, like usual
 // BUT: It advances our tokens by calling p.nextToken()!
 // (Because We're working with prefix and expression)
 func (p *Parser) parsePrefixExpression() ast.Expression {
-	// defer untrace(trace("parsePrefixExpression"))
+	if p.mode&Trace != 0 {
+		defer p.untrace(p.trace("parsePrefixExpression"))
+	}
 
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -269,7 +505,9 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 // parseInfixExpression:
 // 1. Takes argument left expression
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	// defer untrace(trace("parseInfixExpression"))
+	if p.mode&Trace != 0 {
+		defer p.untrace(p.trace("parseInfixExpression"))
+	}
 
 	// 2. constructs an InfixExpression node
 	expression := &ast.InfixExpression{
@@ -286,6 +524,27 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parseAssignExpression parses `x = value` and `arr[i] = value`. Assignment is right associative,
+// x = y = 1 assigns 1 to y and then to x
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	p.nextToken()
+	value := p.parseExpression(ASSIGN - 1)
+
+	switch left := left.(type) {
+	case *ast.Identifier:
+		return &ast.AssignExpression{Token: tok, Name: left, Value: value}
+	case *ast.IndexExpression:
+		return &ast.IndexAssignExpression{Token: tok, Target: left, Value: value}
+	}
+	// left can be only partly parsed, with nil children, so it's named by its kind rather than
+	// printed. A nil left already has an error of its own
+	if left != nil {
+		p.errorAt(tok, "", fmt.Sprintf("cannot assign to %s", left.Kind()))
+	}
+	return nil
+}
+
 // Account for grouped operations, like (5+5)*2,
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
@@ -327,6 +586,37 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// parseTryExpression expects a block, then the catch keyword followed by a parenthesized
+// identifier for the error and a second block
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Block = p.parseBlockStatement()
+
+	if !p.expectPeek(token.CATCH) {
+		return nil
+	}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	expression.CatchParam = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.CatchBlock = p.parseBlockStatement()
+
+	return expression
+}
+
 // Calls parseStatement until it encounters a '}' (end of block) or EOF (no more tokens)
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
@@ -334,14 +624,17 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	p.nextToken()
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		start := p.curToken
 		stmt := p.parseStatement()
 
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
+			p.recordSpan(stmt, start)
 		}
 
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
 
 	return block
 }
@@ -361,7 +654,53 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-// constructs the slice of params by repeatedly building identifiers from the comma separated list. It also makes an early exit if the list is empty
+// parseLambda parses the short function form `|x, y| x + y` into the same FunctionLiteral
+// as `fn(x, y) { x + y }`. The body is a single expression and extends as far to the right as
+// possible, so |x| x + 1 is fn(x) { x + 1 }
+func (p *Parser) parseLambda() ast.Expression {
+	tok := p.curToken
+	lit := &ast.FunctionLiteral{Token: token.Token{
+		Type: token.FUNCTION, Literal: "fn", Position: tok.Position,
+	}}
+
+	lit.Parameters = []*ast.Identifier{}
+	if tok.Type == token.PIPE {
+		for !p.peekTokenIs(token.PIPE) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			lit.Parameters = append(lit.Parameters, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+			if !p.peekTokenIs(token.PIPE) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+	}
+
+	p.nextToken()
+	body := &ast.ExpressionStatement{Token: p.curToken, Expression: p.parseExpression(LOWEST)}
+	lit.Body = &ast.BlockStatement{Token: tok, Statements: []ast.Statement{body}}
+	return lit
+}
+
+// parseMacroLiteral parses a macro the same way as a function literal: a parameter list and a body
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
+// constructs the slice of params by repeatedly building identifiers from the comma separated list. It also makes an early exit if the list is empty.
+// Like every other comma separated list, a trailing comma is allowed
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
@@ -369,14 +708,21 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 		p.nextToken()
 		return identifiers
 	}
-	p.nextToken()
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
 
 	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	identifiers = append(identifiers, ident)
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
-		p.nextToken()
+		if p.peekTokenIs(token.RPAREN) { // trailing comma
+			break
+		}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		identifiers = append(identifiers, ident)
 	}
@@ -392,21 +738,148 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 // construct an *ast.CallExpression node
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	if !p.parseCallArguments(exp) {
+		return nil
+	}
 	return exp
 }
 
+// parseCallArguments works like parseExpressionList, but also accepts `name: value` arguments.
+// An identifier directly followed by a ':' can't start any other expression (hash literals
+// start with a '{'), so that's all it takes to tell them apart
+func (p *Parser) parseCallArguments(exp *ast.CallExpression) bool {
+	exp.Arguments = []ast.Expression{}
+	exp.NamedArguments = []*ast.NamedArgument{}
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		exp.Rparen = p.curToken
+		return true
+	}
+
+	for {
+		p.nextToken()
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+			name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			p.nextToken()
+			p.nextToken()
+			exp.NamedArguments = append(exp.NamedArguments, &ast.NamedArgument{Name: name, Value: p.parseExpression(LOWEST)})
+		} else if len(exp.NamedArguments) > 0 {
+			p.errorAt(p.curToken, "", fmt.Sprintf("positional argument %s after named arguments", p.curToken.Literal))
+			return false
+		} else {
+			exp.Arguments = append(exp.Arguments, p.parseExpression(LOWEST))
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+		if p.peekTokenIs(token.RPAREN) { // trailing comma
+			break
+		}
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return false
+	}
+	exp.Rparen = p.curToken
+	return true
+}
+
+// parseStringLiteral unescapes the literal, so the Value of the node is the string the program
+// means. An invalid escape sequence is reported at its backslash. A literal with ${...} in it
+// becomes an InterpolatedString
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	tok := p.curToken
+	parts := lexer.SplitString(tok.Literal)
+	if len(parts) == 1 && !parts[0].Expr {
+		value, ok := p.unescape(tok, parts[0])
+		if !ok {
+			return nil
+		}
+		return &ast.StringLiteral{Token: tok, Value: value}
+	}
+
+	str := &ast.InterpolatedString{Token: tok}
+	text := ""
+	for _, part := range parts {
+		if !part.Expr {
+			value, ok := p.unescape(tok, part)
+			if !ok {
+				return nil
+			}
+			text = value
+			continue
+		}
+		if part.Unterminated {
+			tok.Position = advance(tok.Position, `"`+tok.Literal[:part.Offset-len("${")])
+			p.errorAt(tok, "", "unterminated ${")
+			return nil
+		}
+		exp := p.parseInterpolation(part.Text, advance(tok.Position, `"`+tok.Literal[:part.Offset]))
+		if exp == nil {
+			return nil
+		}
+		str.Strings = append(str.Strings, text)
+		str.Exprs = append(str.Exprs, exp)
+		text = ""
+	}
+	str.Strings = append(str.Strings, text)
+	return str
+}
+
+// unescape gives the value of a text part of the literal of tok, reporting an error if it has an
+// invalid escape sequence
+func (p *Parser) unescape(tok token.Token, part lexer.StringPart) (string, bool) {
+	value, err := lexer.Unescape(part.Text)
+	if err != nil {
+		offset := part.Offset + err.(*lexer.EscapeError).Offset
+		tok.Position = advance(tok.Position, `"`+tok.Literal[:offset])
+		p.errorAt(tok, "", err.Error())
+		return "", false
+	}
+	return value, true
+}
+
+// parseInterpolation parses the expression of a ${...} in a string, whose source src starts at pos.
+// It points the parser at a lexer over src for the time being, so the expression is parsed with
+// the same operators, mode and depth limit as the code around it
+func (p *Parser) parseInterpolation(src string, pos token.Position) ast.Expression {
+	l, cur, peek := p.l, p.curToken, p.peekToken
+	defer func() { p.l, p.curToken, p.peekToken = l, cur, peek }()
+
+	p.l = lexer.NewAt(src, pos)
+	p.nextToken()
+	p.nextToken()
+	exp := p.parseExpression(LOWEST)
+	if exp != nil && !p.peekTokenIs(token.EOF) {
+		p.errorAt(p.peekToken, "", fmt.Sprintf("unexpected %s in string interpolation", p.peekToken.Type))
+		return nil
+	}
+	return exp
+}
+
+// advance gives the position just past text that starts at pos
+func advance(pos token.Position, text string) token.Position {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			pos.Line++
+			pos.Column = 0
+		}
+		pos.Column++
+	}
+	pos.Offset += len(text)
+	return pos
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken
 	return array
 }
 
-// a modified and gereralized version of parseCallArguments
+// a modified and gereralized version of parseCallArguments. A trailing comma before the end token is allowed
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	list := []ast.Expression{}
 	if p.peekTokenIs(end) {
@@ -417,6 +890,9 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	list = append(list, p.parseExpression(LOWEST))
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+		if p.peekTokenIs(end) { // trailing comma
+			break
+		}
 		p.nextToken()
 		list = append(list, p.parseExpression(LOWEST))
 	}
@@ -428,22 +904,202 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+// parseIndexExpression handles both arr[i] and the slice forms arr[1:3], arr[:3] and arr[2:].
+// A ':' right after the '[' or after the first expression turns it into a slice
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 	p.nextToken()
+	if p.curTokenIs(token.COLON) {
+		return p.parseSliceExpression(exp.Token, left, nil)
+	}
 	exp.Index = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(exp.Token, left, exp.Index)
+	}
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	exp.Rbracket = p.curToken
 	return exp
 }
 
-// loops over key-value expression pairs by checking for a closing token.RBRACE 
+// parseSliceExpression is called while sitting on the ':' token, with the start of the slice already parsed (or nil)
+func (p *Parser) parseSliceExpression(tok token.Token, left ast.Expression, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Low: start}
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.Rbracket = p.curToken
+		return exp
+	}
+	p.nextToken()
+	exp.High = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Rbracket = p.curToken
+	return exp
+}
+
+// parseMatchExpression parses `match (subject) { pattern => body, ... }`.
+// Arms are comma separated, and like hash literals a trailing comma before the '}' is fine
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Arms = []*ast.MatchArm{}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	expression.Rbrace = p.curToken
+	return expression
+}
+
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Token: p.curToken}
+	arm.Pattern = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	p.nextToken()
+	arm.Body = p.parseExpression(LOWEST)
+	return arm
+}
+
+// parseSelectExpression parses `select { case v = recv(c): ... case send(d, x): ... default: ... }`.
+// It's called on the 'select' identifier
+func (p *Parser) parseSelectExpression() ast.Expression {
+	expression := &ast.SelectExpression{Token: p.curToken}
+	p.nextToken()
+
+	expression.Cases = []*ast.SelectCase{}
+	hasDefault := false
+	for !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
+		if !p.curTokenIs(token.IDENT) || p.curToken.Literal != "case" && p.curToken.Literal != "default" {
+			p.errorAt(p.curToken, "", fmt.Sprintf("expected case or default in select, got %s", p.curToken.Literal))
+			return nil
+		}
+		if p.curToken.Literal == "default" {
+			if hasDefault {
+				p.errorAt(p.curToken, "", "more than one default in select")
+				return nil
+			}
+			hasDefault = true
+		}
+		c := p.parseSelectCase()
+		if c == nil {
+			return nil
+		}
+		expression.Cases = append(expression.Cases, c)
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	expression.Rbrace = p.curToken
+	return expression
+}
+
+// parseSelectCase parses a case of a select, from the 'case' or 'default' identifier to the end of
+// the statements of its body, which runs until the next case or the end of the select. A statement
+// there can't start with an identifier called case or default
+func (p *Parser) parseSelectCase() *ast.SelectCase {
+	c := &ast.SelectCase{Token: p.curToken}
+	if p.curToken.Literal == "case" {
+		p.nextToken()
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.ASSIGN) {
+			c.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			p.nextToken()
+			p.nextToken()
+		}
+		op := ""
+		if p.curTokenIs(token.IDENT) {
+			op = p.curToken.Literal
+		}
+		switch {
+		case op == "recv" || op == "receive":
+		case op == "send" && c.Name == nil:
+		default:
+			want := "recv(channel) or send(channel, value)"
+			if c.Name != nil {
+				want = "recv(channel)"
+			}
+			p.errorAt(p.curToken, "", fmt.Sprintf("expected %s in select case, got %s", want, p.curToken.Literal))
+			return nil
+		}
+		c.Op = p.curToken
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		p.nextToken()
+		c.Channel = p.parseExpression(LOWEST)
+		if c.Op.Literal == "send" {
+			if !p.expectPeek(token.COMMA) {
+				return nil
+			}
+			p.nextToken()
+			c.Value = p.parseExpression(LOWEST)
+		}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	c.Body = &ast.BlockStatement{Token: p.curToken, Statements: []ast.Statement{}}
+	for !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) &&
+		!(p.peekTokenIs(token.IDENT) && (p.peekToken.Literal == "case" || p.peekToken.Literal == "default")) {
+		p.nextToken()
+		start := p.curToken
+		if stmt := p.parseStatement(); stmt != nil {
+			c.Body.Statements = append(c.Body.Statements, stmt)
+			p.recordSpan(stmt, start)
+		}
+	}
+	return c
+}
+
+// parseRangeExpression is called with the already parsed start of the range
+// while sitting on the '..' token. Because RANGE binds looser than comparison
+// and arithmetic, `1..n + 1` parses as 1..(n + 1)
+func (p *Parser) parseRangeExpression(start ast.Expression) ast.Expression {
+	exp := &ast.RangeExpression{Token: p.curToken, From: start}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	exp.To = p.parseExpression(precedence)
+	return exp
+}
+
+// loops over key-value expression pairs by checking for a closing token.RBRACE
 // and calling parseExpression two times.
 // Also fills hash.Pairs
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Pairs = []*ast.HashPair{}
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
 		key := p.parseExpression(LOWEST)
@@ -452,7 +1108,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		}
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, &ast.HashPair{Key: key, Value: value})
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
 		}
@@ -460,24 +1116,54 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.curToken
 	return hash
 }
 
 //// HELPER METHODS ////
 
 // To get the next tokens
-// Advances both curToken and peekToken
+// Advances both curToken and peekToken. Comments never reach the parsing functions,
+// they're either collected (in ParseComments mode) or dropped right here
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	first := len(p.comments)
+	for p.peekToken.Type == token.COMMENT {
+		if p.mode&ParseComments != 0 {
+			p.comments = append(p.comments, p.peekToken)
+			p.commentLinks = append(p.commentLinks, commentLink{prev: p.curToken})
+		}
+		p.peekToken = p.l.NextToken()
+	}
+	for i := first; i < len(p.comments); i++ {
+		p.commentLinks[i].next = p.peekToken
+	}
+}
+
+// skipSemicolon consumes the optional ';' that ends a statement. In StrictSemicolons mode it's
+// only optional for the last statement of a block or program
+func (p *Parser) skipSemicolon() {
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		return
+	}
+	if p.mode&StrictSemicolons != 0 && !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		p.peekError(token.SEMICOLON)
+	}
+}
+
+// Comments returns the comments seen so far, in source order. It's always empty unless the parser was created with ParseComments
+func (p *Parser) Comments() []token.Token {
+	return p.comments
 }
 
 // helper methods that add entries to the prefixParseFns & infixParseFns maps
-func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
+func (p *Parser) registerPrefix(tokenType token.TokenType, fn PrefixParseFn) {
 	p.prefixParseFns[tokenType] = fn
 }
 
-func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
+func (p *Parser) registerInfix(tokenType token.TokenType, fn InfixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
@@ -503,7 +1189,7 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 
 // peekPrecedence returns the precedence associate with the token type of p.peekToken, defaulting to the lowest
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+	if p, ok := p.precedences[p.peekToken.Type]; ok {
 		return p
 	}
 	return LOWEST
@@ -511,22 +1197,88 @@ func (p *Parser) peekPrecedence() int {
 
 // curPrecedence returns the precedence associate with the token type of p.peekToken, defaulting to the lowest
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
+	if p, ok := p.precedences[p.curToken.Type]; ok {
 		return p
 	}
 	return LOWEST
 }
 
+//// ERRORS ////
+
+// ParseError describes a single syntax error. Pos points at the offending token (Got), Expected
+// is only set when one specific token type would have been valid there
+type ParseError struct {
+	Pos      token.Position
+	Got      token.TokenType
+	Expected token.TokenType
+	Msg      string
+}
+
+func (e ParseError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// SetMaxDepth changes how deeply expressions may nest, DefaultMaxDepth by default
+func (p *Parser) SetMaxDepth(depth int) {
+	p.maxDepth = depth
+}
+
+// bail records msg as the final error and skips the rest of the input. Every parsing function
+// that is still active then simply runs into EOF, and the errors they'd report are dropped
+func (p *Parser) bail(msg string) {
+	p.errorAt(p.curToken, "", msg)
+	p.bailed = true
+	for !p.curTokenIs(token.EOF) {
+		p.nextToken()
+	}
+}
+
+// ErrorList is returned as the error of ParseExpr when there are one or more ParseErrors
+type ErrorList []ParseError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// errorAt records a ParseError for tok
+func (p *Parser) errorAt(tok token.Token, expected token.TokenType, msg string) {
+	if p.bailed {
+		return
+	}
+	p.errors = append(p.errors, ParseError{
+		Pos:      tok.Position,
+		Got:      tok.Type,
+		Expected: expected,
+		Msg:      msg,
+	})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.peekToken, t, msg)
 }
 
+// Errors returns just the messages of ParseErrors(), for callers that only want to print them
 func (p *Parser) Errors() []string {
+	msgs := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		msgs[i] = err.Msg
+	}
+	return msgs
+}
+
+// ParseErrors returns every error encountered so far, in the order they were found
+func (p *Parser) ParseErrors() []ParseError {
 	return p.errors
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse functions for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken, "", msg)
 }
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+// StatementComments are the comments attached to a single statement
+type StatementComments struct {
+	Leading  *ast.CommentGroup // comments right before the statement, with nothing but comments in between
+	Trailing *ast.CommentGroup // a comment after the statement's last token, on the same line
+}
+
+// CommentMap maps statements to their comments. It's a side table rather than a field of the
+// nodes so that the AST stays the same whether comments are parsed or not
+type CommentMap map[ast.Statement]*StatementComments
+
+// commentLink records the tokens a comment sits between. prev has an empty Type for comments at
+// the very start of the input
+type commentLink struct {
+	prev, next token.Token
+}
+
+type statementSpan struct {
+	stmt       ast.Statement
+	start, end token.Token
+}
+
+// recordSpan remembers that stmt started at start and ends at the current token
+func (p *Parser) recordSpan(stmt ast.Statement, start token.Token) {
+	if p.mode&ParseComments != 0 {
+		p.spans = append(p.spans, statementSpan{stmt: stmt, start: start, end: p.curToken})
+	}
+}
+
+// CommentMap attaches the comments collected so far to the statements they belong to. A comment on
+// the same line as the end of a statement trails it, any other comment leads the statement that
+// follows it. Comments that fit neither, eg one right before a closing '}', are left out: they're
+// still in Comments(). Without ParseComments the map is always empty
+func (p *Parser) CommentMap() CommentMap {
+	starts := map[int]ast.Statement{}
+	ends := map[int]ast.Statement{}
+	for _, span := range p.spans {
+		starts[span.start.Offset] = span.stmt
+		ends[span.end.Offset] = span.stmt // nested statements never end on the same token as their parent
+	}
+
+	cmap := CommentMap{}
+	attached := func(stmt ast.Statement) *StatementComments {
+		if cmap[stmt] == nil {
+			cmap[stmt] = &StatementComments{}
+		}
+		return cmap[stmt]
+	}
+	for i, c := range p.comments {
+		link := p.commentLinks[i]
+		if stmt, ok := ends[link.prev.Offset]; ok && link.prev.Type != "" && link.prev.Line == c.Line {
+			attached(stmt).Trailing = appendComment(attached(stmt).Trailing, c)
+		} else if stmt, ok := starts[link.next.Offset]; ok && link.next.Type != token.EOF {
+			attached(stmt).Leading = appendComment(attached(stmt).Leading, c)
+		}
+	}
+	return cmap
+}
+
+func appendComment(group *ast.CommentGroup, tok token.Token) *ast.CommentGroup {
+	if group == nil {
+		group = &ast.CommentGroup{}
+	}
+	group.List = append(group.List, &ast.Comment{Token: tok, Text: tok.Literal})
+	return group
+}
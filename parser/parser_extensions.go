@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+// The methods below let code outside this package add syntax to a Parser without forking it,
+// eg a pipeline operator that turns `x |> f` into f(x):
+//
+//	p.SetPrecedence(PIPE, parser.LOWEST+1)
+//	p.RegisterInfix(PIPE, func(left ast.Expression) ast.Expression {
+//		call := &ast.CallExpression{Token: p.CurToken(), Arguments: []ast.Expression{left}}
+//		prec := p.Precedence(p.CurToken().Type)
+//		p.NextToken()
+//		call.Function = p.ParseExpression(prec)
+//		return call
+//	})
+//
+// Parsing functions follow the same rules as the built-in ones: they're called with CurToken on
+// the first token of their construct and return with CurToken on its last token.
+
+// RegisterPrefix makes fn the parsing function for tokenType in prefix position, replacing the
+// built-in one if there is any
+func (p *Parser) RegisterPrefix(tokenType token.TokenType, fn PrefixParseFn) {
+	p.registerPrefix(tokenType, fn)
+}
+
+// RegisterInfix makes fn the parsing function for tokenType in infix position. The token also
+// needs a precedence above LOWEST (see SetPrecedence), otherwise fn is never called
+func (p *Parser) RegisterInfix(tokenType token.TokenType, fn InfixParseFn) {
+	p.registerInfix(tokenType, fn)
+}
+
+// SetPrecedence sets the binding power of tokenType as an infix operator. Use the precedence
+// constants, eg SUM, or a value between two of them
+func (p *Parser) SetPrecedence(tokenType token.TokenType, precedence int) {
+	p.precedences[tokenType] = precedence
+}
+
+// Precedence returns the binding power of tokenType, LOWEST if it isn't an infix operator
+func (p *Parser) Precedence(tokenType token.TokenType) int {
+	if prec, ok := p.precedences[tokenType]; ok {
+		return prec
+	}
+	return LOWEST
+}
+
+func (p *Parser) CurToken() token.Token  { return p.curToken }
+func (p *Parser) PeekToken() token.Token { return p.peekToken }
+func (p *Parser) NextToken()             { p.nextToken() }
+
+// ExpectPeek advances to the next token if it has type t, and records an error otherwise
+func (p *Parser) ExpectPeek(t token.TokenType) bool {
+	return p.expectPeek(t)
+}
+
+// ParseExpression parses an expression starting at CurToken, consuming operators that bind
+// tighter than precedence
+func (p *Parser) ParseExpression(precedence int) ast.Expression {
+	return p.parseExpression(precedence)
+}
+
+// Error records a syntax error at CurToken
+func (p *Parser) Error(msg string) {
+	p.errorAt(p.curToken, "", msg)
+}
@@ -1,13 +1,18 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"monkey/token"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
-///// LET Statements ///////
+// /// LET Statements ///////
 func TestLetStatements(t *testing.T) {
 
 	tests := []struct {
@@ -42,7 +47,58 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
-/////// RETURN Statements //////
+func TestConstStatements(t *testing.T) {
+	p := New(lexer.New("const PI = 3;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.LetStatement, got=%T", program.Statements[0])
+	}
+	if !stmt.Const {
+		t.Errorf("stmt.Const is false")
+	}
+	if stmt.Name.Value != "PI" {
+		t.Errorf("stmt.Name.Value not `PI`. got=%s", stmt.Name.Value)
+	}
+	testLiteralExpression(t, stmt.Value, 3)
+	if stmt.String() != "const PI = 3;" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+
+	p = New(lexer.New("let x = 1;"))
+	program = p.ParseProgram()
+	if program.Statements[0].(*ast.LetStatement).Const {
+		t.Errorf("let statement has Const set")
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"const PI;", "1:9: missing initializer in const declaration of PI"},
+		{"const PI", "1:9: missing initializer in const declaration of PI"},
+		{"const = 3;", "1:7: expected next token to be IDENT, got = instead"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.ParseErrors()
+		if len(errors) == 0 {
+			t.Errorf("no errors for %q", tt.input)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+// ///// RETURN Statements //////
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -79,7 +135,236 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
-/////// IDENTIFIER Expressions //////
+func TestThrowStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue interface{}
+	}{
+		{"throw 5;", 5},
+		{"throw err;", "err"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+		throwStmt, ok := program.Statements[0].(*ast.ThrowStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ThrowStatement. got=%T", program.Statements[0])
+		}
+		if throwStmt.TokenLiteral() != "throw" {
+			t.Fatalf("throwStmt.TokenLiteral not 'throw', got %q", throwStmt.TokenLiteral())
+		}
+		if !testLiteralExpression(t, throwStmt.Value, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestForInStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedKey   string // empty for the one variable form
+		expectedValue string
+	}{
+		{"for (x in xs) { x }", "", "x"},
+		{"for (k, v in h) { k; v; };", "k", "v"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ForInStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ForInStatement. got=%T", program.Statements[0])
+		}
+		if tt.expectedKey == "" && stmt.Key != nil {
+			t.Errorf("stmt.Key not nil. got=%s", stmt.Key)
+		}
+		if tt.expectedKey != "" {
+			testIdentifier(t, stmt.Key, tt.expectedKey)
+		}
+		testIdentifier(t, stmt.Value, tt.expectedValue)
+		if len(stmt.Body.Statements) == 0 {
+			t.Errorf("stmt.Body is empty")
+		}
+	}
+
+	errorTests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"for x in xs {}", "expected next token to be (, got IDENT instead"},
+		{"for (1 in xs) {}", "expected next token to be IDENT, got INT instead"},
+		{"for (x xs) {}", "expected next token to be IN, got IDENT instead"},
+		{"for (x in xs) x", "expected next token to be {, got IDENT instead"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestDeferStatements(t *testing.T) {
+	input := "defer close(f); defer x"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	deferStmt, ok := program.Statements[0].(*ast.DeferStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.DeferStatement. got=%T", program.Statements[0])
+	}
+	call, ok := deferStmt.Value.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("deferStmt.Value not *ast.CallExpression. got=%T", deferStmt.Value)
+	}
+	testIdentifier(t, call.Function, "close")
+	testIdentifier(t, program.Statements[1].(*ast.DeferStatement).Value, "x")
+	if program.String() != "defer close(f);defer x;" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	input := `while (x < 10) { if (x == 5) { break; } continue }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body does not contain 2 statements. got=%d", len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("body.Statements[1] not *ast.ContinueStatement. got=%T", stmt.Body.Statements[1])
+	}
+	if program.String() != "while ((x < 10)) { if ((x == 5)) { break; };continue; }" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+
+	for _, input := range []string{"while x < 10 { }", "while (true) x"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("no errors for %q", input)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5;", "(x = 5)"},
+		{"x = y = 5 + 1", "(x = (y = (5 + 1)))"},
+		{"arr[i + 1] = x || y", "(arr[(i + 1)] = (x || y))"},
+		{"h[\"a\"][0] = fn(x) { x = 1 }", "((h[\"a\"])[0] = fn(x) { (x = 1) })"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	for _, input := range []string{"1 = 2", "f() = 2", "x + y = 3"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || !strings.HasPrefix(p.Errors()[0], "cannot assign to ") {
+			t.Errorf("wrong errors for %q. got=%q", input, p.Errors())
+		}
+	}
+
+	// the left side of these is only partly parsed, with nil children
+	for _, input := range []string{"!@ = 0", "-@ = 0", "1 + @ = 0"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) < 2 || !strings.HasPrefix(errors[len(errors)-1], "cannot assign to ") {
+			t.Errorf("wrong errors for %q. got=%q", input, errors)
+		}
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "(1 + (2 * 3))"},
+		{"add(x, y);", "add(x, y)"},
+		{"  -a  ", "(-a)"},
+	}
+	for _, tt := range tests {
+		exp, err := ParseExpr(tt.input)
+		if err != nil {
+			t.Errorf("ParseExpr(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if exp.String() != tt.expected {
+			t.Errorf("ParseExpr(%q) wrong. expected=%q, got=%q", tt.input, tt.expected, exp.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2; 3", "1:8: unexpected INT after expression"},
+		{"let x = 1", "1:1: no prefix parse functions for LET found (and 1 more errors)"},
+		{"", "1:1: no prefix parse functions for EOF found"},
+		{"(1", "1:3: expected next token to be ), got EOF instead"},
+	}
+	for _, tt := range errorTests {
+		exp, err := ParseExpr(tt.input)
+		if err == nil {
+			t.Errorf("ParseExpr(%q) returned no error. got=%s", tt.input, exp)
+			continue
+		}
+		if exp != nil {
+			t.Errorf("ParseExpr(%q) returned an expression along with errors", tt.input)
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("ParseExpr(%q) wrong error. expected=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+// ///// IDENTIFIER Expressions //////
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar;"
 
@@ -112,7 +397,7 @@ func TestIdentifierExpression(t *testing.T) {
 	}
 }
 
-/////// INTEGER Expressions //////
+// ///// INTEGER Expressions //////
 func TestIntegerLiteralExpression(t *testing.T) {
 	input := "5;"
 
@@ -146,7 +431,28 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
-/////// Prefix or Unary Expressions //////
+// ///// Prefix or Unary Expressions //////
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "3.25;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != 3.25 {
+		t.Errorf("literal.Value not %g. got=%g", 3.25, literal.Value)
+	}
+	if literal.TokenLiteral() != "3.25" {
+		t.Errorf("literal.TokenLiteral not %s. got=%s", "3.25", literal.TokenLiteral())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -187,7 +493,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}
 }
 
-/////// Infix or Binary Expressions //////
+// ///// Infix or Binary Expressions //////
 func TestParsingInfixExpressions(t *testing.T) {
 	infixTests := []struct {
 		input      string
@@ -198,6 +504,7 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 + 5;", 5, "+", 5},
 		{"5 - 5;", 5, "-", 5},
 		{"5 * 5;", 5, "*", 5},
+		{"5 % 5;", 5, "%", 5},
 		{"5 / 5;", 5, "/", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
@@ -258,6 +565,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a * b / c",
 			"((a * b) / c)",
 		},
+		{
+			"a + b % c",
+			"(a + (b % c))",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
 		{
 			"a + b / c",
 			"(a + (b / c))",
@@ -268,7 +587,7 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		},
 		{
 			"3 + 4; -5 * 5",
-			"(3 + 4)((-5) * 5)",
+			"(3 + 4);((-5) * 5)",
 		},
 		{
 			"5 > 4 == 3 < 4",
@@ -338,6 +657,14 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"1..n + 1",
+			"(1..(n + 1))",
+		},
+		{
+			"a * 2..b < c",
+			"((a * 2)..(b < c))",
+		},
 	}
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
@@ -385,7 +712,28 @@ func TestBooleanExpression(t *testing.T) {
 	}
 }
 
-//// IF Expression /////
+func TestNullLiteral(t *testing.T) {
+	p := New(lexer.New("null;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	null, ok := stmt.Expression.(*ast.NullLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.NullLiteral. got=%T", stmt.Expression)
+	}
+	if null.TokenLiteral() != "null" {
+		t.Errorf("null.TokenLiteral not %q. got=%q", "null", null.TokenLiteral())
+	}
+}
+
+// // IF Expression /////
 func TestIfExpression(t *testing.T) {
 	input := `if (x < y) { x }`
 	l := lexer.New(input)
@@ -425,7 +773,7 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
-//// IF ELSE Expressions /////
+// // IF ELSE Expressions /////
 func TestIfElseExpression(t *testing.T) {
 	input := `if (x < y) { x } else { y }`
 
@@ -485,7 +833,66 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
-///// FUNTCTION Literal //////
+// /// FUNTCTION Literal //////
+func TestTryExpression(t *testing.T) {
+	input := `try { x } catch (e) { y }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.TryExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T", stmt.Expression)
+	}
+	if len(exp.Block.Statements) != 1 {
+		t.Fatalf("try block is not 1 statements. got=%d", len(exp.Block.Statements))
+	}
+	body := exp.Block.Statements[0].(*ast.ExpressionStatement)
+	if !testIdentifier(t, body.Expression, "x") {
+		return
+	}
+	if !testIdentifier(t, exp.CatchParam, "e") {
+		return
+	}
+	if len(exp.CatchBlock.Statements) != 1 {
+		t.Fatalf("catch block is not 1 statements. got=%d", len(exp.CatchBlock.Statements))
+	}
+	handler := exp.CatchBlock.Statements[0].(*ast.ExpressionStatement)
+	if !testIdentifier(t, handler.Expression, "y") {
+		return
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`try { x }`, "expected next token to be CATCH, got EOF instead"},
+		{`try { x } catch { y }`, "expected next token to be (, got { instead"},
+		{`try { x } catch (1) { y }`, "expected next token to be IDENT, got INT instead"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x,y) { x + y; }`
 	l := lexer.New(input)
@@ -525,109 +932,645 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
-///// Function PARAMETER //////
-func TestFunctionParameterParsing(t *testing.T) {
-	tests := []struct {
-		input          string
-		expectedParams []string
-	}{
-		{input: "fn() {};", expectedParams: []string{}},
-		{input: "fn(x) {};", expectedParams: []string{"x"}},
-		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
-	}
-
-	for _, tt := range tests {
-		l := lexer.New(tt.input)
-		p := New(l)
-		program := p.ParseProgram()
-		checkParserErrors(t, p)
-
-		stmt := program.Statements[0].(*ast.ExpressionStatement)
-		function := stmt.Expression.(*ast.FunctionLiteral)
-
-		if len(function.Parameters) != len(tt.expectedParams) {
-			t.Errorf("length parameters wrong. want %d, got= %d\n", len(tt.expectedParams), len(function.Parameters))
-		}
-		for i, ident := range tt.expectedParams {
-			testLiteralExpression(t, function.Parameters[i], ident)
-		}
-	}
-}
-
-///// Function CALL EXPRESSION //////
-func TestCallExpressionParsing(t *testing.T) {
-	input := "add(1, 2 * 3, 4 + 5);"
-	l := lexer.New(input)
-	p := New(l)
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { quote(unquote(x) + unquote(y)); }`
+	p := New(lexer.New(input))
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
 	if len(program.Statements) != 1 {
-		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+		t.Fatalf("program.Statements does not contain %d statements. got %d \n", 1, len(program.Statements))
 	}
 
 	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
 	if !ok {
-		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got =%T", program.Statements[0])
 	}
 
-	exp, ok := stmt.Expression.(*ast.CallExpression)
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
 	if !ok {
-		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got %T", stmt.Expression)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n", len(macro.Parameters))
 	}
 
-	if !testIdentifier(t, exp.Function, "add") {
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d \n", len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatment. got=%T", macro.Body.Statements[0])
+	}
+
+	// quote and unquote are plain calls
+	if bodyStmt.Expression.String() != "quote((unquote(x) + unquote(y)))" {
+		t.Errorf("macro body wrong. got=%q", bodyStmt.Expression.String())
+	}
+	if macro.String() != "macro(x, y) { quote((unquote(x) + unquote(y))) }" {
+		t.Errorf("macro.String() wrong. got=%q", macro.String())
+	}
+}
+
+func TestLambdaParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+		expected       string
+	}{
+		{"|x, y| x + y", []string{"x", "y"}, "fn(x, y) { (x + y) }"},
+		{"|| 1", []string{}, "fn() { 1 }"},
+		{"|x,| x", []string{"x"}, "fn(x) { x }"},
+		{"|x| |y| x * y", []string{"x"}, "fn(x) { fn(y) { (x * y) } }"},
+		{"map(arr, |x| x * 2)", nil, "map(arr, fn(x) { (x * 2) })"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+		if tt.expectedParams == nil {
+			continue
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got %T", stmt.Expression)
+		}
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d\n", len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+		if len(function.Body.Statements) != 1 {
+			t.Errorf("function.Body.Statements has not 1 statements. got=%d \n", len(function.Body.Statements))
+		}
+	}
+}
+
+// /// Function PARAMETER //////
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "fn() {};", expectedParams: []string{}},
+		{input: "fn(x) {};", expectedParams: []string{"x"}},
+		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got= %d\n", len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+	}
+}
+
+// /// Function CALL EXPRESSION //////
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Function, "add") {
 		return
 	}
 
 	if len(exp.Arguments) != 3 {
 		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
 	}
-
-	testLiteralExpression(t, exp.Arguments[0], 1)
-	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
-	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+}
+
+// / STRING LITERALS ///
+func TestCallExpressionNamedArguments(t *testing.T) {
+	input := `makeServer(1, port: 8080, host: "x", opts: {"a": 1})`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Function, "makeServer") {
+		return
+	}
+	if len(exp.Arguments) != 1 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+	testLiteralExpression(t, exp.Arguments[0], 1)
+
+	if len(exp.NamedArguments) != 3 {
+		t.Fatalf("wrong length of named arguments. got=%d", len(exp.NamedArguments))
+	}
+	expectedNames := []string{"port", "host", "opts"}
+	for i, name := range expectedNames {
+		if !testIdentifier(t, exp.NamedArguments[i].Name, name) {
+			return
+		}
+	}
+	testLiteralExpression(t, exp.NamedArguments[0].Value, 8080)
+	if _, ok := exp.NamedArguments[1].Value.(*ast.StringLiteral); !ok {
+		t.Errorf("host is not ast.StringLiteral. got=%T", exp.NamedArguments[1].Value)
+	}
+	if _, ok := exp.NamedArguments[2].Value.(*ast.HashLiteral); !ok {
+		t.Errorf("opts is not ast.HashLiteral. got=%T", exp.NamedArguments[2].Value)
+	}
+
+	expected := `makeServer(1, port: 8080, host: "x", opts: {"a": 1})`
+	if exp.String() != expected {
+		t.Errorf("exp.String() wrong. expected=%q, got=%q", expected, exp.String())
+	}
+}
+
+func TestCallExpressionNamedArgumentErrors(t *testing.T) {
+	input := `f(x: 1, 2)`
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors, got none")
+	}
+	expected := "positional argument 2 after named arguments"
+	if errors[0] != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errors[0])
+	}
+}
+
+func TestStringLIteralExpression(t *testing.T) {
+	input := `"hello world";`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got= %q", "hello world", literal.Value)
+	}
+}
+
+func TestStringEscapeErrors(t *testing.T) {
+	input := "let a = \"a\\nb\";\nlet b = \"x\ny \\q\";\n\"\\u12\""
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	expected := []ParseError{
+		{Pos: token.Position{Offset: 29, Line: 3, Column: 3}, Got: token.STRING, Msg: `invalid escape sequence \q`},
+		{Pos: token.Position{Offset: 35, Line: 4, Column: 2}, Got: token.STRING, Msg: `truncated escape sequence \u12, want 4 hex digits`},
+	}
+	errors := p.ParseErrors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d (%v)", len(expected), len(errors), errors)
+	}
+	for i, err := range expected {
+		if errors[i] != err {
+			t.Errorf("errors[%d] wrong. want=%+v, got=%+v", i, err, errors[i])
+		}
+	}
+
+	literal := program.Statements[0].(*ast.LetStatement).Value.(*ast.StringLiteral)
+	if literal.Value != "a\nb" {
+		t.Errorf("literal.Value not %q. got=%q", "a\nb", literal.Value)
+	}
+	if literal.String() != `"a\nb"` {
+		t.Errorf("literal.String() wrong. got=%q", literal.String())
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"Hello ${name}, ${"a${1 + 2}b"}!"`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+	if !reflect.DeepEqual(str.Strings, []string{"Hello ", ", ", "!"}) || len(str.Exprs) != 2 {
+		t.Fatalf("wrong parts. got=%q and %d expressions", str.Strings, len(str.Exprs))
+	}
+	testIdentifier(t, str.Exprs[0], "name")
+	if str.Exprs[0].(*ast.Identifier).Token.Position != (token.Position{Offset: 9, Line: 1, Column: 10}) {
+		t.Errorf("name at the wrong position. got=%+v", str.Exprs[0].(*ast.Identifier).Token.Position)
+	}
+	nested, ok := str.Exprs[1].(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("nested string not *ast.InterpolatedString. got=%T", str.Exprs[1])
+	}
+	testInfixExpression(t, nested.Exprs[0], 1, "+", 2)
+	if str.String() != `"Hello ${name}, ${"a${(1 + 2)}b"}!"` {
+		t.Errorf("str.String() wrong. got=%q", str.String())
+	}
+
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`"a ${}"`, "no prefix parse functions for EOF found"},
+		{`"a ${x y}"`, "unexpected IDENT in string interpolation"},
+		{`"a ${x} \q"`, `invalid escape sequence \q`},
+		{`"${"\q"}"`, `invalid escape sequence \q`},
+		{`"abc ${1 + 2`, "unterminated ${"},
+		{`"${"`, "unterminated ${"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expectedError, errors[0])
+		}
+	}
+
+	p = New(lexer.New("let s = \"a\n${x y}\""))
+	p.ParseProgram()
+	if err := p.ParseErrors()[0]; err.Pos != (token.Position{Offset: 15, Line: 2, Column: 5}) {
+		t.Errorf("interpolation error at the wrong position. got=%+v", err.Pos)
+	}
+	p = New(lexer.New(`x + "ab${1`))
+	p.ParseProgram()
+	if err := p.ParseErrors()[0]; err.Pos != (token.Position{Offset: 7, Line: 1, Column: 8}) {
+		t.Errorf("unterminated interpolation at the wrong position. got=%+v", err.Pos)
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingMatchExpressions(t *testing.T) {
+	input := `match (x) { 1 => "one", 2 => "two", _ => "other" }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	match, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MatchExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, match.Subject, "x") {
+		return
+	}
+	if len(match.Arms) != 3 {
+		t.Fatalf("match.Arms does not contain 3 arms. got=%d", len(match.Arms))
+	}
+
+	expected := []struct {
+		pattern interface{}
+		body    string
+	}{
+		{1, "one"},
+		{2, "two"},
+		{"_", "other"},
+	}
+	for i, tt := range expected {
+		arm := match.Arms[i]
+		if !testLiteralExpression(t, arm.Pattern, tt.pattern) {
+			return
+		}
+		body, ok := arm.Body.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("arm.Body not *ast.StringLiteral. got=%T", arm.Body)
+		}
+		if body.Value != tt.body {
+			t.Errorf("body.Value not %q. got=%q", tt.body, body.Value)
+		}
+	}
+
+	expectedString := `match (x) { 1 => "one", 2 => "two", _ => "other" }`
+	if match.String() != expectedString {
+		t.Errorf("match.String() wrong. expected=%q, got=%q", expectedString, match.String())
+	}
+}
+
+func TestParsingMatchExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`match (x) { 1 "one" }`, "expected next token to be =>, got STRING instead"},
+		{`match (x) { 1 => 2 3 => 4 }`, "expected next token to be ,, got INT instead"},
+		{`match x { 1 => 2 }`, "expected next token to be (, got IDENT instead"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestParsingSelectExpressions(t *testing.T) {
+	input := `select {
+	case v = recv(a):
+		puts(v)
+		v
+	case receive(b):
+	case send(c, 1 + 2): "sent"
+	default:
+		null
+}`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	sel, ok := stmt.Expression.(*ast.SelectExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SelectExpression. got=%T", stmt.Expression)
+	}
+	if len(sel.Cases) != 4 {
+		t.Fatalf("sel.Cases does not contain 4 cases. got=%d", len(sel.Cases))
+	}
+
+	expected := []struct {
+		name       string
+		op         string
+		channel    string
+		value      string
+		statements int
+	}{
+		{"v", "recv", "a", "", 2},
+		{"", "receive", "b", "", 0},
+		{"", "send", "c", "(1 + 2)", 1},
+		{"", "", "", "", 1},
+	}
+	for i, tt := range expected {
+		c := sel.Cases[i]
+		if (c.Name == nil) != (tt.name == "") || c.Name != nil && c.Name.Value != tt.name {
+			t.Errorf("case %d has the wrong name. want=%q, got=%v", i, tt.name, c.Name)
+		}
+		if c.Op.Literal != tt.op {
+			t.Errorf("case %d has the wrong op. want=%q, got=%q", i, tt.op, c.Op.Literal)
+		}
+		if tt.channel == "" && c.Channel != nil || tt.channel != "" && (c.Channel == nil || c.Channel.String() != tt.channel) {
+			t.Errorf("case %d has the wrong channel. want=%q, got=%v", i, tt.channel, c.Channel)
+		}
+		if tt.value == "" && c.Value != nil || tt.value != "" && (c.Value == nil || c.Value.String() != tt.value) {
+			t.Errorf("case %d has the wrong value. want=%q, got=%v", i, tt.value, c.Value)
+		}
+		if len(c.Body.Statements) != tt.statements {
+			t.Errorf("case %d has the wrong number of statements. want=%d, got=%d", i, tt.statements, len(c.Body.Statements))
+		}
+	}
+
+	expectedString := `select { case v = recv(a): puts(v); v; case receive(b): case send(c, (1 + 2)): "sent"; default: null; }`
+	if sel.String() != expectedString {
+		t.Errorf("sel.String() wrong. expected=%q, got=%q", expectedString, sel.String())
+	}
+	p = New(lexer.New(sel.String()))
+	if reparsed := p.ParseProgram(); !ast.Equal(program, reparsed) {
+		t.Errorf("sel.String() doesn't parse back to the same tree, got %q", reparsed.String())
+	}
+	if end := sel.End(); end != len(input) {
+		t.Errorf("sel.End() wrong. want=%d, got=%d", len(input), end)
+	}
+
+	// select is still an identifier when it's not followed by a '{'
+	p = New(lexer.New("select([a, b])"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	if got := program.String(); got != "select([a, b])" {
+		t.Errorf("call of select parsed wrong. got=%q", got)
+	}
+}
+
+func TestParsingSelectExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`select { 1 }`, "expected case or default in select, got 1"},
+		{`select { case c: 1 }`, "expected recv(channel) or send(channel, value) in select case, got c"},
+		{`select { case v = send(c, 1): 1 }`, "expected recv(channel) in select case, got send"},
+		{`select { case recv(c) 1 }`, "expected next token to be :, got INT instead"},
+		{`select { case send(c): 1 }`, "expected next token to be ,, got ) instead"},
+		{`select { default: 1 default: 2 }`, "more than one default in select"},
+		{`select { case recv(c): 1`, "expected next token to be }, got EOF instead"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStart interface{}
+		expectedEnd   interface{}
+		expected      string
+	}{
+		{"arr[1:3]", 1, 3, "(arr[1:3])"},
+		{"arr[:3]", nil, 3, "(arr[:3])"},
+		{"arr[2:]", 2, nil, "(arr[2:])"},
+		{"arr[:]", nil, nil, "(arr[:])"},
+		{"arr[i:j]", "i", "j", "(arr[i:j])"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		sliceExp, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+		if !testIdentifier(t, sliceExp.Left, "arr") {
+			return
+		}
+		if tt.expectedStart == nil {
+			if sliceExp.Low != nil {
+				t.Errorf("sliceExp.Low not nil. got=%s", sliceExp.Low)
+			}
+		} else if !testLiteralExpression(t, sliceExp.Low, tt.expectedStart) {
+			return
+		}
+		if tt.expectedEnd == nil {
+			if sliceExp.High != nil {
+				t.Errorf("sliceExp.High not nil. got=%s", sliceExp.High)
+			}
+		} else if !testLiteralExpression(t, sliceExp.High, tt.expectedEnd) {
+			return
+		}
+		if sliceExp.String() != tt.expected {
+			t.Errorf("sliceExp.String() wrong. expected=%q, got=%q", tt.expected, sliceExp.String())
+		}
+	}
 }
 
-/// STRING LITERALS ///
-func TestStringLIteralExpression(t *testing.T) {
-	input := `"hello world";`
+func TestParsingRangeExpressions(t *testing.T) {
+	input := "1..10"
 	l := lexer.New(input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
 	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	rangeExp, ok := stmt.Expression.(*ast.RangeExpression)
 	if !ok {
-		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+		t.Fatalf("exp not *ast.RangeExpression. got=%T", stmt.Expression)
 	}
-	if literal.Value != "hello world" {
-		t.Errorf("literal.Value not %q. got= %q", "hello world", literal.Value)
+	if !testIntegerLiteral(t, rangeExp.From, 1) {
+		return
+	}
+	if !testIntegerLiteral(t, rangeExp.To, 10) {
+		return
+	}
+	if rangeExp.String() != "(1..10)" {
+		t.Errorf("rangeExp.String() wrong. got=%q", rangeExp.String())
 	}
 }
 
-func TestParsingIndexExpressions(t *testing.T) {
-	input := "myArray[1 + 1]"
-	l := lexer.New(input)
+func TestTrailingCommas(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2,]", "[1, 2]"},
+		{"[\n  1,\n  2,\n]", "[1, 2]"},
+		{"fn(x, y,) { x }", "fn(x, y) { x }"},
+		{"add(1, 2 * 3,)", "add(1, (2 * 3))"},
+		{"add(1, b: 2,)", "add(1, b: 2)"},
+		{"add(a[1:],)", "add((a[1:]))"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	l := lexer.New(`{"one": 1, "two": 2,}`)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
-
-	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
-	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
-	if !ok {
-		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
-	}
-	if !testIdentifier(t, indexExp.Left, "myArray") {
-		return
+	hash := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+	if len(hash.Pairs) != 2 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
-	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
-		return
+}
+
+func TestTrailingCommaErrors(t *testing.T) {
+	tests := []string{"[1,,]", "add(,)", "fn(,) { 1 }"}
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q, got none", input)
+		}
 	}
 }
 
-/////// ERRORS //////
+// ///// ERRORS //////
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -641,7 +1584,295 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	t.FailNow()
 }
 
-////// ARRAYS //////
+func TestParseErrors(t *testing.T) {
+	input := `let x = 5;
+let = 10;
+  let y 838383;`
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	expected := []ParseError{
+		{Pos: token.Position{Offset: 15, Line: 2, Column: 5}, Got: token.ASSIGN, Expected: token.IDENT, Msg: "expected next token to be IDENT, got = instead"},
+		{Pos: token.Position{Offset: 15, Line: 2, Column: 5}, Got: token.ASSIGN, Msg: "no prefix parse functions for = found"},
+		{Pos: token.Position{Offset: 29, Line: 3, Column: 9}, Got: token.INT, Expected: token.ASSIGN, Msg: "expected next token to be =, got INT instead"},
+	}
+	errors := p.ParseErrors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d (%v)", len(expected), len(errors), errors)
+	}
+	for i, err := range expected {
+		if errors[i] != err {
+			t.Errorf("errors[%d] wrong. want=%+v, got=%+v", i, err, errors[i])
+		}
+		if p.Errors()[i] != err.Msg {
+			t.Errorf("Errors()[%d] wrong. want=%q, got=%q", i, err.Msg, p.Errors()[i])
+		}
+	}
+
+	if errors[2].Error() != "3:9: expected next token to be =, got INT instead" {
+		t.Errorf("errors[2].Error() wrong. got=%q", errors[2].Error())
+	}
+
+	p = New(lexer.NewFile(token.NewFileSet().AddFile("main.mk", input)))
+	p.ParseProgram()
+	if err := p.ParseErrors()[2].Error(); err != "main.mk:3:9: expected next token to be =, got INT instead" {
+		t.Errorf("error in a file wrong. got=%q", err)
+	}
+}
+
+// The program ParseProgram returns along with errors has nil where the parser gave up, which
+// walking it must cope with
+func TestWalkProgramsWithErrors(t *testing.T) {
+	inputs := []string{
+		"#00",
+		"!@ = 0",
+		"let = 1",
+		"fn(",
+		"x[",
+		"1 + ",
+		"try { 1 } catch",
+		"select { case recv(",
+	}
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("no errors for %q", input)
+		}
+		depth := 0
+		ast.Inspect(program, func(n ast.Node) bool {
+			if n == nil {
+				depth--
+			} else {
+				depth++
+			}
+			return true
+		})
+		if depth != 0 {
+			t.Errorf("unbalanced nil calls for %q. depth=%d", input, depth)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	input := strings.Repeat("(", 10000) + "1" + strings.Repeat(")", 10000)
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors. want=1, got=%d", len(errors))
+	}
+	if errors[0] != "expression nesting too deep" {
+		t.Errorf("wrong error. got=%q", errors[0])
+	}
+
+	tests := []struct {
+		input string
+		ok    bool
+	}{
+		{"((1))", true},
+		{"(((1)))", false},
+		{"[[1]]; [[2]]", true},
+		{"if (x) { if (y) { 1 } }", true},
+		{"if (x) { if (y) { if (z) { 1 } } }", false},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.SetMaxDepth(3)
+		p.ParseProgram()
+		if ok := len(p.Errors()) == 0; ok != tt.ok {
+			t.Errorf("wrong result for %q with max depth 3. want ok=%t, got errors=%v", tt.input, tt.ok, p.Errors())
+		}
+	}
+}
+
+func TestTraceMode(t *testing.T) {
+	var out bytes.Buffer
+	p := NewWithMode(lexer.New("-1 + 2"), Trace)
+	p.SetTraceOutput(&out)
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := `BEGIN parseExpressionStatement
+	BEGIN parseExpression
+		BEGIN parsePrefixExpression
+			BEGIN parseExpression
+				BEGIN parseIntegerLiteral
+				END parseIntegerLiteral
+			END parseExpression
+		END parsePrefixExpression
+		BEGIN parseInfixExpression
+			BEGIN parseExpression
+				BEGIN parseIntegerLiteral
+				END parseIntegerLiteral
+			END parseExpression
+		END parseInfixExpression
+	END parseExpression
+END parseExpressionStatement
+`
+	if out.String() != expected {
+		t.Errorf("wrong trace. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	p = New(lexer.New("-1 + 2"))
+	p.SetTraceOutput(&out)
+	p.ParseProgram()
+	if out.Len() != 0 {
+		t.Errorf("parser without Trace mode traced. got=%q", out.String())
+	}
+}
+
+func TestParseCommentsMode(t *testing.T) {
+	input := `// leading
+let x = 5; // trailing
+x // last`
+
+	p := NewWithMode(lexer.New(input), ParseComments)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	expected := []struct {
+		literal string
+		line    int
+	}{
+		{"// leading", 1},
+		{"// trailing", 2},
+		{"// last", 3},
+	}
+	comments := p.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments. want=%d, got=%d", len(expected), len(comments))
+	}
+	for i, tt := range expected {
+		if comments[i].Literal != tt.literal || comments[i].Line != tt.line {
+			t.Errorf("comments[%d] wrong. want=%q on line %d, got=%q on line %d", i, tt.literal, tt.line, comments[i].Literal, comments[i].Line)
+		}
+	}
+
+	p = New(lexer.New(input))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(p.Comments()) != 0 {
+		t.Errorf("parser without ParseComments mode collected comments. got=%v", p.Comments())
+	}
+}
+
+func TestCommentMap(t *testing.T) {
+	input := `// add adds
+// two numbers
+let add = fn(x, y) {
+	// the sum
+	x + y // done
+	// dangling
+};
+add(1, 2); // call
+// end`
+
+	p := NewWithMode(lexer.New(input), ParseComments)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0]
+	body := let.(*ast.LetStatement).Value.(*ast.FunctionLiteral).Body.Statements[0]
+	call := program.Statements[1]
+
+	tests := []struct {
+		stmt     ast.Statement
+		leading  []string
+		trailing []string
+	}{
+		{let, []string{"// add adds", "// two numbers"}, nil},
+		{body, []string{"// the sum"}, []string{"// done"}},
+		{call, nil, []string{"// call"}},
+	}
+
+	literals := func(group *ast.CommentGroup) []string {
+		if group == nil {
+			return nil
+		}
+		var lits []string
+		for _, c := range group.List {
+			lits = append(lits, c.Text)
+		}
+		return lits
+	}
+	cmap := p.CommentMap()
+	if len(cmap) != len(tests) {
+		t.Errorf("wrong number of statements with comments. want=%d, got=%d", len(tests), len(cmap))
+	}
+	for _, tt := range tests {
+		c, ok := cmap[tt.stmt]
+		if !ok {
+			t.Errorf("no comments attached to %q", tt.stmt.String())
+			continue
+		}
+		if !reflect.DeepEqual(literals(c.Leading), tt.leading) {
+			t.Errorf("leading comments of %q wrong. want=%q, got=%q", tt.stmt.String(), tt.leading, literals(c.Leading))
+		}
+		if !reflect.DeepEqual(literals(c.Trailing), tt.trailing) {
+			t.Errorf("trailing comments of %q wrong. want=%q, got=%q", tt.stmt.String(), tt.trailing, literals(c.Trailing))
+		}
+	}
+
+	if group := cmap[let].Leading; group != nil {
+		if group.Text() != "add adds\ntwo numbers" {
+			t.Errorf("group.Text() wrong. got=%q", group.Text())
+		}
+		if group.Pos() != 0 || group.End() != len("// add adds\n// two numbers") {
+			t.Errorf("group positions wrong. got=%d-%d", group.Pos(), group.End())
+		}
+	}
+
+	p = New(lexer.New(input))
+	p.ParseProgram()
+	if len(p.CommentMap()) != 0 {
+		t.Errorf("parser without ParseComments mode attached comments")
+	}
+}
+
+func TestStrictSemicolonsMode(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x = 5; x", ""},
+		{"let f = fn(x) { return x }; f(1);", ""},
+		{"if (x) { 1; 2 }", ""},
+		{"let x = 5 x", "expected next token to be ;, got IDENT instead"},
+		{"return 1 2", "expected next token to be ;, got INT instead"},
+		{"fn(x) { let y = x y }", "expected next token to be ;, got IDENT instead"},
+	}
+	for _, tt := range tests {
+		p := NewWithMode(lexer.New(tt.input), StrictSemicolons)
+		p.ParseProgram()
+		errors := p.Errors()
+		if tt.expectedError == "" {
+			if len(errors) != 0 {
+				t.Errorf("unexpected errors for %q: %v", tt.input, errors)
+			}
+			continue
+		}
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expectedError, errors)
+		}
+
+		p = New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("lenient parser rejected %q: %v", tt.input, p.Errors())
+		}
+	}
+}
+
+// //// ARRAYS //////
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 	l := lexer.New(input)
@@ -681,7 +1912,7 @@ func TestParsingEmptyArrayLiterals(t *testing.T) {
 	}
 }
 
-///// HASHES //// 
+// /// HASHES ////
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one":1, "two":2, "three":3}`
 	l := lexer.New(input)
@@ -697,16 +1928,17 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 	expected := map[string]int64{
-		"one": 1,
-		"two": 2,
+		"one":   1,
+		"two":   2,
 		"three": 3,
 	}
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral) 
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
+		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
 		}
-		expectedValue := expected[literal.String()]
+		expectedValue := expected[literal.Value]
 		testIntegerLiteral(t, value, expectedValue)
 	}
 }
@@ -727,6 +1959,32 @@ func TestParsingEmptyHashLiteral(t *testing.T) {
 	}
 }
 
+func TestHashLiteralPairOrder(t *testing.T) {
+	input := `{"b": 1, "a": 2, "b": 3}`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if hash.String() != `{"b": 1, "a": 2, "b": 3}` {
+		t.Errorf("hash.String() wrong. got=%q", hash.String())
+	}
+
+	value, ok := hash.Get(&ast.StringLiteral{Value: "b"})
+	if !ok {
+		t.Fatalf("hash.Get didn't find key b")
+	}
+	testIntegerLiteral(t, value, 3)
+	if _, ok := hash.Get(&ast.StringLiteral{Value: "c"}); ok {
+		t.Errorf("hash.Get found missing key c")
+	}
+}
+
 func TestParsingHashLiteralsBooleanKeys(t *testing.T) {
 	input := `{true: 1, false: 2}`
 
@@ -750,7 +2008,8 @@ func TestParsingHashLiteralsBooleanKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		boolean, ok := key.(*ast.Boolean)
 		if !ok {
 			t.Errorf("key is not ast.BooleanLiteral. got=%T", key)
@@ -786,7 +2045,8 @@ func TestParsingHashLiteralsIntegerKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		integer, ok := key.(*ast.IntegerLiteral)
 		if !ok {
 			t.Errorf("key is not ast.IntegerLiteral. got=%T", key)
@@ -803,7 +2063,7 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15/5}`
 	l := lexer.New(input)
 	p := New(l)
-	program := p. ParseProgram()
+	program := p.ParseProgram()
 	checkParserErrors(t, p)
 	stmt := program.Statements[0].(*ast.ExpressionStatement)
 	hash, ok := stmt.Expression.(*ast.HashLiteral)
@@ -824,22 +2084,23 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			testInfixExpression(t, e, 15, "/", 5)
 		},
 	}
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
 			continue
 		}
-		testFunc, ok := tests[literal.String()]
+		testFunc, ok := tests[literal.Value]
 		if !ok {
-			t.Errorf("No test function for key %q found", literal.String())
+			t.Errorf("No test function for key %q found", literal.Value)
 			continue
 		}
 		testFunc(value)
 	}
 }
 
-///// HELPER Functions //////
+// /// HELPER Functions //////
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 
 	if s.TokenLiteral() != "let" {
@@ -968,3 +2229,287 @@ func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
 	}
 	return true
 }
+
+func TestRegisterOperators(t *testing.T) {
+	// the lexer has no |> token, => stands in for it
+	newPipelineParser := func(input string) *Parser {
+		p := New(lexer.New(input))
+		p.SetPrecedence(token.ARROW, LOWEST+1)
+		p.RegisterInfix(token.ARROW, func(left ast.Expression) ast.Expression {
+			call := &ast.CallExpression{Token: p.CurToken(), Arguments: []ast.Expression{left}}
+			prec := p.Precedence(p.CurToken().Type)
+			p.NextToken()
+			call.Function = p.ParseExpression(prec)
+			return call
+		})
+		return p
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x => f", "f(x)"},
+		{"x => f => g", "g(f(x))"},
+		{"1 + 2 => double", "double((1 + 2))"},
+		{"arr[0] => fn(x) { x }", "fn(x) { x }((arr[0]))"},
+	}
+	for _, tt := range tests {
+		p := newPipelineParser(tt.input)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	// a custom prefix function replacing a built-in one, reporting errors through Error
+	p := New(lexer.New("-5; -x"))
+	p.RegisterPrefix(token.MINUS, func() ast.Expression {
+		if !p.ExpectPeek(token.INT) {
+			return nil
+		}
+		value, _ := strconv.ParseInt("-"+p.CurToken().Literal, 0, 64)
+		if value < -1 {
+			p.Error("negative literal too small")
+		}
+		return &ast.IntegerLiteral{Token: p.CurToken(), Value: value}
+	})
+	p.ParseProgram()
+	expected := []string{"negative literal too small", "expected next token to be INT, got IDENT instead"}
+	if !reflect.DeepEqual(p.Errors(), expected) {
+		t.Errorf("wrong errors. expected=%q, got=%q", expected, p.Errors())
+	}
+
+	// the changes are local to the parser they were made on
+	p = New(lexer.New("x => f"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("a new parser accepted a custom operator")
+	}
+	if precedences[token.ARROW] != 0 {
+		t.Errorf("SetPrecedence changed the default precedences")
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := `let add = fn(x, y) { x + y };
+add(1, b: "two")[0];
+if (!ok) { arr[1:] } else { {"a": [1, 2]} }
+match (x) { 1..5 => null, _ => |v| v * 2 }
+try { throw 1 } catch (e) { macro(a) { a } }`
+
+	tests := []struct {
+		node     func(program *ast.Program) ast.Node
+		expected string
+	}{
+		{func(pr *ast.Program) ast.Node { return pr }, input},
+		{func(pr *ast.Program) ast.Node { return pr.Statements[0] }, "let add = fn(x, y) { x + y }"},
+		{func(pr *ast.Program) ast.Node { return pr.Statements[0].(*ast.LetStatement).Name }, "add"},
+		{func(pr *ast.Program) ast.Node {
+			return pr.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral).Body
+		}, "{ x + y }"},
+		{func(pr *ast.Program) ast.Node { return pr.Statements[1] }, `add(1, b: "two")[0]`},
+		{func(pr *ast.Program) ast.Node {
+			return pr.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression).Left
+		}, `add(1, b: "two")`},
+		{func(pr *ast.Program) ast.Node {
+			call := pr.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression).Left
+			return call.(*ast.CallExpression).NamedArguments[0]
+		}, `b: "two"`},
+		{func(pr *ast.Program) ast.Node { return pr.Statements[2] }, `if (!ok) { arr[1:] } else { {"a": [1, 2]} }`},
+		{func(pr *ast.Program) ast.Node {
+			return pr.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.IfExpression).Condition
+		}, "!ok"},
+		{func(pr *ast.Program) ast.Node {
+			return pr.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.IfExpression).Consequence.Statements[0]
+		}, "arr[1:]"},
+		{func(pr *ast.Program) ast.Node {
+			return pr.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.IfExpression).Alternative.Statements[0]
+		}, `{"a": [1, 2]}`},
+		{func(pr *ast.Program) ast.Node { return pr.Statements[3] }, "match (x) { 1..5 => null, _ => |v| v * 2 }"},
+		{func(pr *ast.Program) ast.Node {
+			return pr.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression).Arms[0]
+		}, "1..5 => null"},
+		{func(pr *ast.Program) ast.Node {
+			return pr.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression).Arms[1].Body
+		}, "|v| v * 2"},
+		{func(pr *ast.Program) ast.Node { return pr.Statements[4] }, "try { throw 1 } catch (e) { macro(a) { a } }"},
+		{func(pr *ast.Program) ast.Node {
+			return pr.Statements[4].(*ast.ExpressionStatement).Expression.(*ast.TryExpression).Block.Statements[0]
+		}, "throw 1"},
+	}
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	for i, tt := range tests {
+		node := tt.node(program)
+		if got := input[node.Pos():node.End()]; got != tt.expected {
+			t.Errorf("tests[%d]: wrong source range for %T. want=%q, got=%q", i, node, tt.expected, got)
+		}
+	}
+}
+
+// tokenSlice is a TokenSource over a fixed list of tokens
+type tokenSlice []token.Token
+
+func (ts *tokenSlice) NextToken() token.Token {
+	if len(*ts) == 0 {
+		return token.Token{Type: token.EOF}
+	}
+	tok := (*ts)[0]
+	*ts = (*ts)[1:]
+	return tok
+}
+
+func TestTokenSource(t *testing.T) {
+	tokens := tokenSlice{
+		{Type: token.LET, Literal: "let"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.PLUS, Literal: "+"},
+		{Type: token.IDENT, Literal: "y"},
+		{Type: token.IDENT, Literal: "x"},
+	}
+	p := New(&tokens)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "let x = (1 + y);x" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+
+	tokens = tokenSlice{
+		{Type: token.LET, Literal: "let", Position: token.Position{Line: 3, Column: 5}},
+		{Type: token.EOF, Position: token.Position{Line: 3, Column: 8}},
+	}
+	p = New(&tokens)
+	p.ParseProgram()
+	expected := []string{"3:8: expected next token to be IDENT, got EOF instead"}
+	var got []string
+	for _, err := range p.ParseErrors() {
+		got = append(got, err.Error())
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong errors. expected=%q, got=%q", expected, got)
+	}
+}
+
+// TestStringRoundTrip checks that String prints programs the parser reads back into the same tree
+func TestStringRoundTrip(t *testing.T) {
+	inputs := []string{
+		"x; (y)",
+		"a; -b; !c",
+		"if (a) { b }; [c]",
+		"if (a) { if (b) { c } else { d } } else { }",
+		"fn(x) { x; x }(5)",
+		`{"a": {"b": [1, 2]}}["a"]`,
+		"let f = |x| |y| x + y; f(1)(2)",
+		"-(-5) - -5",
+		"(1..3)[0]",
+	}
+	for _, tt := range grammarCorpus {
+		if tt.valid {
+			inputs = append(inputs, tt.input)
+		}
+	}
+
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		printed := program.String()
+		p = New(lexer.New(printed))
+		reparsed := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("%q printed as %q, which doesn't parse: %q", input, printed, p.Errors())
+			continue
+		}
+		if !ast.Equal(program, reparsed) {
+			t.Errorf("%q printed as %q, which parses to %q", input, printed, reparsed.String())
+		}
+	}
+}
+
+func TestFprint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1 + 2 * 3; (1 + 2) * 3; a - (b - c); (a - b) - c", "let x = 1 + 2 * 3;\n(1 + 2) * 3;\na - (b - c);\na - b - c;\n"},
+		{"-(a + b); -(-a); !!a; (a + b)[0]; (1..n + 1)", "-(a + b);\n-(-a);\n!!a;\n(a + b)[0];\n1..n + 1;\n"},
+		{"let max = fn(a, b) { if (a > b) { a } else { return b; } };",
+			"let max = fn(a, b) {\n    if (a > b) {\n        a\n    } else {\n        return b;\n    }\n};\n"},
+		{"map(arr, |x| x * 2); fn() {}", "map(arr, fn(x) {\n    x * 2\n});\nfn() {};\n"},
+		{`match (x) { 1 => "one", _ => {"a": [1, 2]}[y] }`, "match (x) {\n    1 => \"one\",\n    _ => {\"a\": [1, 2]}[y],\n};\n"},
+		{"try { throw 1; } catch (e) { puts(e, sep: \"\"); e }", "try {\n    throw 1;\n} catch (e) {\n    puts(e, sep: \"\");\n    e\n};\n"},
+		{`"a\n${(x+1)*2} \${y} ${"in${z}"}"`, `"a\n${(x + 1) * 2} \${y} ${"in${z}"}";` + "\n"},
+		{"select { case v = recv(c): puts(v); v case send(d, 1 + 2): default: }; select {}",
+			"select {\n    case v = recv(c):\n        puts(v);\n        v\n    case send(d, 1 + 2):\n    default:\n};\nselect {};\n"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var out bytes.Buffer
+		if err := ast.Fprint(&out, program, nil); err != nil {
+			t.Fatalf("Fprint returned error: %s", err)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("Fprint(%q) wrong. expected=\n%s\ngot=\n%s", tt.input, tt.expected, out.String())
+			continue
+		}
+
+		p = New(lexer.New(out.String()))
+		reparsed := p.ParseProgram()
+		checkParserErrors(t, p)
+		if !ast.Equal(program, reparsed) {
+			t.Errorf("Fprint(%q) doesn't parse back to the same tree, got %q", tt.input, reparsed.String())
+		}
+	}
+
+	var out bytes.Buffer
+	block := &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: &ast.Identifier{Value: "x"}}}}
+	ast.Fprint(&out, &ast.IfExpression{Condition: &ast.Identifier{Value: "c"}, Consequence: block}, &ast.PrintOptions{Indent: "\t"})
+	if out.String() != "if (c) {\n\tx\n}\n" {
+		t.Errorf("Fprint with tab indent wrong. got=%q", out.String())
+	}
+}
+
+func TestSdump(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1 + 2;", "(let x (+ 1 2))"},
+		{"const y = -a * b", "(const y (* (- a) b))"},
+		{"a + b * c == d; !x", "(== (+ a (* b c)) d)\n(! x)"},
+		{`f(a, b: "s")[0]`, `(index (call f a (named b "s")) 0)`},
+		{"arr[:2]; 1..n", "(slice arr () 2)\n(.. 1 n)"},
+		{"fn(x, y) { return x; }; fn() {}", "(fn (x y) (block (return x)))\n(fn () (block))"},
+		{"if (c) { 1 } else { 2 }; if (c) { null }", "(if c (block 1) (block 2))\n(if c (block null))"},
+		{`{"a": [1, true]}`, `(hash ("a" (array 1 true)))`},
+		{"match (x) { 1 => a, _ => b }", "(match x (=> 1 a) (=> _ b))"},
+		{"try { throw e; } catch (err) { err }", "(try (block (throw e)) err (block err))"},
+		{"select { case v = recv(c): v case receive(c): case send(c, 1): default: x }",
+			"(select (case (= v (recv c)) (block v)) (case (receive c) (block)) (case (send c 1) (block)) (default (block x)))"},
+		{"defer f(x)", "(defer (call f x))"},
+		{"for (k, v in h) { k }", "(for-in k v h (block k))"},
+		{`"a ${x + 1}${y}\n"`, `(interpolate "a " (+ x 1) "" y "\n")`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := ast.Sdump(program); got != tt.expected {
+			t.Errorf("Sdump(%q) wrong. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
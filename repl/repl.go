@@ -4,26 +4,69 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
+	"monkey/crash"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"runtime/debug"
+	"strings"
 )
 
 const PROMPT = ">> "
 
+// A Command implements a meta-command, typed at the prompt as `:name args`.
+// args is the rest of the line after the command name, with surrounding whitespace trimmed
+type Command func(args string, env *object.Environment, out io.Writer)
+
+// A Renderer displays the result of evaluating a line. It returns false if it doesn't
+// handle the given object, in which case the next renderer gets a chance
+type Renderer func(obj object.Object, out io.Writer) bool
+
+var (
+	commands  = map[string]Command{}
+	renderers = []Renderer{}
+)
+
+// When CrashBundles is set, a panic while evaluating a line is recovered and reported with a
+// crash bundle (see package crash) under CrashDir, containing every line entered so far. The
+// session then carries on with the next line
+var (
+	CrashBundles bool
+	CrashDir     string
+)
+
+// RegisterCommand makes cmd available as `:name` in the REPL, replacing any command registered under the same name
+func RegisterCommand(name string, cmd Command) {
+	commands[name] = cmd
+}
+
+// RegisterRenderer adds r in front of the renderers registered so far, so later registrations
+// take precedence. Results no renderer handles are printed with Inspect()
+func RegisterRenderer(r Renderer) {
+	renderers = append([]Renderer{r}, renderers...)
+}
+
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	history := []string{}
 
 	for {
-		fmt.Printf(PROMPT)
+		fmt.Fprint(out, PROMPT)
 		scanned := scanner.Scan()
 		if !scanned {
 			return
 		}
 
 		line := scanner.Text()
+		if strings.HasPrefix(line, ":") {
+			runCommand(out, line[1:], env)
+			continue
+		}
+
+		history = append(history, line)
 		l := lexer.New(line)
 		p := parser.New(l)
 
@@ -33,12 +76,63 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		evalLine(out, program, env, history)
+	}
+}
+
+func evalLine(out io.Writer, program *ast.Program, env *object.Environment, history []string) {
+	if CrashBundles {
+		defer func() {
+			if r := recover(); r != nil {
+				reportCrash(out, program, history, r)
+			}
+		}()
+	}
+
+	evaluated := evaluator.Eval(program, env)
+	if evaluated != nil {
+		render(out, evaluated)
+	}
+}
+
+func reportCrash(out io.Writer, program *ast.Program, history []string, r interface{}) {
+	report := &crash.Report{
+		Source:  strings.Join(history, "\n") + "\n",
+		Program: program,
+		Engine:  "evaluator",
+		Panic:   r,
+		Stack:   debug.Stack(),
+	}
+	fmt.Fprintf(out, "internal error: %v\n", r)
+	dir, err := crash.Write(CrashDir, report)
+	if err != nil {
+		fmt.Fprintf(out, "could not write a crash bundle: %s\n", err)
+		return
+	}
+	fmt.Fprintf(out, "a crash report was written to %s, please attach it to a bug report\n", dir)
+}
+
+func runCommand(out io.Writer, line string, env *object.Environment) {
+	name, args := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, args = line[:i], strings.TrimSpace(line[i:])
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		io.WriteString(out, "unknown command :"+name+"\n")
+		return
+	}
+	cmd(args, env, out)
+}
+
+func render(out io.Writer, obj object.Object) {
+	for _, r := range renderers {
+		if r(obj, out) {
+			return
 		}
 	}
+	io.WriteString(out, obj.Inspect())
+	io.WriteString(out, "\n")
 }
 
 func printParserErrors(out io.Writer, errors []string) {
@@ -0,0 +1,105 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"monkey/object"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	in := strings.NewReader("let a = 5;\na * 2\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	expected := PROMPT + PROMPT + "10\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestCommands(t *testing.T) {
+	RegisterCommand("get", func(args string, env *object.Environment, out io.Writer) {
+		val, ok := env.Get(args)
+		if !ok {
+			io.WriteString(out, "unbound\n")
+			return
+		}
+		io.WriteString(out, args+" is "+val.Inspect()+"\n")
+	})
+	defer delete(commands, "get")
+
+	in := strings.NewReader("let a = 5;\n:get   a  \n:get b\n:nope\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	expected := PROMPT + PROMPT + "a is 5\n" + PROMPT + "unbound\n" + PROMPT + "unknown command :nope\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	RegisterRenderer(func(obj object.Object, out io.Writer) bool {
+		if obj.Type() != object.INTEGER_OBJ {
+			return false
+		}
+		io.WriteString(out, "int("+obj.Inspect()+")\n")
+		return true
+	})
+	defer func() { renderers = []Renderer{} }()
+
+	in := strings.NewReader("1 + 1\n\"one\"\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	expected := PROMPT + "int(2)\n" + PROMPT + "one\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestCrashBundles(t *testing.T) {
+	RegisterRenderer(func(obj object.Object, out io.Writer) bool {
+		if obj.Inspect() == "13" {
+			panic("unlucky")
+		}
+		return false
+	})
+	CrashBundles, CrashDir = true, t.TempDir()
+	defer func() {
+		renderers = []Renderer{}
+		CrashBundles, CrashDir = false, ""
+	}()
+
+	in := strings.NewReader("let a = 6;\na + 7\na\n")
+	var out bytes.Buffer
+	Start(in, &out)
+
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) != 4 || lines[0] != PROMPT+PROMPT+"internal error: unlucky" || lines[2] != PROMPT+"6" {
+		t.Fatalf("wrong output. got=%q", out.String())
+	}
+	dir := strings.TrimSuffix(strings.TrimPrefix(lines[1], "a crash report was written to "), ", please attach it to a bug report")
+
+	files := map[string]string{
+		"source.monkey": "let a = 6;\na + 7\n",
+		"ast.txt":       "(a + 7)\n",
+	}
+	for name, expected := range files {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("crash bundle is missing %s: %s", name, err)
+		}
+		if string(content) != expected {
+			t.Errorf("%s wrong. expected=%q, got=%q", name, expected, content)
+		}
+	}
+	report, _ := ioutil.ReadFile(filepath.Join(dir, "report.txt"))
+	if !strings.HasPrefix(string(report), "panic: unlucky\n\nengine: evaluator\n") {
+		t.Errorf("report.txt wrong. got=%q", report)
+	}
+}
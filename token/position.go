@@ -0,0 +1,112 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Position is a place in a source file, like go/token.Position. The zero Position is invalid
+type Position struct {
+	Filename string // empty for input that isn't a file, eg a line typed into the REPL
+	Offset   int    // byte offset, starting at 0
+	Line     int    // starting at 1
+	Column   int    // column in bytes, starting at 1
+}
+
+// IsValid reports whether the position points anywhere
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String gives the position in one of the forms
+//
+//	file:line:column  valid position with a file name
+//	line:column       valid position without a file name
+//	file              invalid position with a file name
+//	-                 invalid position without a file name
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// A File is a source file added to a FileSet
+type File struct {
+	name  string
+	src   string
+	lines []int // offset of the first character of each line
+}
+
+// Name is the name the file was added with
+func (f *File) Name() string { return f.name }
+
+// Source is the whole text of the file
+func (f *File) Source() string { return f.src }
+
+// LineCount is the number of lines in the file
+func (f *File) LineCount() int { return len(f.lines) }
+
+// Position turns a byte offset into the file into a Position. Offsets past the end of the file are
+// clamped to it
+func (f *File) Position(offset int) Position {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(f.src) {
+		offset = len(f.src)
+	}
+	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	return Position{Filename: f.name, Offset: offset, Line: i + 1, Column: offset - f.lines[i] + 1}
+}
+
+// A FileSet is the set of files making up a program, eg a script and the ones it imports or the
+// chunks typed into a REPL session. It's safe for concurrent use
+type FileSet struct {
+	mu    sync.Mutex
+	files []*File
+}
+
+func NewFileSet() *FileSet {
+	return &FileSet{}
+}
+
+// AddFile adds the file called filename with the source src to the set
+func (s *FileSet) AddFile(filename, src string) *File {
+	f := &File{name: filename, src: src, lines: []int{0}}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files = append(s.files, f)
+	return f
+}
+
+// File returns the file added last under filename, nil if there is none
+func (s *FileSet) File(filename string) *File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.files) - 1; i >= 0; i-- {
+		if s.files[i].name == filename {
+			return s.files[i]
+		}
+	}
+	return nil
+}
+
+// Files returns the files of the set in the order they were added
+func (s *FileSet) Files() []*File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*File(nil), s.files...)
+}
@@ -0,0 +1,52 @@
+package token
+
+import "testing"
+
+func TestFileSet(t *testing.T) {
+	fset := NewFileSet()
+	a := fset.AddFile("a.mk", "let x = 1;\nlet y = 2;\n")
+	b := fset.AddFile("b.mk", "x")
+
+	tests := []struct {
+		file     *File
+		offset   int
+		expected string
+	}{
+		{a, 0, "a.mk:1:1"},
+		{a, 4, "a.mk:1:5"},
+		{a, 10, "a.mk:1:11"},
+		{a, 11, "a.mk:2:1"},
+		{a, 22, "a.mk:3:1"},
+		{a, 100, "a.mk:3:1"},
+		{b, 0, "b.mk:1:1"},
+	}
+	for _, tt := range tests {
+		if got := tt.file.Position(tt.offset).String(); got != tt.expected {
+			t.Errorf("%s.Position(%d) wrong. expected=%q, got=%q", tt.file.Name(), tt.offset, tt.expected, got)
+		}
+	}
+
+	if fset.File("b.mk") != b || fset.File("c.mk") != nil || len(fset.Files()) != 2 {
+		t.Errorf("wrong files in the set: %v", fset.Files())
+	}
+	if a.LineCount() != 3 {
+		t.Errorf("a.LineCount() wrong. got=%d", a.LineCount())
+	}
+}
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		pos      Position
+		expected string
+	}{
+		{Position{Filename: "a.mk", Line: 2, Column: 3}, "a.mk:2:3"},
+		{Position{Line: 2, Column: 3}, "2:3"},
+		{Position{Filename: "a.mk"}, "a.mk"},
+		{Position{}, "-"},
+	}
+	for _, tt := range tests {
+		if tt.pos.String() != tt.expected {
+			t.Errorf("String() wrong. expected=%q, got=%q", tt.expected, tt.pos.String())
+		}
+	}
+}
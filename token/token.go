@@ -6,17 +6,20 @@ type TokenType string
 
 // Token
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type     TokenType
+	Literal  string
+	Position // of the token's first character
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // from // to the end of the line
 
 	// Identifieres & literals
 	IDENT = "IDENT" // add, foobar, x, y
 	INT   = "INT"   // 1343456
+	FLOAT = "FLOAT" // 3.14
 
 	// Operators
 	ASSIGN   = "="
@@ -25,15 +28,21 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 	LT       = "<"
 	GT       = ">"
 	EQ       = "=="
 	NOT_EQ   = "!="
+	DOTDOT   = ".."
+	ARROW    = "=>"
+	PIPE     = "|"
+	AND      = "&&"
+	OR       = "||"
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
-	COLON = ":"
+	COLON     = ":"
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -45,24 +54,50 @@ const (
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	NULL     = "NULL"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	MATCH    = "MATCH"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	THROW    = "THROW"
+	DEFER    = "DEFER"
+	MACRO    = "MACRO"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 
 	// Data Types
 	STRING = "STRING"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"null":     NULL,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"match":    MATCH,
+	"try":      TRY,
+	"catch":    CATCH,
+	"throw":    THROW,
+	"defer":    DEFER,
+	"macro":    MACRO,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 // LookupIdent checks whether the word is a keyword. If it is, it returns the keyword's TokenType constant. If it isn't, we get back token.IDENT (the TokenType for all user-defined identifiers)